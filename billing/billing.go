@@ -0,0 +1,47 @@
+// Package billing renders invoices into documents sent to clients.
+package billing
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/types"
+)
+
+const dateLayout = "2006-01-02"
+
+//go:embed invoice.html
+var invoiceTemplate string
+
+var invoiceHTML = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format(dateLayout)
+	},
+	"hours": func(d time.Duration) string {
+		return fmt.Sprintf("%.2f", d.Hours())
+	},
+	"money": func(m types.Money, currency string) string {
+		return m.Format(currency)
+	},
+}).Parse(invoiceTemplate))
+
+// RenderHTML renders invoice as a standalone html page issued by user.
+func RenderHTML(invoice types.Invoice, user types.User) (string, error) {
+	var b strings.Builder
+	err := invoiceHTML.Execute(&b, struct {
+		Invoice types.Invoice
+		Issuer  types.User
+		Time    string
+	}{
+		Invoice: invoice,
+		Issuer:  user,
+		Time:    types.InvoiceLineTime,
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTransferBalances(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	bank := alice.do(http.MethodPost, "/api/v1/accounts", map[string]any{"name": "bank", "kind": "bank", "currency": "EUR", "openingBalance": "100"})["id"].(string)
+	cash := alice.do(http.MethodPost, "/api/v1/accounts", map[string]any{"name": "cash", "kind": "cash", "currency": "USD", "openingBalance": "10"})["id"].(string)
+	alice.do(http.MethodPost, "/api/v1/income", map[string]any{"money": "50", "currency": "EUR", "date": "2024-03-01T09:00:00Z", "accountId": bank})
+	alice.do(http.MethodPost, "/api/v1/moneyspend", map[string]any{"money": "20", "currency": "EUR", "date": "2024-03-02T09:00:00Z", "accountId": bank})
+	transfer := alice.do(http.MethodPost, "/api/v1/transfers", map[string]any{"fromAccountId": bank, "toAccountId": cash, "money": "30", "toMoney": "33", "date": "2024-03-05T09:00:00Z"})["id"].(string)
+
+	balance := func(id, date string) string {
+		t.Helper()
+		res := alice.do(http.MethodGet, "/api/v1/accounts/"+id+"/balance?date="+date, nil)
+		return res["balance"].(map[string]any)["balance"].(string)
+	}
+	tests := []struct {
+		account string
+		date    string
+		want    string
+	}{
+		{bank, "2024-03-04", "130.00"},
+		{cash, "2024-03-04", "10.00"},
+		{bank, "2024-03-05", "100.00"},
+		{cash, "2024-03-05", "43.00"},
+	}
+	for _, tt := range tests {
+		if got := balance(tt.account, tt.date); got != tt.want {
+			t.Fatalf("balance of %s on %s is %s, want %s", tt.account, tt.date, got, tt.want)
+		}
+	}
+
+	alice.fail(http.MethodDelete, "/api/v1/accounts/"+cash, nil)
+	alice.do(http.MethodDelete, "/api/v1/transfers/"+transfer, nil)
+	if got := balance(bank, "2024-03-05"); got != "130.00" {
+		t.Fatalf("balance of bank after deleting the transfer is %s, want 130.00", got)
+	}
+	if got := balance(cash, "2024-03-05"); got != "10.00" {
+		t.Fatalf("balance of cash after deleting the transfer is %s, want 10.00", got)
+	}
+}
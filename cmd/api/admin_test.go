@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminRoutesNeedAdminRole(t *testing.T) {
+	s := newMemoryStores()
+	app := newTestAppOn(t, s)
+	admin := register(t, app, "admin@example.com")
+	alice := register(t, app, "alice@example.com")
+	aliceID := alice.userID()
+	alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z"})
+
+	routes := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodGet, "/api/v1/admin/users", nil},
+		{http.MethodGet, "/api/v1/admin/users/" + aliceID, nil},
+		{http.MethodPut, "/api/v1/admin/users/" + aliceID + "/role", map[string]any{"role": "admin"}},
+		{http.MethodPost, "/api/v1/admin/users/" + aliceID + "/disable", nil},
+		{http.MethodPost, "/api/v1/admin/users/" + aliceID + "/enable", nil},
+		{http.MethodGet, "/api/v1/admin/stats", nil},
+	}
+	for _, route := range routes {
+		alice.fail(route.method, route.path, route.body)
+		admin.fail(route.method, route.path, route.body)
+	}
+
+	if err := promoteAdmin(context.Background(), s.userStore, "admin@example.com"); err != nil {
+		t.Fatalf("promote admin: %v", err)
+	}
+	// the token claims the former role
+	admin.fail(http.MethodGet, "/api/v1/admin/users", nil)
+	admin.login("admin@example.com")
+	if n := len(admin.do(http.MethodGet, "/api/v1/admin/users", nil)["users"].([]any)); n != 2 {
+		t.Fatalf("admin lists %d users, want 2", n)
+	}
+	if role := admin.do(http.MethodGet, "/api/v1/admin/users/"+aliceID, nil)["user"].(map[string]any)["role"]; role != "user" {
+		t.Fatalf("alice has role %v, want user", role)
+	}
+	stats := admin.do(http.MethodGet, "/api/v1/admin/stats", nil)
+	if users := stats["users"].(map[string]any); users["count"] != 2.0 || users["admins"] != 1.0 || users["disabled"] != 0.0 {
+		t.Fatalf("stats count users %v", users)
+	}
+	if timespends := stats["timespends"].(map[string]any); timespends["count"] != 1.0 || timespends["duration"] != float64(time.Hour) {
+		t.Fatalf("stats count timespends %v", timespends)
+	}
+	for _, route := range routes {
+		alice.fail(route.method, route.path, route.body)
+	}
+}
+
+func TestAdminChangesUsers(t *testing.T) {
+	s := newMemoryStores()
+	app := newTestAppOn(t, s)
+	admin := register(t, app, "admin@example.com")
+	alice := register(t, app, "alice@example.com")
+	adminID, aliceID := admin.userID(), alice.userID()
+	if err := promoteAdmin(context.Background(), s.userStore, "admin@example.com"); err != nil {
+		t.Fatalf("promote admin: %v", err)
+	}
+	admin.login("admin@example.com")
+
+	admin.fail(http.MethodPut, "/api/v1/admin/users/"+aliceID+"/role", map[string]any{"role": "owner"})
+	admin.fail(http.MethodPut, "/api/v1/admin/users/"+adminID+"/role", map[string]any{"role": "user"})
+	admin.fail(http.MethodPost, "/api/v1/admin/users/"+adminID+"/disable", nil)
+	admin.fail(http.MethodPost, "/api/v1/admin/users/000000000000000000000000/disable", nil)
+
+	admin.do(http.MethodPost, "/api/v1/admin/users/"+aliceID+"/disable", nil)
+	alice.fail(http.MethodGet, "/api/v1/user", nil)
+	if rec := alice.request(http.MethodPost, "/api/v1/auth/login", map[string]any{"email": "alice@example.com", "password": "supersecret"}); rec.Code == http.StatusOK {
+		t.Fatalf("disabled user logged in")
+	}
+	admin.do(http.MethodPost, "/api/v1/admin/users/"+aliceID+"/enable", nil)
+	alice.login("alice@example.com")
+	alice.do(http.MethodGet, "/api/v1/user", nil)
+
+	admin.do(http.MethodPut, "/api/v1/admin/users/"+aliceID+"/role", map[string]any{"role": "admin"})
+	// tokens issued with the former role are refused
+	alice.fail(http.MethodGet, "/api/v1/user", nil)
+	alice.login("alice@example.com")
+	alice.do(http.MethodGet, "/api/v1/admin/stats", nil)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+)
+
+// apiKey creates an API key of the user of c with scopes and returns its id
+// and a client using it.
+func (c *client) apiKey(scopes ...string) (string, *client) {
+	c.t.Helper()
+	res := c.do(http.MethodPost, "/api/v1/user/tokens", map[string]any{"name": "script", "scopes": scopes})
+	return res["id"].(string), &client{t: c.t, app: c.app, token: res["token"].(string)}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	timespend := map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z"}
+	moneyspend := map[string]any{"money": "5", "currency": "EUR", "date": "2024-03-05T09:00:00Z"}
+
+	_, reader := alice.apiKey("read")
+	reader.do(http.MethodGet, "/api/v1/timespend", nil)
+	reader.do(http.MethodGet, "/api/v1/report/total", nil)
+	reader.fail(http.MethodPost, "/api/v1/timespend", timespend)
+
+	_, scoped := alice.apiKey("moneyspend:write", "timespend:read")
+	scoped.do(http.MethodPost, "/api/v1/moneyspend", moneyspend)
+	scoped.do(http.MethodGet, "/api/v1/moneyspend", nil)
+	scoped.do(http.MethodGet, "/api/v1/timespend", nil)
+	scoped.fail(http.MethodPost, "/api/v1/timespend", timespend)
+	scoped.fail(http.MethodGet, "/api/v1/income", nil)
+
+	_, writer := alice.apiKey("write")
+	writer.do(http.MethodPost, "/api/v1/timespend", timespend)
+	// account routes take access tokens only, so keys can't make keys
+	writer.fail(http.MethodGet, "/api/v1/user", nil)
+	writer.fail(http.MethodPost, "/api/v1/user/tokens", map[string]any{"name": "script", "scopes": []string{"write"}})
+
+	if n := len(alice.do(http.MethodGet, "/api/v1/moneyspend", nil)["monies"].([]any)); n != 1 {
+		t.Fatalf("keys created %d moneyspends of alice, want 1", n)
+	}
+	for _, scopes := range [][]string{nil, {"everything"}, {"timespend:delete"}, {"users:read"}} {
+		alice.fail(http.MethodPost, "/api/v1/user/tokens", map[string]any{"name": "script", "scopes": scopes})
+	}
+}
+
+func TestExpiredAPIKeysAreRefused(t *testing.T) {
+	s := newMemoryStores()
+	alice := register(t, newTestAppOn(t, s), "alice@example.com")
+	alice.fail(http.MethodPost, "/api/v1/user/tokens", map[string]any{"name": "script", "scopes": []string{"read"}, "expiresAt": time.Now().Add(-time.Minute)})
+
+	expiring := alice.do(http.MethodPost, "/api/v1/user/tokens", map[string]any{"name": "script", "scopes": []string{"read"}, "expiresAt": time.Now().Add(time.Hour)})
+	(&client{t: t, app: alice.app, token: expiring["token"].(string)}).do(http.MethodGet, "/api/v1/timespend", nil)
+
+	// keys can't be created expired, so the expired key is stored directly
+	ownerID := alice.userID()
+	token, err := utils.NewToken(types.APIKeyPrefix)
+	if err != nil {
+		t.Fatalf("new token: %v", err)
+	}
+	now := time.Now()
+	key := types.NewAPIKeyFromParams(types.CreateAPIKeyParams{Name: "old", Scopes: []string{"read"}, ExpiresAt: now.Add(-time.Second)}, token, utils.HashToken(token), now.Add(-time.Hour))
+	key.OwnerID = ownerID
+	if _, err := s.apiKeyStore.Create(tenant.WithOwner(context.Background(), ownerID), key); err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	(&client{t: t, app: alice.app, token: token}).fail(http.MethodGet, "/api/v1/timespend", nil)
+	(&client{t: t, app: alice.app, token: types.APIKeyPrefix + "unknown"}).fail(http.MethodGet, "/api/v1/timespend", nil)
+}
+
+func TestRevokedAPIKeysAreRefused(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+	id, key := alice.apiKey("read")
+	_, other := alice.apiKey("read")
+	key.do(http.MethodGet, "/api/v1/timespend", nil)
+
+	tokens := alice.do(http.MethodGet, "/api/v1/user/tokens", nil)["tokens"].([]any)
+	if len(tokens) != 2 {
+		t.Fatalf("alice has %d keys, want 2", len(tokens))
+	}
+	for _, item := range tokens {
+		if _, ok := item.(map[string]any)["tokenHash"]; ok {
+			t.Fatalf("key %v is listed with its hash", item)
+		}
+	}
+
+	bob.fail(http.MethodDelete, "/api/v1/user/tokens/"+id, nil)
+	key.do(http.MethodGet, "/api/v1/timespend", nil)
+	alice.do(http.MethodDelete, "/api/v1/user/tokens/"+id, nil)
+	key.fail(http.MethodGet, "/api/v1/timespend", nil)
+	other.do(http.MethodGet, "/api/v1/timespend", nil)
+}
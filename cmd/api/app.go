@@ -0,0 +1,220 @@
+package main
+
+import (
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/memory"
+	"github.com/SpectralJager/spender/handlers"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+// stores are the stores of one backend the api server is built on.
+type stores struct {
+	userStore         db.UserStore
+	timespendStore    db.SpendStor[types.Timespend]
+	moneyspendStore   db.SpendStor[types.Moneyspend]
+	incomeStore       db.SpendStor[types.Income]
+	accountStore      db.AccountStore
+	transferStore     db.SpendStor[types.Transfer]
+	budgetStore       db.BudgetStore
+	recurringStore    db.RecurringStore
+	timerStore        db.TimerStore
+	sessionStore      db.SessionStore
+	apiKeyStore       db.APIKeyStore
+	projectStore      db.ProjectStore
+	invoiceStore      db.InvoiceStore
+	groupStore        db.GroupStore
+	groupExpenseStore db.GroupExpenseStore
+	categoryStore     db.CategoryStore
+	rateStore         db.ExchangeRateStore
+}
+
+func newMemoryStores() stores {
+	return stores{
+		userStore:         memory.NewUserStore(),
+		timespendStore:    memory.NewTimespendStore(),
+		moneyspendStore:   memory.NewMoneyspendStore(),
+		incomeStore:       memory.NewIncomeStore(),
+		accountStore:      memory.NewAccountStore(),
+		transferStore:     memory.NewTransferStore(),
+		budgetStore:       memory.NewBudgetStore(),
+		recurringStore:    memory.NewRecurringStore(),
+		timerStore:        memory.NewTimerStore(),
+		sessionStore:      memory.NewSessionStore(),
+		apiKeyStore:       memory.NewAPIKeyStore(),
+		projectStore:      memory.NewProjectStore(),
+		invoiceStore:      memory.NewInvoiceStore(),
+		groupStore:        memory.NewGroupStore(),
+		groupExpenseStore: memory.NewGroupExpenseStore(),
+		categoryStore:     memory.NewCategoryStore(),
+		rateStore:         memory.NewExchangeRateStore(),
+	}
+}
+
+// newApp returns the api server on the stores s, signing tokens with
+// keyring.
+func newApp(s stores, keyring *middleware.Keyring) *echo.Echo {
+	middleware.UseKeyring(keyring)
+	authHandler := handlers.NewAuthHandler(s.userStore, s.sessionStore)
+	userHandler := handlers.NewUserHandler(s.userStore, s.groupStore, s.groupExpenseStore)
+	timespendHandler := handlers.NewTimespendHandler(s.timespendStore, s.timerStore, s.projectStore, s.categoryStore)
+	moneyspendHandler := handlers.NewMoneyspendHandler(s.moneyspendStore, s.categoryStore, s.accountStore, s.userStore)
+	incomeHandler := handlers.NewIncomeHandler(s.incomeStore, s.categoryStore, s.accountStore, s.userStore)
+	accountHandler := handlers.NewAccountHandler(s.accountStore, s.transferStore, s.moneyspendStore, s.incomeStore, s.recurringStore, s.userStore)
+	transferHandler := handlers.NewTransferHandler(s.transferStore, s.accountStore)
+	budgetHandler := handlers.NewBudgetHandler(s.budgetStore, s.categoryStore, s.userStore)
+	projectHandler := handlers.NewProjectHandler(s.projectStore, s.timespendStore, s.userStore)
+	invoiceHandler := handlers.NewInvoiceHandler(s.invoiceStore, s.timespendStore, s.moneyspendStore, s.userStore)
+	groupHandler := handlers.NewGroupHandler(s.groupStore, s.groupExpenseStore, s.userStore)
+	recurringHandler := handlers.NewRecurringHandler(s.recurringStore, s.categoryStore, s.accountStore, s.userStore)
+	categoryHandler := handlers.NewCategoryHandler(s.categoryStore, s.timespendStore, s.moneyspendStore, s.incomeStore, s.budgetStore, s.recurringStore, s.timerStore)
+	tagHandler := handlers.NewTagHandler(s.timespendStore, s.moneyspendStore, s.incomeStore)
+	reportHandler := handlers.NewReportHandler(s.timespendStore, s.moneyspendStore, s.incomeStore, s.categoryStore, s.budgetStore, s.projectStore, s.userStore, s.rateStore)
+	rateHandler := handlers.NewExchangeRateHandler(s.rateStore)
+	adminHandler := handlers.NewAdminHandler(s.userStore, s.timespendStore, s.moneyspendStore, s.incomeStore, s.groupStore)
+	keyHandler := handlers.NewKeyHandler(keyring)
+	apiKeyHandler := handlers.NewAPIKeyHandler(s.apiKeyStore)
+	jwtAuthentication := middleware.NewJWTAuthentication(s.userStore, s.sessionStore)
+	apiAuthentication := middleware.NewAPIAuthentication(s.userStore, s.sessionStore, s.apiKeyStore)
+
+	app := echo.New()
+	app.GET("/.well-known/jwks.json", keyHandler.GetJWKS)
+	apiv1 := app.Group("/api/v1")
+	// Authentication api
+	authApi := apiv1.Group("/auth")
+	authApi.POST("/login", authHandler.Authenticate)
+	authApi.POST("/register", authHandler.Register)
+	authApi.POST("/refresh", authHandler.Refresh)
+	authApi.POST("/logout", authHandler.Logout, jwtAuthentication)
+	authApi.POST("/logout-all", authHandler.LogoutAll, jwtAuthentication)
+	// User api
+	userApi := apiv1.Group("/user", jwtAuthentication)
+	userApi.GET("", userHandler.GetUser)
+	userApi.PUT("", userHandler.PutUser)
+	userApi.DELETE("", userHandler.DeleteUser)
+	userApi.GET("/tokens", apiKeyHandler.GetAllAPIKeys)
+	userApi.POST("/tokens", apiKeyHandler.PostAPIKey)
+	userApi.DELETE("/tokens/:id", apiKeyHandler.DeleteAPIKey)
+	// Timespend api
+	timespendApi := apiv1.Group("/timespend", apiAuthentication("timespend"))
+	timespendApi.GET("", timespendHandler.GetAllTimes)
+	timespendApi.POST("", timespendHandler.PostTimespend)
+	timespendApi.GET("/timeline", timespendHandler.GetTimeline)
+	timespendApi.GET("/timer", timespendHandler.GetTimer)
+	timespendApi.DELETE("/timer", timespendHandler.DeleteTimer)
+	timespendApi.POST("/timer/start", timespendHandler.StartTimer)
+	timespendApi.POST("/timer/stop", timespendHandler.StopTimer)
+	timespendApi.GET("/:id", timespendHandler.GetTimespend)
+	timespendApi.PUT("/:id", timespendHandler.PutTimespend)
+	timespendApi.DELETE("/:id", timespendHandler.DeleteTimespend)
+	// Moneyspend api
+	moneyspendApi := apiv1.Group("/moneyspend", apiAuthentication("moneyspend"))
+	moneyspendApi.GET("", moneyspendHandler.GetAllMonies)
+	moneyspendApi.POST("", moneyspendHandler.PostMoneyspend)
+	moneyspendApi.GET("/:id", moneyspendHandler.GetMoneyspend)
+	moneyspendApi.PUT("/:id", moneyspendHandler.PutMoneyspend)
+	moneyspendApi.DELETE("/:id", moneyspendHandler.DeleteMoneyspend)
+	// Income api
+	incomeApi := apiv1.Group("/income", apiAuthentication("income"))
+	incomeApi.GET("", incomeHandler.GetAllIncomes)
+	incomeApi.POST("", incomeHandler.PostIncome)
+	incomeApi.GET("/:id", incomeHandler.GetIncome)
+	incomeApi.PUT("/:id", incomeHandler.PutIncome)
+	incomeApi.DELETE("/:id", incomeHandler.DeleteIncome)
+	// Account api
+	accountApi := apiv1.Group("/accounts", apiAuthentication("accounts"))
+	accountApi.GET("", accountHandler.GetAllAccounts)
+	accountApi.POST("", accountHandler.PostAccount)
+	accountApi.GET("/balances", accountHandler.GetBalances)
+	accountApi.GET("/:id", accountHandler.GetAccount)
+	accountApi.PUT("/:id", accountHandler.PutAccount)
+	accountApi.DELETE("/:id", accountHandler.DeleteAccount)
+	accountApi.GET("/:id/balance", accountHandler.GetBalance)
+	// Transfer api
+	transferApi := apiv1.Group("/transfers", apiAuthentication("transfers"))
+	transferApi.GET("", transferHandler.GetAllTransfers)
+	transferApi.POST("", transferHandler.PostTransfer)
+	transferApi.GET("/:id", transferHandler.GetTransfer)
+	transferApi.DELETE("/:id", transferHandler.DeleteTransfer)
+	// Budget api
+	budgetApi := apiv1.Group("/budgets", apiAuthentication("budgets"))
+	budgetApi.GET("", budgetHandler.GetAllBudgets)
+	budgetApi.POST("", budgetHandler.PostBudget)
+	budgetApi.GET("/:id", budgetHandler.GetBudget)
+	budgetApi.PUT("/:id", budgetHandler.PutBudget)
+	budgetApi.DELETE("/:id", budgetHandler.DeleteBudget)
+	// Project api
+	projectApi := apiv1.Group("/projects", apiAuthentication("projects"))
+	projectApi.GET("", projectHandler.GetAllProjects)
+	projectApi.POST("", projectHandler.PostProject)
+	projectApi.GET("/:id", projectHandler.GetProject)
+	projectApi.PUT("/:id", projectHandler.PutProject)
+	projectApi.DELETE("/:id", projectHandler.DeleteProject)
+	// Invoice api
+	invoiceApi := apiv1.Group("/invoices", apiAuthentication("invoices"))
+	invoiceApi.GET("", invoiceHandler.GetAllInvoices)
+	invoiceApi.POST("", invoiceHandler.PostInvoice)
+	invoiceApi.GET("/:id", invoiceHandler.GetInvoice)
+	invoiceApi.GET("/:id/document", invoiceHandler.GetInvoiceDocument)
+	invoiceApi.DELETE("/:id", invoiceHandler.DeleteInvoice)
+	// Group api
+	groupApi := apiv1.Group("/groups", apiAuthentication("groups"))
+	groupApi.GET("", groupHandler.GetAllGroups)
+	groupApi.POST("", groupHandler.PostGroup)
+	groupApi.GET("/invitations", groupHandler.GetInvitations)
+	groupApi.POST("/:id/join", groupHandler.PostJoin)
+	memberApi := groupApi.Group("/:id", middleware.GroupMembership(s.groupStore))
+	memberApi.GET("", groupHandler.GetGroup)
+	memberApi.PUT("", groupHandler.PutGroup)
+	memberApi.DELETE("", groupHandler.DeleteGroup)
+	memberApi.POST("/invitations", groupHandler.PostInvitation)
+	memberApi.POST("/leave", groupHandler.PostLeave)
+	memberApi.GET("/expenses", groupHandler.GetAllExpenses)
+	memberApi.POST("/expenses", groupHandler.PostExpense)
+	memberApi.GET("/expenses/:expenseId", groupHandler.GetExpense)
+	memberApi.DELETE("/expenses/:expenseId", groupHandler.DeleteExpense)
+	memberApi.POST("/settle", groupHandler.PostSettle)
+	memberApi.GET("/balances", groupHandler.GetBalances)
+	// Recurring api
+	recurringApi := apiv1.Group("/recurring", apiAuthentication("recurring"))
+	recurringApi.GET("", recurringHandler.GetAllRecurring)
+	recurringApi.POST("", recurringHandler.PostRecurring)
+	recurringApi.GET("/:id", recurringHandler.GetRecurring)
+	recurringApi.PUT("/:id", recurringHandler.PutRecurring)
+	recurringApi.DELETE("/:id", recurringHandler.DeleteRecurring)
+	recurringApi.GET("/:id/upcoming", recurringHandler.GetUpcoming)
+	recurringApi.POST("/:id/skip", recurringHandler.PostSkip)
+	// Category api
+	categoryApi := apiv1.Group("/categories", apiAuthentication("categories"))
+	categoryApi.GET("", categoryHandler.GetAllCategories)
+	categoryApi.POST("", categoryHandler.PostCategory)
+	categoryApi.GET("/:id", categoryHandler.GetCategory)
+	categoryApi.PUT("/:id", categoryHandler.PutCategory)
+	categoryApi.DELETE("/:id", categoryHandler.DeleteCategory)
+	// Tag api
+	tagApi := apiv1.Group("/tags", apiAuthentication("tags"))
+	tagApi.GET("", tagHandler.GetTags)
+	// Exchange rate api
+	rateApi := apiv1.Group("/rates", apiAuthentication("rates"))
+	rateApi.GET("", rateHandler.GetRate)
+	// Report api
+	reportApi := apiv1.Group("/report", apiAuthentication("report"))
+	reportApi.GET("/total", reportHandler.GetTotalSpend)
+	reportApi.GET("/series", reportHandler.GetSeries)
+	reportApi.GET("/by-category", reportHandler.GetByCategory)
+	reportApi.GET("/by-tag", reportHandler.GetByTag)
+	reportApi.GET("/cashflow", reportHandler.GetCashflow)
+	reportApi.GET("/budgets", reportHandler.GetBudgets)
+	reportApi.GET("/billable", reportHandler.GetBillable)
+	// Admin api
+	adminApi := apiv1.Group("/admin", jwtAuthentication, middleware.RequireRoles(types.RoleAdmin))
+	adminApi.GET("/users", adminHandler.GetAllUsers)
+	adminApi.GET("/users/:id", adminHandler.GetUser)
+	adminApi.PUT("/users/:id/role", adminHandler.PutUserRole)
+	adminApi.POST("/users/:id/disable", adminHandler.PostDisableUser)
+	adminApi.POST("/users/:id/enable", adminHandler.PostEnableUser)
+	adminApi.GET("/stats", adminHandler.GetStats)
+
+	return app
+}
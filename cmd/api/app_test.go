@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// client calls the api server as a registered user.
+type client struct {
+	t     *testing.T
+	app   *echo.Echo
+	token string
+}
+
+// newTestApp returns the api server on memory stores.
+func newTestApp(t *testing.T) *echo.Echo {
+	t.Helper()
+	return newTestAppOn(t, newMemoryStores())
+}
+
+// newTestAppOn returns the api server on the stores s, for tests which set
+// up entities the api can't create.
+func newTestAppOn(t *testing.T, s stores) *echo.Echo {
+	t.Helper()
+	keyring, err := middleware.NewEphemeralKeyring()
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	return newApp(s, keyring)
+}
+
+// register registers a user with email and returns a client of the user.
+func register(t *testing.T, app *echo.Echo, email string) *client {
+	t.Helper()
+	c := &client{t: t, app: app}
+	rec := c.request(http.MethodPost, "/api/v1/auth/register", map[string]any{
+		"firstName": "John",
+		"lastName":  "Doe",
+		"email":     email,
+		"password":  "supersecret",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register %s: %d %s", email, rec.Code, rec.Body)
+	}
+	c.token = rec.Header().Get("X-Api-Token")
+	return c
+}
+
+// login logs the user of c in again, c then uses the new access token. It
+// returns the refresh token of the new session.
+func (c *client) login(email string) string {
+	c.t.Helper()
+	rec := c.request(http.MethodPost, "/api/v1/auth/login", map[string]any{"email": email, "password": "supersecret"})
+	if rec.Code != http.StatusOK {
+		c.t.Fatalf("login %s: %d %s", email, rec.Code, rec.Body)
+	}
+	c.token = rec.Header().Get("X-Api-Token")
+	return rec.Header().Get("X-Refresh-Token")
+}
+
+func (c *client) request(method, path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			c.t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if len(c.token) != 0 {
+		req.Header.Set("X-Api-Token", c.token)
+	}
+	rec := httptest.NewRecorder()
+	c.app.ServeHTTP(rec, req)
+	return rec
+}
+
+// do sends a request which should succeed and returns its decoded response.
+func (c *client) do(method, path string, body any) map[string]any {
+	c.t.Helper()
+	rec := c.request(method, path, body)
+	if rec.Code != http.StatusOK {
+		c.t.Fatalf("%s %s: %d %s", method, path, rec.Code, rec.Body)
+	}
+	res := map[string]any{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		c.t.Fatalf("%s %s: decode response: %v", method, path, err)
+	}
+	return res
+}
+
+// fail sends a request which should fail.
+func (c *client) fail(method, path string, body any) {
+	c.t.Helper()
+	if rec := c.request(method, path, body); rec.Code == http.StatusOK {
+		c.t.Fatalf("%s %s succeeded: %s", method, path, rec.Body)
+	}
+}
+
+func TestForeignSpendsAreHidden(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+
+	tests := []struct {
+		path   string
+		key    string
+		create map[string]any
+		update map[string]any
+	}{
+		{
+			path:   "/api/v1/timespend",
+			key:    "timespend",
+			create: map[string]any{"duration": 3600000000000, "date": "2024-03-05T00:00:00Z", "note": "review"},
+			update: map[string]any{"duration": 60000000000, "date": "2024-03-06T00:00:00Z", "note": "taken"},
+		},
+		{
+			path:   "/api/v1/moneyspend",
+			key:    "money",
+			create: map[string]any{"money": "12.50", "currency": "EUR", "date": "2024-03-05T00:00:00Z", "note": "lunch"},
+			update: map[string]any{"money": "1", "currency": "EUR", "date": "2024-03-06T00:00:00Z", "note": "taken"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			id := alice.do(http.MethodPost, tt.path, tt.create)["id"]
+			path := fmt.Sprintf("%s/%s", tt.path, id)
+			before := alice.do(http.MethodGet, path, nil)[tt.key]
+
+			bob.fail(http.MethodGet, path, nil)
+			bob.fail(http.MethodPut, path, tt.update)
+			bob.fail(http.MethodDelete, path, nil)
+
+			if after := alice.do(http.MethodGet, path, nil)[tt.key]; !reflect.DeepEqual(after, before) {
+				t.Fatalf("foreign requests changed %s to %v, want %v", path, after, before)
+			}
+		})
+	}
+}
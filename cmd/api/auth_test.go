@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// refresh refreshes the tokens with refreshToken and returns the response.
+func (c *client) refresh(refreshToken string) (int, string) {
+	rec := c.request(http.MethodPost, "/api/v1/auth/refresh", map[string]any{"refreshToken": refreshToken})
+	return rec.Code, rec.Header().Get("X-Refresh-Token")
+}
+
+func TestReusedRefreshTokenRevokesSession(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	first := alice.login("alice@example.com")
+	code, second := alice.refresh(first)
+	if code != http.StatusOK || len(second) == 0 {
+		t.Fatalf("refresh returned %d", code)
+	}
+	if code, _ := alice.refresh(first); code == http.StatusOK {
+		t.Fatalf("used refresh token refreshed again")
+	}
+	if code, _ := alice.refresh(second); code == http.StatusOK {
+		t.Fatalf("refresh token of a revoked session refreshed")
+	}
+}
+
+func TestConcurrentRefreshesRotateOnce(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	token := alice.login("alice@example.com")
+
+	const requests = 8
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			codes[i], _ = alice.refresh(token)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	refreshed := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			refreshed++
+		}
+	}
+	if refreshed != 1 {
+		t.Fatalf("%d of %d concurrent refreshes with one token succeeded, want 1", refreshed, requests)
+	}
+}
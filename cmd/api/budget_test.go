@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBudgetPeriodRollsOver(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	alice.do(http.MethodPost, "/api/v1/budgets", map[string]any{"name": "market", "kind": "money", "period": "month", "money": "100", "currency": "EUR", "keyword": "market"})
+	for _, spend := range []struct {
+		money string
+		date  string
+		note  string
+	}{
+		{"60", "2024-02-29T23:59:59Z", "market"},
+		{"30", "2024-03-01T00:00:00Z", "market"},
+		{"20", "2024-03-10T18:00:00Z", "market"},
+		{"50", "2024-03-11T09:00:00Z", "market"},
+		{"99", "2024-03-05T09:00:00Z", "cinema"},
+	} {
+		alice.do(http.MethodPost, "/api/v1/moneyspend", map[string]any{"money": spend.money, "currency": "EUR", "date": spend.date, "note": spend.note})
+	}
+
+	tests := []struct {
+		date   string
+		start  string
+		spent  string
+		count  float64
+		status string
+	}{
+		{"2024-02-29", "2024-02-01T00:00:00Z", "60.00", 1, "ok"},
+		{"2024-03-10", "2024-03-01T00:00:00Z", "50.00", 2, "at-risk"},
+		{"2024-03-31", "2024-03-01T00:00:00Z", "100.00", 3, "ok"},
+		{"2024-04-01", "2024-04-01T00:00:00Z", "0.00", 0, "ok"},
+	}
+	for _, tt := range tests {
+		res := alice.do(http.MethodGet, "/api/v1/report/budgets?date="+tt.date, nil)
+		status := res["budgets"].([]any)[0].(map[string]any)
+		if status["start"] != tt.start || status["spent"] != tt.spent || status["count"] != tt.count || status["status"] != tt.status {
+			t.Fatalf("budget on %s is %v, want start %s, spent %s, count %v and status %s", tt.date, status, tt.start, tt.spent, tt.count, tt.status)
+		}
+	}
+}
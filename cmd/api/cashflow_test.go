@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCashflow(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+	for _, entry := range []struct{ path, money, currency, date string }{
+		{"/api/v1/income", "100", "EUR", "2024-01-31T23:00:00Z"},
+		{"/api/v1/income", "40", "USD", "2024-02-10T09:00:00Z"},
+		{"/api/v1/moneyspend", "30", "EUR", "2024-01-05T09:00:00Z"},
+		{"/api/v1/moneyspend", "50.25", "EUR", "2024-02-29T23:59:59Z"},
+		{"/api/v1/moneyspend", "10", "EUR", "2024-03-01T00:00:00Z"},
+	} {
+		alice.do(http.MethodPost, entry.path, map[string]any{"money": entry.money, "currency": entry.currency, "date": entry.date})
+	}
+	bob.do(http.MethodPost, "/api/v1/income", map[string]any{"money": "1000", "currency": "EUR", "date": "2024-01-10T09:00:00Z"})
+
+	res := alice.do(http.MethodGet, "/api/v1/report/cashflow?unit=month&currency=EUR&start=2024-01-01&end=2024-02-29", nil)
+	want := []struct{ label, income, expenses, net string }{
+		{"2024-01", "100.00", "30.00", "70.00"},
+		{"2024-02", "0.00", "50.25", "-50.25"},
+	}
+	series := res["series"].([]any)
+	if len(series) != len(want) {
+		t.Fatalf("cashflow has %d buckets, want %d", len(series), len(want))
+	}
+	for i, want := range want {
+		got := series[i].(map[string]any)
+		if got["label"] != want.label || got["income"] != want.income || got["expenses"] != want.expenses || got["net"] != want.net {
+			t.Fatalf("bucket %d is %v, want %+v", i, got, want)
+		}
+	}
+	for field, want := range map[string]string{"income": "100.00", "expenses": "80.25", "net": "19.75"} {
+		if got := res[field]; got != want {
+			t.Fatalf("cashflow has %s %v, want %s", field, got, want)
+		}
+	}
+	unconverted := map[string]any{
+		"income":   []any{map[string]any{"currency": "USD", "money": "40.00", "count": 1.0}},
+		"expenses": []any{},
+	}
+	if got := res["unconverted"]; !reflect.DeepEqual(got, unconverted) {
+		t.Fatalf("cashflow left %v unconverted, want %v", got, unconverted)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCategoryMovesToTopLevel(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	parent := alice.do(http.MethodPost, "/api/v1/categories", map[string]any{"name": "home"})["id"]
+	child := alice.do(http.MethodPost, "/api/v1/categories", map[string]any{"name": "rent", "parentId": parent})["id"]
+	path := "/api/v1/categories/" + child.(string)
+
+	alice.do(http.MethodPut, path, map[string]any{"icon": "key"})
+	category := alice.do(http.MethodGet, path, nil)["category"].(map[string]any)
+	if category["parentId"] != parent {
+		t.Fatalf("update without parentId moved the category to %v, want %v", category["parentId"], parent)
+	}
+
+	alice.do(http.MethodPut, path, map[string]any{"parentId": ""})
+	category = alice.do(http.MethodGet, path, nil)["category"].(map[string]any)
+	if _, ok := category["parentId"]; ok {
+		t.Fatalf("category has parent %v after moving it to the top level", category["parentId"])
+	}
+}
+
+func TestUsedCategoryIsKept(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	category := alice.do(http.MethodPost, "/api/v1/categories", map[string]any{"name": "home"})["id"]
+	path := "/api/v1/categories/" + category.(string)
+
+	uses := []struct {
+		name   string
+		path   string
+		body   map[string]any
+		delete string
+	}{
+		{"subcategory", "/api/v1/categories", map[string]any{"name": "rent", "parentId": category}, ""},
+		{"timespend", "/api/v1/timespend", map[string]any{"duration": 3600000000000, "date": "2024-03-05T00:00:00Z", "categoryId": category}, ""},
+		{"moneyspend", "/api/v1/moneyspend", map[string]any{"money": "5", "currency": "EUR", "date": "2024-03-05T00:00:00Z", "categoryId": category}, ""},
+		{"income", "/api/v1/income", map[string]any{"money": "5", "currency": "EUR", "date": "2024-03-05T00:00:00Z", "categoryId": category}, ""},
+		{"budget", "/api/v1/budgets", map[string]any{"name": "home", "kind": "money", "period": "month", "money": "100", "currency": "EUR", "categoryId": category}, ""},
+		{"recurring", "/api/v1/recurring", map[string]any{"kind": "money", "freq": "monthly", "monthDay": 1, "start": "2024-03-01T00:00:00Z", "count": 1, "money": "5", "currency": "EUR", "categoryId": category}, ""},
+		{"timer", "/api/v1/timespend/timer/start", map[string]any{"categoryId": category}, "/api/v1/timespend/timer"},
+	}
+	for _, use := range uses {
+		t.Run(use.name, func(t *testing.T) {
+			id := alice.do(http.MethodPost, use.path, use.body)["id"]
+			alice.fail(http.MethodDelete, path, nil)
+			alice.do(http.MethodGet, path, nil)
+			if len(use.delete) == 0 {
+				use.delete = use.path + "/" + id.(string)
+			}
+			alice.do(http.MethodDelete, use.delete, nil)
+		})
+	}
+	alice.do(http.MethodDelete, path, nil)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/exchange"
+	"github.com/SpectralJager/spender/types"
+)
+
+func TestReportsConvertAtRateOfDate(t *testing.T) {
+	s := newMemoryStores()
+	day := func(date string) time.Time {
+		at, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatalf("parse %s: %v", date, err)
+		}
+		return at
+	}
+	_, err := exchange.Import(context.Background(), s.rateStore, []types.ExchangeRate{
+		{From: "EUR", To: "USD", Rate: 1.10, Date: day("2024-03-01")},
+		{From: "EUR", To: "USD", Rate: 1.20, Date: day("2024-03-15")},
+		{From: "JPY", To: "USD", Rate: 0.0067, Date: day("2024-01-01")},
+	})
+	if err != nil {
+		t.Fatalf("import rates: %v", err)
+	}
+	alice := register(t, newTestAppOn(t, s), "alice@example.com")
+	for _, spend := range []struct{ money, currency, date string }{
+		{"10", "EUR", "2024-02-20"},
+		{"10", "EUR", "2024-03-05"},
+		{"5", "USD", "2024-03-10"},
+		{"1000", "JPY", "2024-03-10"},
+		{"10", "EUR", "2024-03-20"},
+	} {
+		alice.do(http.MethodPost, "/api/v1/moneyspend", map[string]any{"money": spend.money, "currency": spend.currency, "date": spend.date + "T09:00:00Z"})
+	}
+	unconvertedEUR := []any{map[string]any{"currency": "EUR", "money": "10.00", "count": 1.0}}
+
+	total := alice.do(http.MethodGet, "/api/v1/report/total?currency=USD&start=2024-02-01&end=2024-03-31", nil)
+	if got := total["moneyspendStats"].(map[string]any)["sum"]; got != "34.70" {
+		t.Fatalf("total in USD is %v, want 34.70", got)
+	}
+	if got := total["unconverted"]; !reflect.DeepEqual(got, unconvertedEUR) {
+		t.Fatalf("total left %v unconverted, want %v", got, unconvertedEUR)
+	}
+
+	series := alice.do(http.MethodGet, "/api/v1/report/series?unit=month&currency=USD&start=2024-02-01&end=2024-03-31", nil)
+	for i, want := range []string{"0.00", "34.70"} {
+		if got := series["series"].([]any)[i].(map[string]any)["money"]; got != want {
+			t.Fatalf("bucket %d of the series in USD has %v, want %s", i, got, want)
+		}
+	}
+	if got := series["unconverted"]; !reflect.DeepEqual(got, unconvertedEUR) {
+		t.Fatalf("series left %v unconverted, want %v", got, unconvertedEUR)
+	}
+
+	// USD converts to EUR at the inverted rate, JPY through USD
+	total = alice.do(http.MethodGet, "/api/v1/report/total?currency=EUR&start=2024-03-01&end=2024-03-31", nil)
+	if got := total["moneyspendStats"].(map[string]any)["sum"]; got != "30.64" {
+		t.Fatalf("total in EUR is %v, want 30.64", got)
+	}
+	if got := total["unconverted"]; !reflect.DeepEqual(got, []any{}) {
+		t.Fatalf("total in EUR left %v unconverted", got)
+	}
+}
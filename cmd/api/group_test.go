@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// userID returns the id of the user of c.
+func (c *client) userID() string {
+	c.t.Helper()
+	return c.do(http.MethodGet, "/api/v1/user", nil)["user"].(map[string]any)["id"].(string)
+}
+
+func TestGroupMembers(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+	carol := register(t, app, "carol@example.com")
+	group := "/api/v1/groups/" + alice.do(http.MethodPost, "/api/v1/groups", map[string]any{"name": "Flat", "currency": "EUR"})["id"].(string)
+	for _, member := range []struct {
+		email  string
+		client *client
+	}{{"bob@example.com", bob}, {"carol@example.com", carol}} {
+		member.client.fail(http.MethodPost, group+"/join", nil)
+		alice.do(http.MethodPost, group+"/invitations", map[string]any{"email": member.email})
+		member.client.do(http.MethodPost, group+"/join", nil)
+		member.client.fail(http.MethodPost, group+"/join", nil)
+	}
+
+	expense := group + "/expenses/" + bob.do(http.MethodPost, group+"/expenses", map[string]any{"money": "30", "date": "2024-03-02T00:00:00Z", "note": "groceries"})["id"].(string)
+	carol.fail(http.MethodDelete, expense, nil)
+	alice.do(http.MethodDelete, expense, nil)
+	expense = group + "/expenses/" + carol.do(http.MethodPost, group+"/expenses", map[string]any{"money": "30", "date": "2024-03-02T00:00:00Z", "note": "groceries", "payerId": bob.userID()})["id"].(string)
+	bob.do(http.MethodDelete, expense, nil)
+
+	bob.do(http.MethodPost, group+"/expenses", map[string]any{"money": "30", "date": "2024-03-02T00:00:00Z", "note": "groceries"})
+	bob.fail(http.MethodDelete, "/api/v1/user", nil)
+	alice.fail(http.MethodDelete, "/api/v1/user", nil)
+	for _, debtor := range []*client{alice, carol} {
+		debtor.do(http.MethodPost, group+"/settle", map[string]any{"to": bob.userID(), "money": "10"})
+	}
+	bobID, carolID := bob.userID(), carol.userID()
+	alice.do(http.MethodDelete, "/api/v1/user", nil)
+
+	got := bob.do(http.MethodGet, group, nil)["group"].(map[string]any)
+	if got["createdBy"] != bobID {
+		t.Fatalf("group of a deleted user is created by %v, want the next member %s", got["createdBy"], bobID)
+	}
+	if members := got["members"]; !reflect.DeepEqual(members, []any{bobID, carolID}) {
+		t.Fatalf("members of the group are %v after deleting its creator, want [%s %s]", members, bobID, carolID)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+var invoiceParams = map[string]any{"client": "Acme", "hourlyRate": "90", "currency": "EUR", "start": "2024-03-01", "end": "2024-03-31"}
+
+func TestInvoicedEntriesAreLocked(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	timespend := "/api/v1/timespend/" + alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": 3600000000000, "date": "2024-03-05T00:00:00Z", "note": "review"})["id"].(string)
+	moneyspend := "/api/v1/moneyspend/" + alice.do(http.MethodPost, "/api/v1/moneyspend", map[string]any{"money": "12.50", "currency": "EUR", "date": "2024-03-05T00:00:00Z", "note": "train", "reimbursable": true})["id"].(string)
+
+	invoice := alice.do(http.MethodPost, "/api/v1/invoices", invoiceParams)["id"].(string)
+	if got := alice.do(http.MethodGet, timespend, nil)["timespend"].(map[string]any)["invoiceId"]; got != invoice {
+		t.Fatalf("timespend has invoice %v, want %s", got, invoice)
+	}
+	alice.fail(http.MethodPut, timespend, map[string]any{"duration": 60000000000, "date": "2024-03-05T00:00:00Z", "note": "changed"})
+	alice.fail(http.MethodDelete, timespend, nil)
+	alice.fail(http.MethodPut, moneyspend, map[string]any{"money": "1", "currency": "EUR", "date": "2024-03-05T00:00:00Z", "note": "changed"})
+	alice.fail(http.MethodDelete, moneyspend, nil)
+	alice.fail(http.MethodPost, "/api/v1/invoices", invoiceParams)
+
+	alice.do(http.MethodDelete, "/api/v1/invoices/"+invoice, nil)
+	alice.do(http.MethodPut, timespend, map[string]any{"duration": 60000000000, "date": "2024-03-05T00:00:00Z", "note": "changed"})
+	alice.do(http.MethodDelete, moneyspend, nil)
+	again := alice.do(http.MethodPost, "/api/v1/invoices", invoiceParams)["invoice"].(map[string]any)
+	if lines := again["lines"].([]any); len(lines) != 1 {
+		t.Fatalf("invoice after voiding has %d lines, want 1", len(lines))
+	}
+}
+
+func TestConcurrentInvoicesBillEntriesOnce(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	for _, date := range []string{"2024-03-04", "2024-03-05", "2024-03-06"} {
+		alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": 3600000000000, "date": date + "T00:00:00Z", "note": "review"})
+	}
+
+	const requests = 8
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			codes[i] = alice.request(http.MethodPost, "/api/v1/invoices", invoiceParams).Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	invoices := alice.do(http.MethodGet, "/api/v1/invoices", nil)["invoices"].([]any)
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			created++
+		}
+	}
+	if created != len(invoices) {
+		t.Fatalf("%d invoices were created and %d are stored", created, len(invoices))
+	}
+	billed := map[string]string{}
+	numbers := map[float64]bool{}
+	for _, item := range invoices {
+		invoice := item.(map[string]any)
+		number := invoice["number"].(float64)
+		if numbers[number] {
+			t.Fatalf("several invoices have number %v", number)
+		}
+		numbers[number] = true
+		for _, line := range invoice["lines"].([]any) {
+			source := line.(map[string]any)["sourceId"].(string)
+			if other, ok := billed[source]; ok {
+				t.Fatalf("timespend %s is billed on %s and %s", source, other, invoice["id"])
+			}
+			billed[source] = invoice["id"].(string)
+		}
+	}
+	timespends := alice.do(http.MethodGet, "/api/v1/timespend", nil)["timespends"].([]any)
+	for _, item := range timespends {
+		timespend := item.(map[string]any)
+		if got, want := timespend["invoiceId"], billed[timespend["id"].(string)]; got != want {
+			t.Fatalf("timespend %s has invoice %v, billed on %q", timespend["id"], got, want)
+		}
+	}
+	if len(billed) != len(timespends) {
+		t.Fatalf("%d of %d timespends are billed", len(billed), len(timespends))
+	}
+}
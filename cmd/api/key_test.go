@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SpectralJager/spender/middleware"
+)
+
+// testKeys are the keys written to a keyring directory by writeKeys.
+type testKeys struct {
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+	rsa *rsa.PrivateKey
+}
+
+// writeKeys writes an EC key with kid 2024-01, an Ed25519 key with kid
+// 2024-06, the public RSA key of a partner and an HMAC secret to dir.
+func writeKeys(t *testing.T, dir string) testKeys {
+	t.Helper()
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "2024-01.pem"), "PRIVATE KEY", ec, x509.MarshalPKCS8PrivateKey)
+	writePEM(t, filepath.Join(dir, "2024-06.pem"), "PRIVATE KEY", ed, x509.MarshalPKCS8PrivateKey)
+	writePEM(t, filepath.Join(dir, "partner.pem"), "PUBLIC KEY", &rsaKey.PublicKey, x509.MarshalPKIXPublicKey)
+	if err := os.WriteFile(filepath.Join(dir, "legacy.secret"), bytes.Repeat([]byte("s"), 32), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	return testKeys{ec: ec, ed: ed, rsa: rsaKey}
+}
+
+func writePEM(t *testing.T, path, blockType string, key any, marshal func(any) ([]byte, error)) {
+	t.Helper()
+	der, err := marshal(key)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func loadKeyring(t *testing.T, dir, signingKID string) *middleware.Keyring {
+	t.Helper()
+	keyring, err := middleware.LoadKeyring(dir, signingKID)
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	return keyring
+}
+
+// tokenKID returns the kid in the header of the access token of c.
+func (c *client) tokenKID() string {
+	c.t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(c.token, ".")[0])
+	if err != nil {
+		c.t.Fatalf("decode token header: %v", err)
+	}
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		c.t.Fatalf("decode token header: %v", err)
+	}
+	return header.Kid
+}
+
+func TestRotatedKeysVerifyTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeKeys(t, dir)
+	s := newMemoryStores()
+
+	alice := register(t, newApp(s, loadKeyring(t, dir, "2024-01")), "alice@example.com")
+	if kid := alice.tokenKID(); kid != "2024-01" {
+		t.Fatalf("token is signed with kid %s, want 2024-01", kid)
+	}
+
+	rotated := newApp(s, loadKeyring(t, dir, "2024-06"))
+	alice.app = rotated
+	alice.do(http.MethodGet, "/api/v1/user", nil)
+	bob := register(t, rotated, "bob@example.com")
+	if kid := bob.tokenKID(); kid != "2024-06" {
+		t.Fatalf("token is signed with kid %s, want 2024-06", kid)
+	}
+
+	// tokens signed with a key removed from the keyring are refused
+	if err := os.Remove(filepath.Join(dir, "2024-01.pem")); err != nil {
+		t.Fatalf("remove key: %v", err)
+	}
+	retired := newApp(s, loadKeyring(t, dir, "2024-06"))
+	alice.app, bob.app = retired, retired
+	alice.fail(http.MethodGet, "/api/v1/user", nil)
+	bob.do(http.MethodGet, "/api/v1/user", nil)
+
+	if kid := loadKeyring(t, dir, "").SigningKID(); kid != "legacy" {
+		t.Fatalf("keyring signs with kid %s by default, want legacy", kid)
+	}
+	legacy := newApp(s, loadKeyring(t, dir, "legacy"))
+	carol := register(t, legacy, "carol@example.com")
+	carol.do(http.MethodGet, "/api/v1/user", nil)
+	bob.app = legacy
+	bob.do(http.MethodGet, "/api/v1/user", nil)
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	tests := []struct {
+		name       string
+		write      func(dir string)
+		signingKID string
+	}{
+		{"short secret", func(dir string) {
+			os.WriteFile(filepath.Join(dir, "short.secret"), []byte("secret"), 0o600)
+		}, ""},
+		{"P-384 key", func(dir string) {
+			writePEM(t, filepath.Join(dir, "p384.pem"), "PRIVATE KEY", p384, x509.MarshalPKCS8PrivateKey)
+		}, ""},
+		{"duplicate kid", func(dir string) {
+			writePEM(t, filepath.Join(dir, "key.pem"), "PRIVATE KEY", ed, x509.MarshalPKCS8PrivateKey)
+			os.WriteFile(filepath.Join(dir, "key.secret"), bytes.Repeat([]byte("s"), 32), 0o600)
+		}, ""},
+		{"unknown signing kid", func(dir string) {
+			writePEM(t, filepath.Join(dir, "key.pem"), "PRIVATE KEY", ed, x509.MarshalPKCS8PrivateKey)
+		}, "other"},
+		{"public signing key", func(dir string) {
+			writePEM(t, filepath.Join(dir, "key.pem"), "PUBLIC KEY", ed.Public(), x509.MarshalPKIXPublicKey)
+		}, "key"},
+		{"no keys", func(dir string) {}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.write(dir)
+			if _, err := middleware.LoadKeyring(dir, tt.signingKID); err == nil {
+				t.Fatalf("keyring loaded")
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	keys := writeKeys(t, dir)
+	c := &client{t: t, app: newApp(newMemoryStores(), loadKeyring(t, dir, ""))}
+	jwkInt := func(data []byte) string {
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	want := map[string]any{"keys": []any{
+		map[string]any{
+			"kty": "EC", "kid": "2024-01", "alg": "ES256", "use": "sig", "crv": "P-256",
+			"x": jwkInt(keys.ec.X.FillBytes(make([]byte, 32))),
+			"y": jwkInt(keys.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		map[string]any{
+			"kty": "OKP", "kid": "2024-06", "alg": "EdDSA", "use": "sig", "crv": "Ed25519",
+			"x": jwkInt(keys.ed.Public().(ed25519.PublicKey)),
+		},
+		map[string]any{
+			"kty": "RSA", "kid": "partner", "alg": "RS256", "use": "sig",
+			"n": jwkInt(keys.rsa.N.Bytes()),
+			"e": jwkInt(big.NewInt(int64(keys.rsa.E)).Bytes()),
+		},
+	}}
+	if got := c.do(http.MethodGet, "/.well-known/jwks.json", nil); !reflect.DeepEqual(got, want) {
+		t.Fatalf("jwks are %v, want %v", got, want)
+	}
+	if e := want["keys"].([]any)[2].(map[string]any)["e"]; e != "AQAB" {
+		t.Fatalf("RSA exponent is encoded as %v, want AQAB", e)
+	}
+}
@@ -3,77 +3,199 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/SpectralJager/spender/db"
-	"github.com/SpectralJager/spender/handlers"
+	"github.com/SpectralJager/spender/db/postgres"
+	"github.com/SpectralJager/spender/db/sqlite"
+	"github.com/SpectralJager/spender/exchange"
 	"github.com/SpectralJager/spender/middleware"
-	"github.com/labstack/echo/v4"
+	"github.com/SpectralJager/spender/recurring"
+	"github.com/SpectralJager/spender/types"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
-	DBURI          = "mongodb://localhost:27017"
-	DBNAME         = "spender"
-	USERCOLL       = "users"
-	TIMESPENDCOLL  = "timespends"
-	MONEYSPENDCOLL = "moneyspends"
+	DBURI            = "mongodb://localhost:27017"
+	POSTGRESDSN      = "postgres://localhost:5432/spender?sslmode=disable"
+	DBNAME           = "spender"
+	USERCOLL         = "users"
+	TIMESPENDCOLL    = "timespends"
+	MONEYSPENDCOLL   = "moneyspends"
+	INCOMECOLL       = "incomes"
+	ACCOUNTCOLL      = "accounts"
+	TRANSFERCOLL     = "transfers"
+	BUDGETCOLL       = "budgets"
+	RECURRINGCOLL    = "recurring"
+	TIMERCOLL        = "timers"
+	SESSIONCOLL      = "sessions"
+	APIKEYCOLL       = "api_keys"
+	PROJECTCOLL      = "projects"
+	INVOICECOLL      = "invoices"
+	GROUPCOLL        = "groups"
+	GROUPEXPENSECOLL = "group_expenses"
+	CATEGORYCOLL     = "categories"
+	RATECOLL         = "exchange_rates"
 )
 
 func main() {
 	listenAddr := flag.String("addr", ":8080", "the listhen addres of api server")
+	storeKind := flag.String("store", "mongo", "the store backend of api server: mongo, postgres, sqlite or memory")
+	sqlitePath := flag.String("sqlite", "spender.db", "the database file of sqlite store")
+	postgresDSN := flag.String("postgres", POSTGRESDSN, "the connection string of postgres store")
+	ratesPath := flag.String("rates", "", "the .csv or .json file of exchange rates to import on start")
+	jwtKeys := flag.String("jwt-keys", "", "the directory of the keys tokens are signed and verified with, a key is generated on start when it is empty")
+	jwtKID := flag.String("jwt-kid", "", "the kid of the key tokens are signed with, the last one in -jwt-keys when it is empty")
+	adminEmail := flag.String("admin", "", "the email of a registered user to give the admin role on start")
+	recurringInterval := flag.Duration("recurring", time.Minute, "how often due recurring rules are materialized, 0 disables it")
 	flag.Parse()
 
 	ctx := context.Background()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(DBURI))
+	var (
+		keyring *middleware.Keyring
+		err     error
+	)
+	if len(*jwtKeys) != 0 {
+		keyring, err = middleware.LoadKeyring(*jwtKeys, *jwtKID)
+	} else {
+		log.Println("tokens are signed with a key generated on start, they are refused after a restart unless -jwt-keys is set")
+		keyring, err = middleware.NewEphemeralKeyring()
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Disconnect(ctx)
-
-	userStore := db.NewMongoUserStore(client, DBNAME, USERCOLL)
-	timespendStore := db.NewMongoTimespendStore(client, DBNAME, TIMESPENDCOLL)
-	moneyspendStore := db.NewMongoMoneyspendStore(client, DBNAME, MONEYSPENDCOLL)
-
-	authHandler := handlers.NewAuthHandler(userStore)
-	userHandler := handlers.NewUserHandler(userStore)
-	timespendHandler := handlers.NewTimespendHandler(timespendStore)
-	moneyspendHandler := handlers.NewMoneyspendHandler(moneyspendStore)
-	reportHandler := handlers.NewReportHandler(timespendStore, moneyspendStore)
-
-	app := echo.New()
-	apiv1 := app.Group("/api/v1")
-	// Authentication api
-	authApi := apiv1.Group("/auth")
-	authApi.POST("/login", authHandler.Authenticate)
-	authApi.POST("/register", userHandler.Register)
-	// User api
-	userApi := apiv1.Group("/user", middleware.JWTAuthentication)
-	userApi.GET("", userHandler.GetUser)
-	userApi.PUT("", userHandler.PutUser)
-	userApi.DELETE("", userHandler.DeleteUser)
-	// Timespend api
-	timespendApi := apiv1.Group("/timespend", middleware.JWTAuthentication)
-	timespendApi.GET("", timespendHandler.GetAllTimes)
-	timespendApi.POST("", timespendHandler.PostTimespend)
-	timespendApi.GET("/:id", timespendHandler.GetTimespend)
-	timespendApi.PUT("/:id", timespendHandler.PutTimespend)
-	timespendApi.DELETE("/:id", timespendHandler.DeleteTimespend)
-	// Moneyspend api
-	moneyspendApi := apiv1.Group("/moneyspend", middleware.JWTAuthentication)
-	moneyspendApi.GET("", moneyspendHandler.GetAllMonies)
-	moneyspendApi.POST("", moneyspendHandler.PostMoneyspend)
-	moneyspendApi.GET("/:id", moneyspendHandler.GetMoneyspend)
-	moneyspendApi.PUT("/:id", moneyspendHandler.PutMoneyspend)
-	moneyspendApi.DELETE("/:id", moneyspendHandler.DeleteMoneyspend)
-	// Report api
-	reportApi := apiv1.Group("/report", middleware.JWTAuthentication)
-	reportApi.GET("/total", reportHandler.GetTotalSpend)
 
+	var s stores
+	switch *storeKind {
+	case "mongo":
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(DBURI))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer client.Disconnect(ctx)
+		err = db.MigrateMongo(ctx, client, DBNAME,
+			db.NewMoneyMinorUnitsMigration(MONEYSPENDCOLL),
+			db.NewTimerOwnerIndexMigration(TIMERCOLL),
+			db.NewInvoiceNumberIndexMigration(INVOICECOLL),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		s.userStore = db.NewMongoUserStore(client, DBNAME, USERCOLL)
+		s.timespendStore = db.NewMongoTimespendStore(client, DBNAME, TIMESPENDCOLL)
+		s.moneyspendStore = db.NewMongoMoneyspendStore(client, DBNAME, MONEYSPENDCOLL)
+		s.incomeStore = db.NewMongoIncomeStore(client, DBNAME, INCOMECOLL)
+		s.accountStore = db.NewMongoAccountStore(client, DBNAME, ACCOUNTCOLL)
+		s.transferStore = db.NewMongoTransferStore(client, DBNAME, TRANSFERCOLL)
+		s.budgetStore = db.NewMongoBudgetStore(client, DBNAME, BUDGETCOLL)
+		s.recurringStore = db.NewMongoRecurringStore(client, DBNAME, RECURRINGCOLL)
+		s.timerStore = db.NewMongoTimerStore(client, DBNAME, TIMERCOLL)
+		s.sessionStore = db.NewMongoSessionStore(client, DBNAME, SESSIONCOLL)
+		s.apiKeyStore = db.NewMongoAPIKeyStore(client, DBNAME, APIKEYCOLL)
+		s.projectStore = db.NewMongoProjectStore(client, DBNAME, PROJECTCOLL)
+		s.invoiceStore = db.NewMongoInvoiceStore(client, DBNAME, INVOICECOLL)
+		s.groupStore = db.NewMongoGroupStore(client, DBNAME, GROUPCOLL)
+		s.groupExpenseStore = db.NewMongoGroupExpenseStore(client, DBNAME, GROUPEXPENSECOLL)
+		s.categoryStore = db.NewMongoCategoryStore(client, DBNAME, CATEGORYCOLL)
+		s.rateStore = db.NewMongoExchangeRateStore(client, DBNAME, RATECOLL)
+	case "postgres":
+		sqlDB, err := postgres.Open(ctx, *postgresDSN)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sqlDB.Close()
+
+		s.userStore = postgres.NewUserStore(sqlDB)
+		s.timespendStore = postgres.NewTimespendStore(sqlDB)
+		s.moneyspendStore = postgres.NewMoneyspendStore(sqlDB)
+		s.incomeStore = postgres.NewIncomeStore(sqlDB)
+		s.accountStore = postgres.NewAccountStore(sqlDB)
+		s.transferStore = postgres.NewTransferStore(sqlDB)
+		s.budgetStore = postgres.NewBudgetStore(sqlDB)
+		s.recurringStore = postgres.NewRecurringStore(sqlDB)
+		s.timerStore = postgres.NewTimerStore(sqlDB)
+		s.sessionStore = postgres.NewSessionStore(sqlDB)
+		s.apiKeyStore = postgres.NewAPIKeyStore(sqlDB)
+		s.projectStore = postgres.NewProjectStore(sqlDB)
+		s.invoiceStore = postgres.NewInvoiceStore(sqlDB)
+		s.groupStore = postgres.NewGroupStore(sqlDB)
+		s.groupExpenseStore = postgres.NewGroupExpenseStore(sqlDB)
+		s.categoryStore = postgres.NewCategoryStore(sqlDB)
+		s.rateStore = postgres.NewExchangeRateStore(sqlDB)
+	case "sqlite":
+		sqlDB, err := sqlite.Open(ctx, *sqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sqlDB.Close()
+
+		s.userStore = sqlite.NewUserStore(sqlDB)
+		s.timespendStore = sqlite.NewTimespendStore(sqlDB)
+		s.moneyspendStore = sqlite.NewMoneyspendStore(sqlDB)
+		s.incomeStore = sqlite.NewIncomeStore(sqlDB)
+		s.accountStore = sqlite.NewAccountStore(sqlDB)
+		s.transferStore = sqlite.NewTransferStore(sqlDB)
+		s.budgetStore = sqlite.NewBudgetStore(sqlDB)
+		s.recurringStore = sqlite.NewRecurringStore(sqlDB)
+		s.timerStore = sqlite.NewTimerStore(sqlDB)
+		s.sessionStore = sqlite.NewSessionStore(sqlDB)
+		s.apiKeyStore = sqlite.NewAPIKeyStore(sqlDB)
+		s.projectStore = sqlite.NewProjectStore(sqlDB)
+		s.invoiceStore = sqlite.NewInvoiceStore(sqlDB)
+		s.groupStore = sqlite.NewGroupStore(sqlDB)
+		s.groupExpenseStore = sqlite.NewGroupExpenseStore(sqlDB)
+		s.categoryStore = sqlite.NewCategoryStore(sqlDB)
+		s.rateStore = sqlite.NewExchangeRateStore(sqlDB)
+	case "memory":
+		s = newMemoryStores()
+	default:
+		log.Fatalf("unknown store backend %q", *storeKind)
+	}
+
+	if len(*ratesPath) != 0 {
+		rates, err := exchange.Load(*ratesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		imported, err := exchange.Import(ctx, s.rateStore, rates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("imported %d of %d exchange rates from %s", imported, len(rates), *ratesPath)
+	}
+
+	if len(*adminEmail) != 0 {
+		if err := promoteAdmin(ctx, s.userStore, *adminEmail); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("user %s has the admin role", *adminEmail)
+	}
+
+	if *recurringInterval > 0 {
+		go recurring.NewScheduler(s.recurringStore, s.moneyspendStore, s.timespendStore).Run(ctx, *recurringInterval)
+	}
+
+	app := newApp(s, keyring)
 	if err := app.Start(*listenAddr); err != nil {
 		log.Fatalf("something goes wrong -> %v", err)
 	}
 }
+
+// promoteAdmin gives the admin role to the user registered with email.
+func promoteAdmin(ctx context.Context, userStore db.UserStore, email string) error {
+	user, err := userStore.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("can't find admin %s: %w", email, err)
+	}
+	if user.UserRole() == types.RoleAdmin {
+		return nil
+	}
+	return userStore.Update(ctx, user.ID, types.UserRoleParams{Role: types.RoleAdmin})
+}
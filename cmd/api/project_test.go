@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBillableReport(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	project := func(name, client, rate, currency string) string {
+		t.Helper()
+		return alice.do(http.MethodPost, "/api/v1/projects", map[string]any{"name": name, "client": client, "hourlyRate": rate, "currency": currency})["id"].(string)
+	}
+	website := project("website", "acme", "90.50", "EUR")
+	docs := project("docs", "acme", "50", "EUR")
+	app := project("app", "beta", "12000", "JPY")
+	for _, spend := range []struct {
+		project  string
+		duration time.Duration
+		date     string
+		billable bool
+	}{
+		{website, 90 * time.Minute, "2024-03-05T09:00:00Z", true},
+		{website, time.Hour, "2024-03-06T09:00:00Z", false},
+		{website, 20 * time.Minute, "2024-04-01T09:00:00Z", true},
+		{app, 135 * time.Minute, "2024-03-31T23:00:00Z", true},
+	} {
+		alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": spend.duration, "date": spend.date, "projectId": spend.project, "billable": spend.billable})
+	}
+	alice.fail(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z", "billable": true})
+
+	type billing struct {
+		id          string
+		rate        string
+		billable    time.Duration
+		nonBillable time.Duration
+		amount      string
+	}
+	check := func(want []billing, total []any) {
+		t.Helper()
+		res := alice.do(http.MethodGet, "/api/v1/report/billable?start=2024-03-01&end=2024-03-31", nil)
+		projects := res["projects"].([]any)
+		if len(projects) != len(want) {
+			t.Fatalf("report has %d projects, want %d", len(projects), len(want))
+		}
+		for i, want := range want {
+			got := projects[i].(map[string]any)
+			if got["projectId"] != want.id || got["hourlyRate"] != want.rate || got["amount"] != want.amount ||
+				time.Duration(got["billable"].(float64)) != want.billable || time.Duration(got["nonBillable"].(float64)) != want.nonBillable {
+				t.Fatalf("project %d is billed as %v, want %+v", i, got, want)
+			}
+		}
+		if got := res["total"]; !reflect.DeepEqual(got, total) {
+			t.Fatalf("report totals %v, want %v", got, total)
+		}
+	}
+	check([]billing{
+		{docs, "50.00", 0, 0, "0.00"},
+		{website, "90.50", 90 * time.Minute, time.Hour, "135.75"},
+		{app, "12000", 135 * time.Minute, 0, "27000"},
+	}, []any{
+		map[string]any{"currency": "EUR", "amount": "135.75"},
+		map[string]any{"currency": "JPY", "amount": "27000"},
+	})
+
+	alice.fail(http.MethodPut, "/api/v1/projects/"+website, map[string]any{"name": "website", "client": "acme", "currency": "USD"})
+	alice.fail(http.MethodPut, "/api/v1/projects/"+website, map[string]any{"name": "website", "client": "acme", "hourlyRate": "100.001"})
+	alice.do(http.MethodPut, "/api/v1/projects/"+website, map[string]any{"name": "website", "client": "acme", "hourlyRate": "100"})
+	check([]billing{
+		{docs, "50.00", 0, 0, "0.00"},
+		{website, "100.00", 90 * time.Minute, time.Hour, "150.00"},
+		{app, "12000", 135 * time.Minute, 0, "27000"},
+	}, []any{
+		map[string]any{"currency": "EUR", "amount": "150.00"},
+		map[string]any{"currency": "JPY", "amount": "27000"},
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// timespendAt creates a timespend of an hour dated at date.
+func (c *client) timespendAt(date string) {
+	c.t.Helper()
+	c.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": date, "note": "review"})
+}
+
+func TestDateRangeIncludesEndDay(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	for _, date := range []string{"2024-02-29T23:59:59Z", "2024-03-01T00:00:00Z", "2024-03-31T23:59:59Z", "2024-04-01T00:00:00Z"} {
+		alice.timespendAt(date)
+	}
+
+	timespends := alice.do(http.MethodGet, "/api/v1/timespend?from=2024-03-01&to=2024-03-31", nil)["timespends"].([]any)
+	if len(timespends) != 2 {
+		t.Fatalf("list from 2024-03-01 to 2024-03-31 returned %d timespends, want 2", len(timespends))
+	}
+	stats := alice.do(http.MethodGet, "/api/v1/report/total?start=2024-03-01&end=2024-03-31", nil)["timespendStats"].(map[string]any)
+	if count := stats["count"].(float64); count != 2 {
+		t.Fatalf("total from 2024-03-01 to 2024-03-31 counted %v timespends, want 2", count)
+	}
+	stats = alice.do(http.MethodGet, "/api/v1/report/total?start=2024-03-31&end=2024-03-31", nil)["timespendStats"].(map[string]any)
+	if count := stats["count"].(float64); count != 1 {
+		t.Fatalf("total of 2024-03-31 counted %v timespends, want 1", count)
+	}
+	alice.fail(http.MethodGet, "/api/v1/report/total?start=2024-03-31&end=2024-03-01", nil)
+}
+
+func TestSeriesBuckets(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	// 23:30 on March 30th and 00:30 on April 1st in Berlin, which switches to
+	// summer time on March 31st
+	for _, date := range []string{"2024-03-04T12:00:00Z", "2024-03-30T22:30:00Z", "2024-03-31T22:30:00Z"} {
+		alice.timespendAt(date)
+	}
+
+	type bucket struct {
+		label string
+		start string
+		end   string
+		count float64
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  []bucket
+	}{
+		{
+			name:  "days across daylight saving",
+			query: "unit=day&tz=Europe/Berlin&start=2024-03-30&end=2024-04-01",
+			want: []bucket{
+				{"2024-03-30", "2024-03-29T23:00:00Z", "2024-03-30T23:00:00Z", 1},
+				{"2024-03-31", "2024-03-30T23:00:00Z", "2024-03-31T22:00:00Z", 0},
+				{"2024-04-01", "2024-03-31T22:00:00Z", "2024-04-01T22:00:00Z", 1},
+			},
+		},
+		{
+			name:  "days in utc",
+			query: "unit=day&start=2024-03-30&end=2024-03-31",
+			want: []bucket{
+				{"2024-03-30", "2024-03-30T00:00:00Z", "2024-03-31T00:00:00Z", 1},
+				{"2024-03-31", "2024-03-31T00:00:00Z", "2024-04-01T00:00:00Z", 1},
+			},
+		},
+		{
+			name:  "weeks start on monday",
+			query: "unit=week&start=2024-03-06&end=2024-03-20",
+			want: []bucket{
+				{"2024-W10", "2024-03-04T00:00:00Z", "2024-03-11T00:00:00Z", 1},
+				{"2024-W11", "2024-03-11T00:00:00Z", "2024-03-18T00:00:00Z", 0},
+				{"2024-W12", "2024-03-18T00:00:00Z", "2024-03-25T00:00:00Z", 0},
+			},
+		},
+		{
+			name:  "months",
+			query: "unit=month&start=2024-01-15&end=2024-03-02",
+			want: []bucket{
+				{"2024-01", "2024-01-01T00:00:00Z", "2024-02-01T00:00:00Z", 0},
+				{"2024-02", "2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z", 0},
+				{"2024-03", "2024-03-01T00:00:00Z", "2024-04-01T00:00:00Z", 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := alice.do(http.MethodGet, "/api/v1/report/series?"+tt.query, nil)["series"].([]any)
+			if len(series) != len(tt.want) {
+				t.Fatalf("series has %d buckets, want %d", len(series), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := series[i].(map[string]any)
+				if got["label"] != want.label {
+					t.Fatalf("bucket %d has label %v, want %s", i, got["label"], want.label)
+				}
+				for _, bound := range []struct{ name, want string }{{"start", want.start}, {"end", want.end}} {
+					at, err := time.Parse(time.RFC3339, got[bound.name].(string))
+					if err != nil {
+						t.Fatalf("bucket %s has %s %v: %v", want.label, bound.name, got[bound.name], err)
+					}
+					if !at.Equal(mustParseTime(t, bound.want)) {
+						t.Fatalf("bucket %s has %s %v, want %s", want.label, bound.name, at.UTC(), bound.want)
+					}
+				}
+				if count := got["timespendCount"].(float64); count != want.count {
+					t.Fatalf("bucket %s counted %v timespends, want %v", want.label, count, want.count)
+				}
+				if duration := time.Duration(got["duration"].(float64)); duration != time.Duration(want.count)*time.Hour {
+					t.Fatalf("bucket %s has duration %v, want %v", want.label, duration, time.Duration(want.count)*time.Hour)
+				}
+			}
+		})
+	}
+
+	alice.fail(http.MethodGet, "/api/v1/report/series?unit=day&tz=Mars/Olympus&start=2024-03-30&end=2024-04-01", nil)
+	alice.fail(http.MethodGet, "/api/v1/report/series?unit=fortnight&start=2024-03-30&end=2024-04-01", nil)
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("parse %s: %v", value, err)
+	}
+	return at
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagFilters(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	notes := map[string][]string{
+		"review":   {"Work", " backend ", "work"},
+		"standup":  {"work"},
+		"gym":      {"health"},
+		"untagged": nil,
+	}
+	for note, tags := range notes {
+		alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z", "note": note, "tags": tags})
+	}
+	review := alice.do(http.MethodGet, "/api/v1/timespend?note=review", nil)["timespends"].([]any)[0].(map[string]any)
+	if tags := review["tags"]; !reflect.DeepEqual(tags, []any{"backend", "work"}) {
+		t.Fatalf("tags are stored as %v, want [backend work]", tags)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"tagsAny=work", []string{"review", "standup"}},
+		{"tagsAny=backend,health", []string{"gym", "review"}},
+		{"tagsAny=WORK,work", []string{"review", "standup"}},
+		{"tagsAll=work,backend", []string{"review"}},
+		{"tagsAll=work,health", nil},
+		{"tagsAll=Work,%20work%20", []string{"review", "standup"}},
+		{"tagsAny=work&tagsAll=backend", []string{"review"}},
+		{"tagsAny=", []string{"gym", "review", "standup", "untagged"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, item := range alice.do(http.MethodGet, "/api/v1/timespend?sort=note&"+tt.query, nil)["timespends"].([]any) {
+			got = append(got, item.(map[string]any)["note"].(string))
+		}
+		if len(got) != len(tt.want) || (len(got) != 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Fatalf("%s returned %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTagAutocomplete(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+	for _, tags := range [][]string{{"work", "writing"}, {"work"}, {"walk"}} {
+		alice.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z", "tags": tags})
+	}
+	alice.do(http.MethodPost, "/api/v1/moneyspend", map[string]any{"money": "5", "currency": "EUR", "date": "2024-03-05T09:00:00Z", "tags": []string{"work"}})
+	bob.do(http.MethodPost, "/api/v1/timespend", map[string]any{"duration": time.Hour, "date": "2024-03-05T09:00:00Z", "tags": []string{"wine"}})
+
+	tests := []struct {
+		query string
+		want  []any
+	}{
+		{"prefix=w", []any{
+			map[string]any{"tag": "work", "count": 3.0},
+			map[string]any{"tag": "walk", "count": 1.0},
+			map[string]any{"tag": "writing", "count": 1.0},
+		}},
+		{"prefix=WR", []any{map[string]any{"tag": "writing", "count": 1.0}}},
+		{"prefix=w&limit=1", []any{map[string]any{"tag": "work", "count": 3.0}}},
+		{"prefix=x", []any{}},
+	}
+	for _, tt := range tests {
+		if got := alice.do(http.MethodGet, "/api/v1/tags?"+tt.query, nil)["tags"]; !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("tags with %s are %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	alice.fail(http.MethodGet, "/api/v1/tags?limit=0", nil)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// interval returns the body of a timespend from start to end.
+func interval(start, end string) map[string]any {
+	return map[string]any{"start": start, "end": end, "note": "work"}
+}
+
+func TestOverlappingIntervals(t *testing.T) {
+	app := newTestApp(t)
+	alice := register(t, app, "alice@example.com")
+	bob := register(t, app, "bob@example.com")
+	bob.do(http.MethodPost, "/api/v1/timespend", interval("2024-03-05T08:00:00Z", "2024-03-05T12:00:00Z"))
+
+	first := alice.do(http.MethodPost, "/api/v1/timespend", interval("2024-03-05T09:00:00Z", "2024-03-05T10:00:00Z"))["id"]
+	// intervals which only touch don't overlap
+	touching := alice.do(http.MethodPost, "/api/v1/timespend", interval("2024-03-05T10:00:00Z", "2024-03-05T11:00:00Z"))
+	if overlaps := touching["overlaps"]; !reflect.DeepEqual(overlaps, []any{}) {
+		t.Fatalf("touching interval overlaps %v", overlaps)
+	}
+	second := touching["id"]
+
+	alice.fail(http.MethodPost, "/api/v1/timespend", interval("2024-03-05T09:30:00Z", "2024-03-05T10:30:00Z"))
+	alice.fail(http.MethodPost, "/api/v1/timespend?overlap=reject", interval("2024-03-05T09:30:00Z", "2024-03-05T10:30:00Z"))
+	alice.fail(http.MethodPost, "/api/v1/timespend?overlap=maybe", interval("2024-03-05T11:30:00Z", "2024-03-05T12:30:00Z"))
+	if n := len(alice.do(http.MethodGet, "/api/v1/timespend", nil)["timespends"].([]any)); n != 2 {
+		t.Fatalf("rejected intervals were created, there are %d timespends", n)
+	}
+
+	warned := alice.do(http.MethodPost, "/api/v1/timespend?overlap=warn", interval("2024-03-05T09:30:00Z", "2024-03-05T10:30:00Z"))
+	if overlaps := warned["overlaps"]; !reflect.DeepEqual(overlaps, []any{first, second}) {
+		t.Fatalf("interval overlaps %v, want %v", overlaps, []any{first, second})
+	}
+
+	path := "/api/v1/timespend/" + second.(string)
+	alice.fail(http.MethodPut, path, interval("2024-03-05T10:15:00Z", "2024-03-05T11:00:00Z"))
+	moved := alice.do(http.MethodPut, path, interval("2024-03-05T10:30:00Z", "2024-03-05T11:00:00Z"))
+	if overlaps := moved["overlaps"]; !reflect.DeepEqual(overlaps, []any{}) {
+		t.Fatalf("moved interval overlaps %v", overlaps)
+	}
+}
+
+func TestTimelineSplitsDays(t *testing.T) {
+	alice := register(t, newTestApp(t), "alice@example.com")
+	for _, body := range []map[string]any{
+		interval("2024-03-04T22:00:00Z", "2024-03-05T01:00:00Z"),
+		interval("2024-03-05T02:00:00Z", "2024-03-05T03:00:00Z"),
+		interval("2024-03-05T23:00:00Z", "2024-03-06T02:00:00Z"),
+		{"duration": time.Hour, "date": "2024-03-05T12:00:00Z", "note": "untimed"},
+	} {
+		alice.do(http.MethodPost, "/api/v1/timespend", body)
+	}
+	alice.do(http.MethodPost, "/api/v1/timespend?overlap=warn", interval("2024-03-05T02:30:00Z", "2024-03-05T04:00:00Z"))
+
+	type entry struct {
+		kind       string
+		start, end string
+		overlaps   bool
+	}
+	tests := []struct {
+		name     string
+		query    string
+		timeline []entry
+		tracked  time.Duration
+		gaps     time.Duration
+		untimed  int
+	}{
+		{
+			name:  "utc",
+			query: "date=2024-03-05",
+			timeline: []entry{
+				{"interval", "2024-03-05T00:00:00Z", "2024-03-05T01:00:00Z", false},
+				{"gap", "2024-03-05T01:00:00Z", "2024-03-05T02:00:00Z", false},
+				{"interval", "2024-03-05T02:00:00Z", "2024-03-05T03:00:00Z", false},
+				{"interval", "2024-03-05T02:30:00Z", "2024-03-05T04:00:00Z", true},
+				{"gap", "2024-03-05T04:00:00Z", "2024-03-05T23:00:00Z", false},
+				{"interval", "2024-03-05T23:00:00Z", "2024-03-06T00:00:00Z", false},
+			},
+			tracked: 4 * time.Hour,
+			gaps:    20 * time.Hour,
+			untimed: 1,
+		},
+		{
+			name:  "berlin",
+			query: "date=2024-03-05&tz=Europe/Berlin",
+			timeline: []entry{
+				{"interval", "2024-03-05T00:00:00+01:00", "2024-03-05T02:00:00+01:00", false},
+				{"gap", "2024-03-05T02:00:00+01:00", "2024-03-05T03:00:00+01:00", false},
+				{"interval", "2024-03-05T03:00:00+01:00", "2024-03-05T04:00:00+01:00", false},
+				{"interval", "2024-03-05T03:30:00+01:00", "2024-03-05T05:00:00+01:00", true},
+			},
+			tracked: 4 * time.Hour,
+			gaps:    time.Hour,
+			untimed: 1,
+		},
+		{
+			name:  "next day",
+			query: "date=2024-03-06",
+			timeline: []entry{
+				{"interval", "2024-03-06T00:00:00Z", "2024-03-06T02:00:00Z", false},
+			},
+			tracked: 2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := alice.do(http.MethodGet, "/api/v1/timespend/timeline?"+tt.query, nil)
+			timeline := res["timeline"].([]any)
+			if len(timeline) != len(tt.timeline) {
+				t.Fatalf("timeline has %d entries %v, want %d", len(timeline), timeline, len(tt.timeline))
+			}
+			for i, want := range tt.timeline {
+				got := timeline[i].(map[string]any)
+				overlaps, _ := got["overlaps"].(bool)
+				if got["kind"] != want.kind || got["start"] != want.start || got["end"] != want.end || overlaps != want.overlaps {
+					t.Fatalf("entry %d is %v, want %+v", i, got, want)
+				}
+			}
+			if tracked := time.Duration(res["tracked"].(float64)); tracked != tt.tracked {
+				t.Fatalf("tracked %v, want %v", tracked, tt.tracked)
+			}
+			if gaps := time.Duration(res["gaps"].(float64)); gaps != tt.gaps {
+				t.Fatalf("gaps are %v, want %v", gaps, tt.gaps)
+			}
+			if untimed := len(res["untimed"].([]any)); untimed != tt.untimed {
+				t.Fatalf("%d timespends are untimed, want %d", untimed, tt.untimed)
+			}
+		})
+	}
+}
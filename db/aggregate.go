@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const MaxBuckets = 1000
+
+type Aggregation struct {
+	// Field is the integer field summarized, like minor units of money or a
+	// duration.
+	Field string
+	Conds []Cond
+	// GroupBy optionally splits the summary by the values of a field.
+	GroupBy string
+	// Unwind groups by the elements of the GroupBy array field instead, an
+	// entity counts in the group of each of its elements and entities
+	// without elements are left out.
+	Unwind bool
+	// BucketBy optionally splits the summary, or each group of it, by ranges
+	// of a time field. Entities from Buckets[i] until Buckets[i+1] are put in
+	// bucket Buckets[i], entities outside of all buckets are left out. Empty
+	// buckets are left out as well.
+	BucketBy string
+	Buckets  []time.Time
+}
+
+func (a Aggregation) Validate() error {
+	if a.Unwind && len(a.GroupBy) == 0 {
+		return fmt.Errorf("aggregation can't unwind without a group field")
+	}
+	if len(a.BucketBy) == 0 {
+		return nil
+	}
+	if len(a.Buckets) < 2 || len(a.Buckets) > MaxBuckets+1 {
+		return fmt.Errorf("aggregation needs from 1 to %d buckets", MaxBuckets)
+	}
+	for i := 1; i < len(a.Buckets); i++ {
+		if !a.Buckets[i-1].Before(a.Buckets[i]) {
+			return fmt.Errorf("bucket boundaries should be increasing")
+		}
+	}
+	return nil
+}
+
+// Grouped reports whether the aggregation yields one aggregate per group.
+func (a Aggregation) Grouped() bool {
+	return len(a.GroupBy) != 0 || len(a.BucketBy) != 0
+}
+
+type Aggregate struct {
+	// Group is the GroupBy value the summary is for, nil without grouping.
+	Group any `bson:"_id" json:"group,omitempty"`
+	// Bucket is the start of the BucketBy range the summary is for, zero
+	// without buckets.
+	Bucket time.Time `bson:"bucket" json:"-"`
+	Count  int64     `bson:"count" json:"count"`
+	// Sum, Min and Max are exact, only Avg has fractions.
+	Sum int64   `bson:"sum" json:"sum"`
+	Min int64   `bson:"min" json:"min"`
+	Max int64   `bson:"max" json:"max"`
+	Avg float64 `bson:"avg" json:"avg"`
+}
+
+// Aggregator summarizes entities in the database. Aggregates are ordered by
+// group and then by bucket. Without grouping the result is a single aggregate, also when
+// no entity matches.
+type Aggregator interface {
+	Aggregate(ctx context.Context, a Aggregation) ([]Aggregate, error)
+}
@@ -2,12 +2,36 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 
+	"github.com/SpectralJager/spender/tenant"
 	"github.com/SpectralJager/spender/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNoScope      = errors.New("query on owned collection without owner scope")
+	ErrNoGroupScope = errors.New("query on group collection without group scope")
+	ErrNotFound     = errors.New("entity not found")
+	ErrNoMatch      = errors.New("entity doesn't match the update conditions")
+)
+
+// Scoping names the field queries on a collection are restricted to by the
+// tenant scope of their context.
+type Scoping string
+
+const (
+	// Unscoped collections are shared by every user.
+	Unscoped Scoping = ""
+	// OwnerScoped collections are restricted to the owner of the scope.
+	OwnerScoped Scoping = "ownerid"
+	// GroupScoped collections are restricted to the group of the scope.
+	GroupScoped Scoping = "groupid"
 )
 
 type Dropper interface {
@@ -34,6 +58,32 @@ type UpdateStorer interface {
 	Update(context.Context, string, Updater) error
 }
 
+// ConditionalUpdateStorer updates an entity only while it matches
+// conditions, they are checked by the update itself so of concurrent updates
+// only those matching change the entity.
+type ConditionalUpdateStorer interface {
+	// UpdateIf returns ErrNoMatch when there is no entity with id matching
+	// conds.
+	UpdateIf(ctx context.Context, id string, conds []Cond, updater Updater) error
+}
+
+// SetChange adds Value to the string array Field unless it holds it, or
+// removes it from Field with Remove.
+type SetChange struct {
+	Field  string
+	Value  string
+	Remove bool
+}
+
+// SetUpdateStorer changes values of string arrays of an entity in place, so
+// concurrent changes of other values of the arrays aren't lost.
+type SetUpdateStorer interface {
+	// UpdateSets applies changes in order to the entity with id matching
+	// conds at once. It returns ErrNoMatch when there is no entity with id
+	// matching conds.
+	UpdateSets(ctx context.Context, id string, conds []Cond, changes []SetChange) error
+}
+
 type DeleteStorer interface {
 	Delete(context.Context, string) error
 }
@@ -49,6 +99,8 @@ type BaseCRUDStore[T any] interface {
 type DefaultMongoStore[T any] struct {
 	DefaultMongoDropStore
 	DefaultMongoAllGetStore[T]
+	DefaultMongoQueryStore[T]
+	DefaultMongoAggregateStore
 	DefaultMongoGetStore[T]
 	DefaultMongoCreateStore[T]
 	DefaultMongoUpdateStore
@@ -56,14 +108,77 @@ type DefaultMongoStore[T any] struct {
 }
 
 func NewDefaultMongoStore[T any](coll *mongo.Collection) DefaultMongoStore[T] {
+	return NewScopedMongoStore[T](coll, OwnerScoped)
+}
+
+func NewScopedMongoStore[T any](coll *mongo.Collection, scoping Scoping) DefaultMongoStore[T] {
 	return DefaultMongoStore[T]{
-		DefaultMongoDropStore:   DefaultMongoDropStore{coll},
-		DefaultMongoAllGetStore: DefaultMongoAllGetStore[T]{coll},
-		DefaultMongoGetStore:    DefaultMongoGetStore[T]{coll},
-		DefaultMongoCreateStore: DefaultMongoCreateStore[T]{coll},
-		DefaultMongoUpdateStore: DefaultMongoUpdateStore{coll},
-		DefaultMongoDeleteStore: DefaultMongoDeleteStore{coll},
+		DefaultMongoDropStore:      DefaultMongoDropStore{coll},
+		DefaultMongoAllGetStore:    DefaultMongoAllGetStore[T]{coll, scoping},
+		DefaultMongoQueryStore:     DefaultMongoQueryStore[T]{coll, scoping},
+		DefaultMongoAggregateStore: DefaultMongoAggregateStore{coll, scoping},
+		DefaultMongoGetStore:       DefaultMongoGetStore[T]{coll, scoping},
+		DefaultMongoCreateStore:    DefaultMongoCreateStore[T]{coll},
+		DefaultMongoUpdateStore:    DefaultMongoUpdateStore{coll, scoping},
+		DefaultMongoDeleteStore:    DefaultMongoDeleteStore{coll, scoping},
+	}
+}
+
+// ScopeOwner returns the owner that queries on owned collections must be
+// restricted to. Owned collections are never queried without a scope, only
+// an explicit system scope lifts it, in which case the owner is empty.
+func ScopeOwner(ctx context.Context) (string, error) {
+	scope, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", ErrNoScope
+	}
+	if scope.System {
+		return "", nil
+	}
+	if len(scope.OwnerID) == 0 {
+		return "", ErrNoScope
+	}
+	return scope.OwnerID, nil
+}
+
+// ScopeGroup returns the group that queries on group collections must be
+// restricted to, like ScopeOwner does for owners. Handlers set the group
+// after checking the user is one of its members.
+func ScopeGroup(ctx context.Context) (string, error) {
+	scope, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", ErrNoGroupScope
+	}
+	if scope.System {
+		return "", nil
+	}
+	if len(scope.GroupID) == 0 {
+		return "", ErrNoGroupScope
 	}
+	return scope.GroupID, nil
+}
+
+// ScopeTenant returns the value the scoping field of queries must equal, it
+// is empty when they aren't restricted.
+func ScopeTenant(ctx context.Context, scoping Scoping) (string, error) {
+	switch scoping {
+	case OwnerScoped:
+		return ScopeOwner(ctx)
+	case GroupScoped:
+		return ScopeGroup(ctx)
+	}
+	return "", nil
+}
+
+func scopeFilter(ctx context.Context, scoping Scoping, filter bson.M) error {
+	value, err := ScopeTenant(ctx, scoping)
+	if err != nil {
+		return err
+	}
+	if len(value) != 0 {
+		filter[string(scoping)] = value
+	}
+	return nil
 }
 
 type DefaultMongoDropStore struct {
@@ -75,13 +190,14 @@ func (st DefaultMongoDropStore) Drop(ctx context.Context) error {
 }
 
 type DefaultMongoAllGetStore[T any] struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	scoping Scoping
 }
 
 func (st DefaultMongoAllGetStore[T]) GetAll(ctx context.Context) ([]T, error) {
 	filter := bson.M{}
-	if ctxOwnerID, ok := ctx.Value("ownerID").(string); ok {
-		filter["ownerid"] = ctxOwnerID
+	if err := scopeFilter(ctx, st.scoping, filter); err != nil {
+		return nil, err
 	}
 	cur, err := st.coll.Find(ctx, filter)
 	if err != nil {
@@ -95,18 +211,199 @@ func (st DefaultMongoAllGetStore[T]) GetAll(ctx context.Context) ([]T, error) {
 	return entities, nil
 }
 
+type DefaultMongoQueryStore[T any] struct {
+	coll    *mongo.Collection
+	scoping Scoping
+}
+
+func (st DefaultMongoQueryStore[T]) Query(ctx context.Context, q Query) (Page[T], error) {
+	filter, err := mongoFilter(ctx, st.scoping, q.Conds)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	cursor, ok, err := DecodeCursor(q)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	dir, cmp := 1, "$gt"
+	if q.Desc {
+		dir, cmp = -1, "$lt"
+	}
+	if ok {
+		after := bson.M{IDField: bson.M{cmp: utils.ToObjectID(cursor.ID)}}
+		if sort := q.SortField(); sort != IDField {
+			after = bson.M{"$or": bson.A{
+				bson.M{sort: bson.M{cmp: cursor.Value}},
+				bson.M{sort: cursor.Value, IDField: bson.M{cmp: utils.ToObjectID(cursor.ID)}},
+			}}
+		}
+		and, _ := filter["$and"].(bson.A)
+		filter["$and"] = append(and, after)
+	}
+	sort := bson.D{{Key: IDField, Value: dir}}
+	if q.SortField() != IDField {
+		sort = append(bson.D{{Key: q.SortField(), Value: dir}}, sort...)
+	}
+	opts := options.Find().SetSort(sort).SetLimit(int64(q.PageLimit() + 1))
+	cur, err := st.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	entities := []T{}
+	if err := cur.All(ctx, &entities); err != nil {
+		return Page[T]{}, err
+	}
+	return NewPage(entities, q)
+}
+
+func mongoFilter(ctx context.Context, scoping Scoping, conds []Cond) (bson.M, error) {
+	filter := bson.M{}
+	if err := scopeFilter(ctx, scoping, filter); err != nil {
+		return nil, err
+	}
+	and := bson.A{}
+	for _, cond := range conds {
+		expr, err := mongoCond(cond)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+	if len(and) != 0 {
+		filter["$and"] = and
+	}
+	return filter, nil
+}
+
+func mongoCond(cond Cond) (bson.M, error) {
+	value := cond.Value
+	if cond.Field == IDField {
+		if id, ok := value.(string); ok {
+			value = utils.ToObjectID(id)
+		}
+	}
+	switch cond.Op {
+	case OpEq:
+		return bson.M{cond.Field: value}, nil
+	case OpGt, OpGte, OpLt, OpLte:
+		return bson.M{cond.Field: bson.M{"$" + string(cond.Op): value}}, nil
+	case OpContains:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s condition on %s needs a string", cond.Op, cond.Field)
+		}
+		return bson.M{cond.Field: primitive.Regex{Pattern: regexp.QuoteMeta(str), Options: "i"}}, nil
+	case OpAny, OpAll:
+		values, ok := value.([]string)
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%s condition on %s needs strings", cond.Op, cond.Field)
+		}
+		op := "$in"
+		if cond.Op == OpAll {
+			op = "$all"
+		}
+		return bson.M{cond.Field: bson.M{op: values}}, nil
+	case OpEmpty:
+		return bson.M{cond.Field: bson.M{"$in": bson.A{"", nil}}}, nil
+	}
+	return nil, fmt.Errorf("unsupported condition %s on %s", cond.Op, cond.Field)
+}
+
+type DefaultMongoAggregateStore struct {
+	coll    *mongo.Collection
+	scoping Scoping
+}
+
+func (st DefaultMongoAggregateStore) Aggregate(ctx context.Context, a Aggregation) ([]Aggregate, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	conds := a.Conds
+	if len(a.BucketBy) != 0 {
+		conds = append(conds,
+			Cond{Field: a.BucketBy, Op: OpGte, Value: a.Buckets[0]},
+			Cond{Field: a.BucketBy, Op: OpLt, Value: a.Buckets[len(a.Buckets)-1]},
+		)
+	}
+	filter, err := mongoFilter(ctx, st.scoping, conds)
+	if err != nil {
+		return nil, err
+	}
+	field := "$" + a.Field
+	output := bson.D{
+		{Key: "count", Value: bson.M{"$sum": 1}},
+		{Key: "sum", Value: bson.M{"$sum": field}},
+		{Key: "min", Value: bson.M{"$min": field}},
+		{Key: "max", Value: bson.M{"$max": field}},
+		{Key: "avg", Value: bson.M{"$avg": field}},
+	}
+	id := bson.D{{Key: "group", Value: nil}, {Key: "bucket", Value: nil}}
+	if len(a.GroupBy) != 0 {
+		id[0].Value = "$" + a.GroupBy
+	}
+	if len(a.BucketBy) != 0 {
+		// the range filter above leaves every entity in one of the branches
+		branches := bson.A{}
+		for i, boundary := range a.Buckets[1:] {
+			branches = append(branches, bson.M{
+				"case": bson.M{"$lt": bson.A{"$" + a.BucketBy, boundary}},
+				"then": a.Buckets[i],
+			})
+		}
+		id[1].Value = bson.M{"$switch": bson.M{"branches": branches, "default": nil}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+	}
+	if a.Unwind {
+		pipeline = append(pipeline, bson.D{{Key: "$unwind", Value: "$" + a.GroupBy}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: id}}, output...)}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id.group", Value: 1}, {Key: "_id.bucket", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: "$_id.group"},
+			{Key: "bucket", Value: "$_id.bucket"},
+			{Key: "count", Value: 1},
+			{Key: "sum", Value: 1},
+			{Key: "min", Value: 1},
+			{Key: "max", Value: 1},
+			{Key: "avg", Value: 1},
+		}}},
+	)
+	cur, err := st.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	aggregates := []Aggregate{}
+	if err := cur.All(ctx, &aggregates); err != nil {
+		return nil, err
+	}
+	for i := range aggregates {
+		aggregates[i].Group = GoValue(aggregates[i].Group)
+	}
+	if !a.Grouped() && len(aggregates) == 0 {
+		aggregates = append(aggregates, Aggregate{})
+	}
+	return aggregates, nil
+}
+
 type DefaultMongoGetStore[T any] struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	scoping Scoping
 }
 
 func (st DefaultMongoGetStore[T]) GetByID(ctx context.Context, id string) (T, error) {
 	var entity T
 	filter := bson.M{}
 	filter["_id"] = utils.ToObjectID(id)
-	if ctxOwnerID, ok := ctx.Value("ownerID").(string); ok {
-		filter["ownerid"] = ctxOwnerID
+	if err := scopeFilter(ctx, st.scoping, filter); err != nil {
+		return entity, err
 	}
 	err := st.coll.FindOne(ctx, filter).Decode(&entity)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entity, ErrNotFound
+	}
 	return entity, err
 }
 
@@ -123,7 +420,8 @@ func (st DefaultMongoCreateStore[T]) Create(ctx context.Context, newEntity T) (s
 }
 
 type DefaultMongoUpdateStore struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	scoping Scoping
 }
 
 func (st DefaultMongoUpdateStore) Update(ctx context.Context, id string, updater Updater) error {
@@ -133,8 +431,8 @@ func (st DefaultMongoUpdateStore) Update(ctx context.Context, id string, updater
 	}
 	filter := bson.M{}
 	filter["_id"] = utils.ToObjectID(id)
-	if ctxOwnerID, ok := ctx.Value("ownerID").(string); ok {
-		filter["ownerid"] = ctxOwnerID
+	if err := scopeFilter(ctx, st.scoping, filter); err != nil {
+		return err
 	}
 	res, err := st.coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: entityBson}})
 	if err != nil {
@@ -146,15 +444,71 @@ func (st DefaultMongoUpdateStore) Update(ctx context.Context, id string, updater
 	return nil
 }
 
+func (st DefaultMongoUpdateStore) UpdateIf(ctx context.Context, id string, conds []Cond, updater Updater) error {
+	entityBson, err := updater.ToBsonDoc()
+	if err != nil {
+		return err
+	}
+	filter, err := mongoFilter(ctx, st.scoping, conds)
+	if err != nil {
+		return err
+	}
+	filter["_id"] = utils.ToObjectID(id)
+	res, err := st.coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: entityBson}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount <= 0 {
+		return ErrNoMatch
+	}
+	return nil
+}
+
+func (st DefaultMongoUpdateStore) UpdateSets(ctx context.Context, id string, conds []Cond, changes []SetChange) error {
+	filter, err := mongoFilter(ctx, st.scoping, conds)
+	if err != nil {
+		return err
+	}
+	filter["_id"] = utils.ToObjectID(id)
+	// arrays are computed by an update pipeline, unlike $addToSet and $pull
+	// it handles null arrays and several changes of one array
+	set := bson.M{}
+	for _, change := range changes {
+		array, ok := set[change.Field]
+		if !ok {
+			array = bson.M{"$ifNull": bson.A{"$" + change.Field, bson.A{}}}
+		}
+		value := bson.M{"$literal": change.Value}
+		if change.Remove {
+			set[change.Field] = bson.M{"$filter": bson.M{"input": array, "cond": bson.M{"$ne": bson.A{"$$this", value}}}}
+		} else {
+			set[change.Field] = bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{value, array}},
+				array,
+				bson.M{"$concatArrays": bson.A{array, bson.A{value}}},
+			}}
+		}
+	}
+	res, err := st.coll.UpdateOne(ctx, filter, mongo.Pipeline{{{Key: "$set", Value: set}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount <= 0 {
+		return ErrNoMatch
+	}
+	return nil
+}
+
 type DefaultMongoDeleteStore struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	scoping Scoping
 }
 
 func (st DefaultMongoDeleteStore) Delete(ctx context.Context, id string) error {
 	filter := bson.M{}
 	filter["_id"] = utils.ToObjectID(id)
-	if ctxOwnerID, ok := ctx.Value("ownerID").(string); ok {
-		filter["ownerid"] = ctxOwnerID
+	if err := scopeFilter(ctx, st.scoping, filter); err != nil {
+		return err
 	}
 	res, err := st.coll.DeleteOne(ctx, filter)
 	if err != nil {
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/SpectralJager/spender/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScopeFilter(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		scoping Scoping
+		want    bson.M
+		err     error
+	}{
+		{"unscoped collection", ctx, Unscoped, bson.M{}, nil},
+		{"owner", tenant.WithOwner(ctx, "alice"), OwnerScoped, bson.M{"ownerid": "alice"}, nil},
+		{"system", tenant.WithSystem(ctx), OwnerScoped, bson.M{}, nil},
+		{"no scope", ctx, OwnerScoped, nil, ErrNoScope},
+		{"empty owner", tenant.WithOwner(ctx, ""), OwnerScoped, nil, ErrNoScope},
+		{"group", tenant.WithGroup(tenant.WithOwner(ctx, "alice"), "flat"), GroupScoped, bson.M{"groupid": "flat"}, nil},
+		{"owner without group", tenant.WithOwner(ctx, "alice"), GroupScoped, nil, ErrNoGroupScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := bson.M{}
+			err := scopeFilter(tt.ctx, tt.scoping, filter)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("scope filter returned %v, want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(filter, tt.want) {
+				t.Fatalf("scope filter is %v, want %v", filter, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (st *Store[T]) Aggregate(ctx context.Context, a db.Aggregation) ([]db.Aggregate, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conds, err := bsonConds(a.Conds)
+	if err != nil {
+		return nil, err
+	}
+
+	st.coll.mu.RLock()
+	defer st.coll.mu.RUnlock()
+	type groupKey struct {
+		group  any
+		bucket primitive.DateTime
+	}
+	groups := map[groupKey]*db.Aggregate{}
+	keys := []groupKey{}
+	for _, id := range st.coll.ids {
+		doc := st.coll.docs[id]
+		if !st.match(doc, tenantID) {
+			continue
+		}
+		ok, err := matchConds(doc, conds)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		var bucket primitive.DateTime
+		if len(a.BucketBy) != 0 {
+			date, ok := doc[a.BucketBy].(primitive.DateTime)
+			if !ok {
+				continue
+			}
+			i := sort.Search(len(a.Buckets), func(i int) bool {
+				return a.Buckets[i].After(date.Time())
+			})
+			if i == 0 || i == len(a.Buckets) {
+				continue
+			}
+			bucket = primitive.NewDateTimeFromTime(a.Buckets[i-1])
+		}
+		value, ok := integer(doc[a.Field])
+		if !ok && doc[a.Field] != nil {
+			return nil, fmt.Errorf("field %s is not an integer", a.Field)
+		}
+		groupValues := []any{nil}
+		if len(a.GroupBy) != 0 {
+			groupValues[0] = doc[a.GroupBy]
+		}
+		if a.Unwind {
+			// like mongo $unwind, a non array value is its only element
+			groupValues = []any{}
+			switch elems := doc[a.GroupBy].(type) {
+			case primitive.A:
+				groupValues = elems
+			case nil:
+			default:
+				groupValues = append(groupValues, elems)
+			}
+		}
+		for _, groupValue := range groupValues {
+			key := groupKey{group: groupValue, bucket: bucket}
+			group, ok := groups[key]
+			if !ok {
+				group = &db.Aggregate{Group: db.GoValue(groupValue), Min: value, Max: value}
+				if len(a.BucketBy) != 0 {
+					group.Bucket = bucket.Time().UTC()
+				}
+				groups[key] = group
+				keys = append(keys, key)
+			}
+			group.Count++
+			group.Sum += value
+			group.Min = min(group.Min, value)
+			group.Max = max(group.Max, value)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c := compare(keys[i].group, keys[j].group); c != 0 {
+			return c < 0
+		}
+		return keys[i].bucket < keys[j].bucket
+	})
+
+	aggregates := []db.Aggregate{}
+	for _, key := range keys {
+		group := groups[key]
+		group.Avg = float64(group.Sum) / float64(group.Count)
+		aggregates = append(aggregates, *group)
+	}
+	if !a.Grouped() && len(aggregates) == 0 {
+		aggregates = append(aggregates, db.Aggregate{})
+	}
+	return aggregates, nil
+}
+
+// bsonConds converts condition values to the representation of stored
+// documents, so they compare like mongo compares them.
+func bsonConds(conds []db.Cond) ([]db.Cond, error) {
+	converted := make([]db.Cond, len(conds))
+	for i, cond := range conds {
+		if cond.Field == db.IDField {
+			if id, ok := cond.Value.(string); ok {
+				cond.Value = utils.ToObjectID(id)
+			}
+		}
+		value, err := toBsonValue(cond.Value)
+		if err != nil {
+			return nil, err
+		}
+		cond.Value = value
+		converted[i] = cond
+	}
+	return converted, nil
+}
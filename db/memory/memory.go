@@ -0,0 +1,409 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collection keeps entities as bson documents, so that encoding, partial
+// updates and owner filtering behave the same way as in mongo.
+type collection struct {
+	mu   sync.RWMutex
+	docs map[string]bson.M
+	ids  []string
+}
+
+func newCollection() *collection {
+	return &collection{
+		docs: map[string]bson.M{},
+	}
+}
+
+type Store[T any] struct {
+	coll    *collection
+	scoping db.Scoping
+	// unique lists the sets of fields no two documents have the same values
+	// of, like unique indexes do in mongo and sql.
+	unique [][]string
+}
+
+func NewStore[T any](owned bool) *Store[T] {
+	if owned {
+		return NewScopedStore[T](db.OwnerScoped)
+	}
+	return NewScopedStore[T](db.Unscoped)
+}
+
+func NewScopedStore[T any](scoping db.Scoping) *Store[T] {
+	return &Store[T]{
+		coll:    newCollection(),
+		scoping: scoping,
+	}
+}
+
+func (st *Store[T]) Drop(ctx context.Context) error {
+	st.coll.mu.Lock()
+	defer st.coll.mu.Unlock()
+	st.coll.docs = map[string]bson.M{}
+	st.coll.ids = nil
+	return nil
+}
+
+func (st *Store[T]) GetAll(ctx context.Context) ([]T, error) {
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st.coll.mu.RLock()
+	defer st.coll.mu.RUnlock()
+	entities := []T{}
+	for _, id := range st.coll.ids {
+		doc := st.coll.docs[id]
+		if !st.match(doc, tenantID) {
+			continue
+		}
+		entity, err := fromDoc[T](doc)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+func (st *Store[T]) GetByID(ctx context.Context, id string) (T, error) {
+	var entity T
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return entity, err
+	}
+	st.coll.mu.RLock()
+	defer st.coll.mu.RUnlock()
+	doc, ok := st.coll.docs[key(id)]
+	if !ok || !st.match(doc, tenantID) {
+		return entity, db.ErrNotFound
+	}
+	return fromDoc[T](doc)
+}
+
+func (st *Store[T]) Create(ctx context.Context, newEntity T) (string, error) {
+	doc, err := toDoc(newEntity)
+	if err != nil {
+		return "", err
+	}
+	oid := primitive.NewObjectID()
+	doc["_id"] = oid
+	st.coll.mu.Lock()
+	defer st.coll.mu.Unlock()
+	if err := st.checkUnique(doc); err != nil {
+		return "", err
+	}
+	st.coll.docs[oid.Hex()] = doc
+	st.coll.ids = append(st.coll.ids, oid.Hex())
+	return oid.Hex(), nil
+}
+
+func (st *Store[T]) Update(ctx context.Context, id string, updater db.Updater) error {
+	matched, modified, err := st.update(ctx, id, nil, updater)
+	if err != nil {
+		return err
+	}
+	if !matched || !modified {
+		return fmt.Errorf("no changes for entity with id = %s", id)
+	}
+	return nil
+}
+
+func (st *Store[T]) UpdateIf(ctx context.Context, id string, conds []db.Cond, updater db.Updater) error {
+	matched, _, err := st.update(ctx, id, conds, updater)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return db.ErrNoMatch
+	}
+	return nil
+}
+
+// update sets the fields of updater on the entity with id when it matches
+// conds, and reports whether it matched and whether a field changed.
+func (st *Store[T]) update(ctx context.Context, id string, conds []db.Cond, updater db.Updater) (bool, bool, error) {
+	entityBson, err := updater.ToBsonDoc()
+	if err != nil {
+		return false, false, err
+	}
+	update, err := toDoc(entityBson)
+	if err != nil {
+		return false, false, err
+	}
+	if len(update) == 0 {
+		return false, false, fmt.Errorf("empty update for entity with id = %s", id)
+	}
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return false, false, err
+	}
+	conds, err = bsonConds(conds)
+	if err != nil {
+		return false, false, err
+	}
+	st.coll.mu.Lock()
+	defer st.coll.mu.Unlock()
+	doc, ok := st.coll.docs[key(id)]
+	if !ok || !st.match(doc, tenantID) {
+		return false, false, nil
+	}
+	if ok, err := matchConds(doc, conds); err != nil || !ok {
+		return false, false, err
+	}
+	updated := maps.Clone(doc)
+	modified := false
+	for field, value := range update {
+		if !reflect.DeepEqual(doc[field], value) {
+			updated[field] = value
+			modified = true
+		}
+	}
+	if !modified {
+		return true, false, nil
+	}
+	if err := st.checkUnique(updated); err != nil {
+		return false, false, err
+	}
+	st.coll.docs[key(id)] = updated
+	return true, true, nil
+}
+
+func (st *Store[T]) UpdateSets(ctx context.Context, id string, conds []db.Cond, changes []db.SetChange) error {
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return err
+	}
+	conds, err = bsonConds(conds)
+	if err != nil {
+		return err
+	}
+	st.coll.mu.Lock()
+	defer st.coll.mu.Unlock()
+	doc, ok := st.coll.docs[key(id)]
+	if !ok || !st.match(doc, tenantID) {
+		return db.ErrNoMatch
+	}
+	if ok, err := matchConds(doc, conds); err != nil {
+		return err
+	} else if !ok {
+		return db.ErrNoMatch
+	}
+	updated := maps.Clone(doc)
+	for _, change := range changes {
+		array, _ := updated[change.Field].(primitive.A)
+		without := primitive.A{}
+		for _, value := range array {
+			if value != change.Value {
+				without = append(without, value)
+			}
+		}
+		switch {
+		case change.Remove:
+			updated[change.Field] = without
+		case len(without) == len(array):
+			updated[change.Field] = append(without, change.Value)
+		}
+	}
+	if err := st.checkUnique(updated); err != nil {
+		return err
+	}
+	st.coll.docs[key(id)] = updated
+	return nil
+}
+
+func (st *Store[T]) Delete(ctx context.Context, id string) error {
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return err
+	}
+	st.coll.mu.Lock()
+	defer st.coll.mu.Unlock()
+	k := key(id)
+	doc, ok := st.coll.docs[k]
+	if !ok || !st.match(doc, tenantID) {
+		return fmt.Errorf("can't delete entity with id = %s", id)
+	}
+	delete(st.coll.docs, k)
+	for i, docID := range st.coll.ids {
+		if docID == k {
+			st.coll.ids = append(st.coll.ids[:i], st.coll.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// checkUnique returns an error when another document has the values doc
+// has for the fields of a unique set. Documents missing a field of the set
+// aren't compared, like null values of a unique index in sql. The caller
+// holds the lock of the collection.
+func (st *Store[T]) checkUnique(doc bson.M) error {
+	for _, fields := range st.unique {
+		for _, id := range st.coll.ids {
+			other := st.coll.docs[id]
+			if other["_id"] == doc["_id"] {
+				continue
+			}
+			same := true
+			for _, field := range fields {
+				value, ok := doc[field]
+				if !ok || !reflect.DeepEqual(value, other[field]) {
+					same = false
+					break
+				}
+			}
+			if same {
+				return fmt.Errorf("duplicate %s", strings.Join(fields, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func (st *Store[T]) scope(ctx context.Context) (string, error) {
+	return db.ScopeTenant(ctx, st.scoping)
+}
+
+// match reports whether doc belongs to the tenant the store is scoped to.
+func (st *Store[T]) match(doc bson.M, tenantID string) bool {
+	return len(tenantID) == 0 || doc[string(st.scoping)] == tenantID
+}
+
+type UserStore struct {
+	*Store[types.User]
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		Store: NewStore[types.User](false),
+	}
+}
+
+func (st *UserStore) GetByEmail(ctx context.Context, email string) (types.User, error) {
+	st.coll.mu.RLock()
+	defer st.coll.mu.RUnlock()
+	for _, id := range st.coll.ids {
+		doc := st.coll.docs[id]
+		if doc["email"] == email {
+			return fromDoc[types.User](doc)
+		}
+	}
+	return types.User{}, db.ErrNotFound
+}
+
+func NewTimespendStore() *Store[types.Timespend] {
+	return NewStore[types.Timespend](true)
+}
+
+func NewMoneyspendStore() *Store[types.Moneyspend] {
+	return NewStore[types.Moneyspend](true)
+}
+
+func NewIncomeStore() *Store[types.Income] {
+	return NewStore[types.Income](true)
+}
+
+func NewAccountStore() *Store[types.Account] {
+	return NewStore[types.Account](true)
+}
+
+func NewTransferStore() *Store[types.Transfer] {
+	return NewStore[types.Transfer](true)
+}
+
+func NewBudgetStore() *Store[types.Budget] {
+	return NewStore[types.Budget](true)
+}
+
+func NewRecurringStore() *Store[types.Recurring] {
+	return NewStore[types.Recurring](true)
+}
+
+func NewProjectStore() *Store[types.Project] {
+	return NewStore[types.Project](true)
+}
+
+// NewInvoiceStore returns the store of invoices, their numbers are unique
+// per owner.
+func NewInvoiceStore() *Store[types.Invoice] {
+	st := NewStore[types.Invoice](true)
+	st.unique = [][]string{{"ownerid", "number"}}
+	return st
+}
+
+// NewGroupStore returns the store of groups shared by their members.
+func NewGroupStore() *Store[types.Group] {
+	return NewStore[types.Group](false)
+}
+
+// NewGroupExpenseStore returns the store of group expenses, restricted to
+// the group of the scope.
+func NewGroupExpenseStore() *Store[types.GroupExpense] {
+	return NewScopedStore[types.GroupExpense](db.GroupScoped)
+}
+
+// NewTimerStore returns the store of timers, users have one timer at most.
+func NewTimerStore() *Store[types.Timer] {
+	st := NewStore[types.Timer](true)
+	st.unique = [][]string{{"ownerid"}}
+	return st
+}
+
+func NewSessionStore() *Store[types.Session] {
+	return NewStore[types.Session](true)
+}
+
+func NewAPIKeyStore() *Store[types.APIKey] {
+	return NewStore[types.APIKey](true)
+}
+
+func NewCategoryStore() *Store[types.Category] {
+	return NewStore[types.Category](true)
+}
+
+func NewExchangeRateStore() *Store[types.ExchangeRate] {
+	return NewStore[types.ExchangeRate](false)
+}
+
+func key(id string) string {
+	return utils.ToObjectID(id).Hex()
+}
+
+func toDoc(v any) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func fromDoc[T any](doc bson.M) (T, error) {
+	var entity T
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return entity, err
+	}
+	err = bson.Unmarshal(data, &entity)
+	return entity, err
+}
@@ -0,0 +1,80 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/memory"
+	"github.com/SpectralJager/spender/db/storetest"
+	"github.com/SpectralJager/spender/types"
+)
+
+// crud returns the CRUD contract run on stores made by newStore.
+func crud[T any, S db.BaseCRUDStore[T]](newStore func() S, fx storetest.CRUDFixture[T]) func(t *testing.T) {
+	return func(t *testing.T) {
+		storetest.RunCRUDStore(t, func(t *testing.T) db.BaseCRUDStore[T] {
+			return newStore()
+		}, fx)
+	}
+}
+
+func TestCRUDStores(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"Timespend", crud(memory.NewTimespendStore, storetest.TimespendFixture())},
+		{"Moneyspend", crud(memory.NewMoneyspendStore, storetest.MoneyspendFixture())},
+		{"Category", crud(memory.NewCategoryStore, storetest.CategoryFixture())},
+		{"Income", crud(memory.NewIncomeStore, storetest.IncomeFixture())},
+		{"Account", crud(memory.NewAccountStore, storetest.AccountFixture())},
+		{"Budget", crud(memory.NewBudgetStore, storetest.BudgetFixture())},
+		{"Recurring", crud(memory.NewRecurringStore, storetest.RecurringFixture())},
+		{"Project", crud(memory.NewProjectStore, storetest.ProjectFixture())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	storetest.RunUserStore(t, func(t *testing.T) db.UserStore {
+		return memory.NewUserStore()
+	})
+}
+
+func TestQuery(t *testing.T) {
+	storetest.RunQuery(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return memory.NewTimespendStore()
+	})
+}
+
+func TestMoneyAggregate(t *testing.T) {
+	storetest.RunMoneyAggregate(t, func(t *testing.T) db.SpendStor[types.Moneyspend] {
+		return memory.NewMoneyspendStore()
+	})
+}
+
+func TestTimerStore(t *testing.T) {
+	storetest.RunTimerStore(t, func(t *testing.T) db.TimerStore {
+		return memory.NewTimerStore()
+	})
+}
+
+func TestUpdateIf(t *testing.T) {
+	storetest.RunUpdateIf(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return memory.NewTimespendStore()
+	})
+}
+
+func TestInvoiceStore(t *testing.T) {
+	storetest.RunInvoiceStore(t, func(t *testing.T) db.InvoiceStore {
+		return memory.NewInvoiceStore()
+	})
+}
+
+func TestUpdateSets(t *testing.T) {
+	storetest.RunUpdateSets(t, func(t *testing.T) db.GroupStore {
+		return memory.NewGroupStore()
+	})
+}
@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SpectralJager/spender/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (st *Store[T]) Query(ctx context.Context, q db.Query) (db.Page[T], error) {
+	tenantID, err := st.scope(ctx)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	conds, err := bsonConds(q.Conds)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	cursor, after, err := db.DecodeCursor(q)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	var afterValue any
+	if after {
+		afterValue, err = toBsonValue(cursor.Value)
+		if err != nil {
+			return db.Page[T]{}, err
+		}
+	}
+
+	st.coll.mu.RLock()
+	docs := []bson.M{}
+	for _, id := range st.coll.ids {
+		doc := st.coll.docs[id]
+		if !st.match(doc, tenantID) {
+			continue
+		}
+		ok, err := matchConds(doc, conds)
+		if err != nil {
+			st.coll.mu.RUnlock()
+			return db.Page[T]{}, err
+		}
+		if ok {
+			docs = append(docs, doc)
+		}
+	}
+	st.coll.mu.RUnlock()
+
+	field := q.SortField()
+	order := func(doc bson.M, value any, id string) int {
+		c := compare(doc[field], value)
+		if c == 0 || field == db.IDField {
+			c = strings.Compare(doc[db.IDField].(primitive.ObjectID).Hex(), id)
+		}
+		if q.Desc {
+			return -c
+		}
+		return c
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		other := docs[j]
+		return order(docs[i], other[field], other[db.IDField].(primitive.ObjectID).Hex()) < 0
+	})
+
+	entities := []T{}
+	for _, doc := range docs {
+		if after && order(doc, afterValue, cursor.ID) <= 0 {
+			continue
+		}
+		if len(entities) > q.PageLimit() {
+			break
+		}
+		entity, err := fromDoc[T](doc)
+		if err != nil {
+			return db.Page[T]{}, err
+		}
+		entities = append(entities, entity)
+	}
+	return db.NewPage(entities, q)
+}
+
+func matchConds(doc bson.M, conds []db.Cond) (bool, error) {
+	for _, cond := range conds {
+		value := doc[cond.Field]
+		var ok bool
+		switch cond.Op {
+		case db.OpEq:
+			ok = compare(value, cond.Value) == 0
+		case db.OpGt:
+			ok = value != nil && compare(value, cond.Value) > 0
+		case db.OpGte:
+			ok = value != nil && compare(value, cond.Value) >= 0
+		case db.OpLt:
+			ok = value != nil && compare(value, cond.Value) < 0
+		case db.OpLte:
+			ok = value != nil && compare(value, cond.Value) <= 0
+		case db.OpContains:
+			str, isStr := value.(string)
+			sub, isSubStr := cond.Value.(string)
+			if !isSubStr {
+				return false, fmt.Errorf("%s condition on %s needs a string", cond.Op, cond.Field)
+			}
+			ok = isStr && strings.Contains(strings.ToLower(str), strings.ToLower(sub))
+		case db.OpAny, db.OpAll:
+			values, isArray := cond.Value.(primitive.A)
+			if !isArray || len(values) == 0 {
+				return false, fmt.Errorf("%s condition on %s needs strings", cond.Op, cond.Field)
+			}
+			elems, _ := value.(primitive.A)
+			found := 0
+			for _, v := range values {
+				for _, elem := range elems {
+					if compare(elem, v) == 0 {
+						found++
+						break
+					}
+				}
+			}
+			if cond.Op == db.OpAny {
+				ok = found > 0
+			} else {
+				ok = found == len(values)
+			}
+		case db.OpEmpty:
+			ok = value == nil || value == ""
+		default:
+			return false, fmt.Errorf("unsupported condition %s on %s", cond.Op, cond.Field)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compare orders bson values the way mongo does for the types entities use,
+// missing values come first.
+func compare(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			return compareInt(x, y)
+		}
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return compareInt(int64(x), int64(y))
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(x.Hex(), y.Hex())
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0
+			}
+			if !x {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+func compareInt(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func number(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// integer returns the value of an integer field, like mongo $sum sums
+// them.
+func integer(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	}
+	return 0, false
+}
+
+func toBsonValue(v any) (any, error) {
+	doc, err := toDoc(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	return doc["v"], nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mongoMigrationColl = "schema_migrations"
+
+// MongoMigration changes the documents of a mongo database the way sql
+// migrations change tables of the sql stores.
+type MongoMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, database *mongo.Database) error
+}
+
+// MigrateMongo applies every migration not yet recorded in the
+// schema_migrations collection of the database, in version order.
+// Migrations should be safe to run again, mongo has no transactions across
+// collections on standalone servers.
+func MigrateMongo(ctx context.Context, cl *mongo.Client, dbname string, migrations ...MongoMigration) error {
+	database := cl.Database(dbname)
+	coll := database.Collection(mongoMigrationColl)
+	for _, m := range migrations {
+		err := coll.FindOne(ctx, bson.M{"_id": m.Version}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if err := m.Up(ctx, database); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err = coll.UpdateOne(ctx,
+			bson.M{"_id": m.Version},
+			bson.M{"$set": bson.M{"name": m.Name}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewMoneyMinorUnitsMigration converts money of the moneyspends in collname
+// from floating point amounts to integer minor units of their currency.
+// Moneyspends without a currency get the default one.
+func NewMoneyMinorUnitsMigration(collname string) MongoMigration {
+	return MongoMigration{
+		Version: 1,
+		Name:    "money_minor_units",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			coll := database.Collection(collname)
+			_, err := coll.UpdateMany(ctx,
+				bson.M{"currency": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"currency": "USD"}},
+			)
+			if err != nil {
+				return err
+			}
+			// only doubles are converted, so the migration can run again
+			factor := bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": bson.M{"$in": bson.A{"$currency", bson.A{
+						"BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW",
+						"PYG", "RWF", "UGX", "VND", "VUV", "XAF", "XOF", "XPF",
+					}}}, "then": 1},
+					bson.M{"case": bson.M{"$in": bson.A{"$currency", bson.A{
+						"BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND",
+					}}}, "then": 1000},
+				},
+				"default": 100,
+			}}
+			_, err = coll.UpdateMany(ctx,
+				bson.M{"money": bson.M{"$type": "double"}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{
+					"money": bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$money", factor}}, 0}}},
+				}}}},
+			)
+			return err
+		},
+	}
+}
+
+// NewTimerOwnerIndexMigration makes the owner of the timers in collname
+// unique, so a user can't start two timers at once.
+func NewTimerOwnerIndexMigration(collname string) MongoMigration {
+	return MongoMigration{
+		Version: 2,
+		Name:    "timer_owner_index",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection(collname).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "ownerid", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	}
+}
+
+// NewInvoiceNumberIndexMigration makes the numbers of the invoices in
+// collname unique per owner, so concurrent invoices can't share a number.
+func NewInvoiceNumberIndexMigration(collname string) MongoMigration {
+	return MongoMigration{
+		Version: 3,
+		Name:    "invoice_number_index",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection(collname).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "ownerid", Value: 1}, {Key: "number", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	}
+}
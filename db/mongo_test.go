@@ -0,0 +1,141 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/storetest"
+	"github.com/SpectralJager/spender/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	mongoOnce   sync.Once
+	mongoClient *mongo.Client
+	mongoErr    error
+)
+
+// client returns a client of the server at SPENDER_TEST_MONGO, or on
+// localhost when it is unset, and skips the test when it isn't reachable.
+func client(t *testing.T) *mongo.Client {
+	t.Helper()
+	mongoOnce.Do(func() {
+		uri := os.Getenv("SPENDER_TEST_MONGO")
+		if len(uri) == 0 {
+			uri = "mongodb://localhost:27017"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		opts := options.Client().ApplyURI(uri).SetServerSelectionTimeout(2 * time.Second)
+		mongoClient, mongoErr = mongo.Connect(ctx, opts)
+		if mongoErr == nil {
+			mongoErr = mongoClient.Ping(ctx, nil)
+		}
+	})
+	if mongoErr != nil {
+		t.Skipf("mongo isn't reachable: %v", mongoErr)
+	}
+	return mongoClient
+}
+
+// database returns the name of a database dropped after the test.
+func database(t *testing.T, cl *mongo.Client) string {
+	t.Helper()
+	name := "spender_test_" + primitive.NewObjectID().Hex()
+	t.Cleanup(func() { cl.Database(name).Drop(context.Background()) })
+	return name
+}
+
+// crud returns the CRUD contract run on stores made by newStore on a fresh
+// database.
+func crud[T any, S db.BaseCRUDStore[T]](newStore func(*mongo.Client, string, string) S, collection string, fx storetest.CRUDFixture[T]) func(t *testing.T) {
+	return func(t *testing.T) {
+		cl := client(t)
+		storetest.RunCRUDStore(t, func(t *testing.T) db.BaseCRUDStore[T] {
+			return newStore(cl, database(t, cl), collection)
+		}, fx)
+	}
+}
+
+func TestMongoCRUDStores(t *testing.T) {
+	client(t)
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"Timespend", crud(db.NewMongoTimespendStore, "timespends", storetest.TimespendFixture())},
+		{"Moneyspend", crud(db.NewMongoMoneyspendStore, "moneyspends", storetest.MoneyspendFixture())},
+		{"Category", crud(db.NewMongoCategoryStore, "categories", storetest.CategoryFixture())},
+		{"Income", crud(db.NewMongoIncomeStore, "incomes", storetest.IncomeFixture())},
+		{"Account", crud(db.NewMongoAccountStore, "accounts", storetest.AccountFixture())},
+		{"Budget", crud(db.NewMongoBudgetStore, "budgets", storetest.BudgetFixture())},
+		{"Recurring", crud(db.NewMongoRecurringStore, "recurring", storetest.RecurringFixture())},
+		{"Project", crud(db.NewMongoProjectStore, "projects", storetest.ProjectFixture())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestMongoUserStore(t *testing.T) {
+	cl := client(t)
+	storetest.RunUserStore(t, func(t *testing.T) db.UserStore {
+		return db.NewMongoUserStore(cl, database(t, cl), "users")
+	})
+}
+
+func TestMongoQuery(t *testing.T) {
+	cl := client(t)
+	storetest.RunQuery(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return db.NewMongoTimespendStore(cl, database(t, cl), "timespends")
+	})
+}
+
+func TestMongoMoneyAggregate(t *testing.T) {
+	cl := client(t)
+	storetest.RunMoneyAggregate(t, func(t *testing.T) db.SpendStor[types.Moneyspend] {
+		return db.NewMongoMoneyspendStore(cl, database(t, cl), "moneyspends")
+	})
+}
+
+func TestMongoTimerStore(t *testing.T) {
+	cl := client(t)
+	storetest.RunTimerStore(t, func(t *testing.T) db.TimerStore {
+		dbname := database(t, cl)
+		if err := db.MigrateMongo(context.Background(), cl, dbname, db.NewTimerOwnerIndexMigration("timers")); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return db.NewMongoTimerStore(cl, dbname, "timers")
+	})
+}
+
+func TestMongoUpdateIf(t *testing.T) {
+	cl := client(t)
+	storetest.RunUpdateIf(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return db.NewMongoTimespendStore(cl, database(t, cl), "timespends")
+	})
+}
+
+func TestMongoInvoiceStore(t *testing.T) {
+	cl := client(t)
+	storetest.RunInvoiceStore(t, func(t *testing.T) db.InvoiceStore {
+		dbname := database(t, cl)
+		if err := db.MigrateMongo(context.Background(), cl, dbname, db.NewInvoiceNumberIndexMigration("invoices")); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return db.NewMongoInvoiceStore(cl, dbname, "invoices")
+	})
+}
+
+func TestMongoUpdateSets(t *testing.T) {
+	cl := client(t)
+	storetest.RunUpdateSets(t, func(t *testing.T) db.GroupStore {
+		return db.NewMongoGroupStore(cl, database(t, cl), "groups")
+	})
+}
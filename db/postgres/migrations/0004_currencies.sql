@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN "baseCurrency" TEXT NOT NULL DEFAULT 'USD';
+
+ALTER TABLE moneyspends ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+CREATE TABLE exchange_rates (
+	id TEXT PRIMARY KEY,
+	"from" TEXT NOT NULL,
+	"to" TEXT NOT NULL,
+	rate DOUBLE PRECISION NOT NULL,
+	date TIMESTAMPTZ NOT NULL,
+	UNIQUE ("from", "to", date)
+);
@@ -0,0 +1,6 @@
+-- money is kept in minor units of its currency, like cents of USD
+ALTER TABLE moneyspends ALTER COLUMN money TYPE BIGINT USING ROUND(money * CASE
+	WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1
+	WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+	ELSE 100
+END)::BIGINT;
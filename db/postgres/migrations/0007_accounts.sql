@@ -0,0 +1,34 @@
+CREATE TABLE accounts (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	currency TEXT NOT NULL,
+	"openingBalance" BIGINT NOT NULL,
+	"openingDate" TIMESTAMPTZ
+);
+
+CREATE INDEX accounts_owner ON accounts (ownerid);
+
+CREATE TABLE transfers (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	"fromAccountId" TEXT NOT NULL,
+	"toAccountId" TEXT NOT NULL,
+	money BIGINT NOT NULL,
+	"fromCurrency" TEXT NOT NULL,
+	"toMoney" BIGINT NOT NULL,
+	"toCurrency" TEXT NOT NULL,
+	date TIMESTAMPTZ NOT NULL,
+	note TEXT NOT NULL
+);
+
+CREATE INDEX transfers_owner_date ON transfers (ownerid, date);
+
+ALTER TABLE moneyspends ADD COLUMN "accountId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX moneyspends_owner_account ON moneyspends (ownerid, "accountId");
+
+ALTER TABLE incomes ADD COLUMN "accountId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX incomes_owner_account ON incomes (ownerid, "accountId");
@@ -0,0 +1,34 @@
+CREATE TABLE recurring (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	kind TEXT NOT NULL,
+	freq TEXT NOT NULL,
+	"interval" INTEGER NOT NULL,
+	"monthDay" INTEGER NOT NULL,
+	start TIMESTAMPTZ NOT NULL,
+	until TIMESTAMPTZ,
+	count INTEGER NOT NULL,
+	money BIGINT NOT NULL,
+	currency TEXT NOT NULL,
+	"accountId" TEXT NOT NULL,
+	duration BIGINT NOT NULL,
+	note TEXT NOT NULL,
+	"categoryId" TEXT NOT NULL,
+	tags TEXT,
+	skipped TEXT,
+	next TIMESTAMPTZ NOT NULL,
+	"nextIndex" INTEGER NOT NULL,
+	done BOOLEAN NOT NULL
+);
+
+CREATE INDEX recurring_owner ON recurring (ownerid);
+
+CREATE INDEX recurring_due ON recurring (done, next);
+
+ALTER TABLE moneyspends ADD COLUMN "recurringId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX moneyspends_recurring_date ON moneyspends ("recurringId", date);
+
+ALTER TABLE timespends ADD COLUMN "recurringId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX timespends_recurring_date ON timespends ("recurringId", date);
@@ -0,0 +1,10 @@
+CREATE TABLE timers (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	start TIMESTAMPTZ NOT NULL,
+	note TEXT NOT NULL,
+	"categoryId" TEXT NOT NULL,
+	tags TEXT
+);
+
+CREATE UNIQUE INDEX timers_owner ON timers (ownerid);
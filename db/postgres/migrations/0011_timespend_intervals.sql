@@ -0,0 +1,5 @@
+ALTER TABLE timespends ADD COLUMN start TIMESTAMPTZ;
+
+ALTER TABLE timespends ADD COLUMN "end" TIMESTAMPTZ;
+
+CREATE INDEX timespends_owner_start ON timespends (ownerid, start);
@@ -0,0 +1,25 @@
+CREATE TABLE invoices (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	number BIGINT NOT NULL,
+	client TEXT NOT NULL,
+	currency TEXT NOT NULL,
+	"hourlyRate" BIGINT NOT NULL,
+	start TIMESTAMPTZ NOT NULL,
+	"end" TIMESTAMPTZ NOT NULL,
+	"issuedAt" TIMESTAMPTZ NOT NULL,
+	lines TEXT,
+	duration BIGINT NOT NULL,
+	"timeTotal" BIGINT NOT NULL,
+	"expenseTotal" BIGINT NOT NULL,
+	total BIGINT NOT NULL,
+	document TEXT NOT NULL
+);
+
+CREATE INDEX invoices_owner ON invoices (ownerid);
+
+ALTER TABLE timespends ADD COLUMN "invoiceId" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE moneyspends ADD COLUMN "invoiceId" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE moneyspends ADD COLUMN reimbursable BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,13 @@
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL REFERENCES users (id),
+	"tokenHash" TEXT NOT NULL,
+	"usedHashes" TEXT,
+	"createdAt" TIMESTAMPTZ NOT NULL,
+	"refreshedAt" TIMESTAMPTZ NOT NULL,
+	"expiresAt" TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_owner ON sessions (ownerid);
+
+CREATE INDEX sessions_token ON sessions ("tokenHash");
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX invoices_number ON invoices (ownerid, number);
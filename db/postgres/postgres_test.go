@@ -0,0 +1,233 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/postgres"
+	"github.com/SpectralJager/spender/db/sqlstore"
+	"github.com/SpectralJager/spender/db/storetest"
+	"github.com/SpectralJager/spender/types"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+var (
+	// pgDSN is the database the tests run on, pgErr why there is none.
+	pgDSN string
+	pgErr error
+
+	pgOnce sync.Once
+	pgDB   *sql.DB
+)
+
+// TestMain runs the tests on the database at SPENDER_TEST_POSTGRES, or on a
+// server started for them when it is unset.
+func TestMain(m *testing.M) {
+	pgDSN = os.Getenv("SPENDER_TEST_POSTGRES")
+	if len(pgDSN) > 0 {
+		os.Exit(m.Run())
+	}
+	os.Exit(runEmbedded(m))
+}
+
+// runEmbedded runs the tests on a throwaway server, the postgres binaries
+// are downloaded on the first run and cached in the home directory.
+func runEmbedded(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "spender-postgres")
+	if err != nil {
+		pgErr = err
+		return m.Run()
+	}
+	defer os.RemoveAll(dir)
+	port, err := freePort()
+	if err != nil {
+		pgErr = err
+		return m.Run()
+	}
+	config := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(dir).
+		Logger(io.Discard)
+	server := embeddedpostgres.NewDatabase(config)
+	if err := server.Start(); err != nil {
+		pgErr = fmt.Errorf("start postgres: %w", err)
+		return m.Run()
+	}
+	defer server.Stop()
+	pgDSN = config.GetConnectionURL() + "?sslmode=disable"
+	return m.Run()
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// connect returns the migrated test database, and skips the test when no
+// server could be started. The database is shared by the tests, which
+// mustn't run in parallel.
+func connect(t *testing.T) *sql.DB {
+	t.Helper()
+	if pgErr != nil {
+		t.Skipf("postgres isn't available: %v", pgErr)
+	}
+	pgOnce.Do(func() {
+		pgDB, pgErr = postgres.Open(context.Background(), pgDSN)
+	})
+	if pgErr != nil {
+		t.Fatalf("open: %v", pgErr)
+	}
+	return pgDB
+}
+
+// open returns the database emptied of every row.
+func open(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB := connect(t)
+	tables := []string{}
+	for _, table := range []string{
+		postgres.TimespendTable, postgres.MoneyspendTable, postgres.IncomeTable, postgres.TransferTable,
+		postgres.AccountTable, postgres.BudgetTable, postgres.RecurringTable, postgres.TimerTable,
+		postgres.ProjectTable, postgres.InvoiceTable, postgres.SessionTable, postgres.APIKeyTable,
+		postgres.GroupExpenseTable, postgres.GroupTable, postgres.CategoryTable, postgres.ExchangeRateTable,
+		postgres.UserTable,
+	} {
+		tables = append(tables, `"`+table+`"`)
+	}
+	if _, err := sqlDB.Exec("TRUNCATE " + strings.Join(tables, ", ")); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	return sqlDB
+}
+
+// openWithOwners is open with the owners of the storetest suite registered,
+// since owned rows reference their user.
+func openWithOwners(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB := open(t)
+	for _, id := range []string{storetest.OwnerA, storetest.OwnerB} {
+		_, err := sqlDB.Exec(`INSERT INTO users (id, "firstName", "lastName", email, hpassword) VALUES ($1, 'John', 'Doe', $2, 'hash')`, id, id+"@storetest")
+		if err != nil {
+			t.Fatalf("insert owner: %v", err)
+		}
+	}
+	return sqlDB
+}
+
+// crud returns the CRUD contract run on stores made by newStore.
+func crud[T any, S db.BaseCRUDStore[T]](newStore func(*sql.DB) S, fx storetest.CRUDFixture[T]) func(t *testing.T) {
+	return func(t *testing.T) {
+		storetest.RunCRUDStore(t, func(t *testing.T) db.BaseCRUDStore[T] {
+			return newStore(openWithOwners(t))
+		}, fx)
+	}
+}
+
+func TestCRUDStores(t *testing.T) {
+	connect(t)
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"Timespend", crud(postgres.NewTimespendStore, storetest.TimespendFixture())},
+		{"Moneyspend", crud(postgres.NewMoneyspendStore, storetest.MoneyspendFixture())},
+		{"Category", crud(postgres.NewCategoryStore, storetest.CategoryFixture())},
+		{"Income", crud(postgres.NewIncomeStore, storetest.IncomeFixture())},
+		{"Account", crud(postgres.NewAccountStore, storetest.AccountFixture())},
+		{"Budget", crud(postgres.NewBudgetStore, storetest.BudgetFixture())},
+		{"Recurring", crud(postgres.NewRecurringStore, storetest.RecurringFixture())},
+		{"Project", crud(postgres.NewProjectStore, storetest.ProjectFixture())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	connect(t)
+	storetest.RunUserStore(t, func(t *testing.T) db.UserStore {
+		return postgres.NewUserStore(open(t))
+	})
+}
+
+func TestUserDelete(t *testing.T) {
+	connect(t)
+	storetest.RunUserDelete(t, func(t *testing.T) storetest.UserDelete {
+		sqlDB := open(t)
+		return storetest.UserDelete{
+			Users:   postgres.NewUserStore(sqlDB),
+			Failing: sqlstore.NewUserStore(sqlDB, postgres.Dialect, postgres.UserTable, append(slices.Clone(postgres.OwnedTables), "missing")...),
+			Tables:  postgres.OwnedTables,
+			Owned: storetest.OwnedStores{
+				Timespends:  postgres.NewTimespendStore(sqlDB),
+				Moneyspends: postgres.NewMoneyspendStore(sqlDB),
+				Incomes:     postgres.NewIncomeStore(sqlDB),
+				Transfers:   postgres.NewTransferStore(sqlDB),
+				Accounts:    postgres.NewAccountStore(sqlDB),
+				Budgets:     postgres.NewBudgetStore(sqlDB),
+				Recurring:   postgres.NewRecurringStore(sqlDB),
+				Timers:      postgres.NewTimerStore(sqlDB),
+				Projects:    postgres.NewProjectStore(sqlDB),
+				Invoices:    postgres.NewInvoiceStore(sqlDB),
+				Sessions:    postgres.NewSessionStore(sqlDB),
+				APIKeys:     postgres.NewAPIKeyStore(sqlDB),
+				Categories:  postgres.NewCategoryStore(sqlDB),
+			},
+		}
+	})
+}
+
+func TestQuery(t *testing.T) {
+	connect(t)
+	storetest.RunQuery(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return postgres.NewTimespendStore(openWithOwners(t))
+	})
+}
+
+func TestMoneyAggregate(t *testing.T) {
+	connect(t)
+	storetest.RunMoneyAggregate(t, func(t *testing.T) db.SpendStor[types.Moneyspend] {
+		return postgres.NewMoneyspendStore(openWithOwners(t))
+	})
+}
+
+func TestTimerStore(t *testing.T) {
+	connect(t)
+	storetest.RunTimerStore(t, func(t *testing.T) db.TimerStore {
+		return postgres.NewTimerStore(openWithOwners(t))
+	})
+}
+
+func TestUpdateIf(t *testing.T) {
+	connect(t)
+	storetest.RunUpdateIf(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return postgres.NewTimespendStore(openWithOwners(t))
+	})
+}
+
+func TestInvoiceStore(t *testing.T) {
+	connect(t)
+	storetest.RunInvoiceStore(t, func(t *testing.T) db.InvoiceStore {
+		return postgres.NewInvoiceStore(openWithOwners(t))
+	})
+}
+
+func TestUpdateSets(t *testing.T) {
+	connect(t)
+	storetest.RunUpdateSets(t, func(t *testing.T) db.GroupStore {
+		return postgres.NewGroupStore(openWithOwners(t))
+	})
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	IDField = "_id"
+
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+type Op string
+
+const (
+	OpEq  Op = "eq"
+	OpGt  Op = "gt"
+	OpGte Op = "gte"
+	OpLt  Op = "lt"
+	OpLte Op = "lte"
+	// OpContains matches string fields containing the value, ignoring case.
+	OpContains Op = "contains"
+	// OpAny matches array fields holding any of the []string value.
+	OpAny Op = "any"
+	// OpAll matches array fields holding all of the []string value.
+	OpAll Op = "all"
+	// OpEmpty matches string fields that are empty or unset, the value is
+	// ignored.
+	OpEmpty Op = "empty"
+)
+
+// Cond is a condition on an entity field, named by its bson key.
+type Cond struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+type Query struct {
+	Conds []Cond
+	// Sort is the field entities are ordered by, ties are broken by ID.
+	// Entities are ordered by ID when it is empty.
+	Sort  string
+	Desc  bool
+	Limit int
+	// After is the Next cursor of the previous page.
+	After string
+}
+
+func (q Query) SortField() string {
+	if len(q.Sort) == 0 {
+		return IDField
+	}
+	return q.Sort
+}
+
+func (q Query) PageLimit() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	return min(q.Limit, MaxLimit)
+}
+
+type Page[T any] struct {
+	Items []T
+	// Next is the cursor of the following page, empty on the last one.
+	Next string
+}
+
+type QueryStorer[T any] interface {
+	Query(ctx context.Context, q Query) (Page[T], error)
+}
+
+// Cursor points right after the last entity of a page. It keeps the sort it
+// was made for, so it can't be replayed against another ordering.
+type Cursor struct {
+	Sort  string
+	Desc  bool
+	Value any
+	ID    string
+}
+
+type cursorJSON struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (c Cursor) Encode() (string, error) {
+	enc := cursorJSON{
+		Sort: c.Sort,
+		Desc: c.Desc,
+		ID:   c.ID,
+	}
+	switch v := c.Value.(type) {
+	case time.Time:
+		enc.Kind, enc.Value = "time", v.UTC().Format(time.RFC3339Nano)
+	case int64:
+		enc.Kind, enc.Value = "int", strconv.FormatInt(v, 10)
+	case float64:
+		enc.Kind, enc.Value = "float", strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		enc.Kind, enc.Value = "string", v
+	case nil:
+		enc.Kind = "null"
+	default:
+		return "", fmt.Errorf("unsupported cursor value %T", c.Value)
+	}
+	data, err := json.Marshal(enc)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes the After cursor of q, it returns false for the first
+// page.
+func DecodeCursor(q Query) (Cursor, bool, error) {
+	if len(q.After) == 0 {
+		return Cursor{}, false, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.After)
+	if err != nil {
+		return Cursor{}, false, fmt.Errorf("invalid cursor")
+	}
+	var enc cursorJSON
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return Cursor{}, false, fmt.Errorf("invalid cursor")
+	}
+	if enc.Sort != q.SortField() || enc.Desc != q.Desc {
+		return Cursor{}, false, fmt.Errorf("cursor doesn't match the sort order")
+	}
+	c := Cursor{
+		Sort: enc.Sort,
+		Desc: enc.Desc,
+		ID:   enc.ID,
+	}
+	switch enc.Kind {
+	case "time":
+		c.Value, err = time.Parse(time.RFC3339Nano, enc.Value)
+	case "int":
+		c.Value, err = strconv.ParseInt(enc.Value, 10, 64)
+	case "float":
+		c.Value, err = strconv.ParseFloat(enc.Value, 64)
+	case "string":
+		c.Value = enc.Value
+	case "null":
+	default:
+		err = fmt.Errorf("unknown kind %q", enc.Kind)
+	}
+	if err != nil {
+		return Cursor{}, false, fmt.Errorf("invalid cursor")
+	}
+	return c, true, nil
+}
+
+// NewPage trims items fetched with one extra entity beyond the limit of q
+// and makes the cursor of the next page from the last kept entity.
+func NewPage[T any](items []T, q Query) (Page[T], error) {
+	limit := q.PageLimit()
+	if len(items) <= limit {
+		return Page[T]{Items: items}, nil
+	}
+	items = items[:limit]
+	doc, err := utils.ToBsonDoc(items[limit-1])
+	if err != nil {
+		return Page[T]{}, err
+	}
+	c := Cursor{
+		Sort: q.SortField(),
+		Desc: q.Desc,
+	}
+	for _, elem := range *doc {
+		if elem.Key == c.Sort {
+			c.Value = GoValue(elem.Value)
+		}
+		if elem.Key == IDField {
+			c.ID, _ = GoValue(elem.Value).(string)
+		}
+	}
+	next, err := c.Encode()
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return Page[T]{Items: items, Next: next}, nil
+}
+
+// GoValue converts a bson value to the go type kept in cursors and aggregate
+// groups.
+func GoValue(v any) any {
+	switch x := v.(type) {
+	case primitive.DateTime:
+		return x.Time().UTC()
+	case time.Time:
+		return x.UTC()
+	case int32:
+		return int64(x)
+	case int:
+		return int64(x)
+	case time.Duration:
+		return int64(x)
+	case primitive.ObjectID:
+		return x.Hex()
+	}
+	return v
+}
@@ -0,0 +1,27 @@
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	"firstName" TEXT NOT NULL,
+	"lastName" TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	hpassword TEXT NOT NULL
+);
+
+CREATE TABLE timespends (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	duration INTEGER NOT NULL,
+	date INTEGER NOT NULL,
+	note TEXT NOT NULL
+);
+
+CREATE INDEX timespends_owner_date ON timespends (ownerid, date);
+
+CREATE TABLE moneyspends (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	money REAL NOT NULL,
+	date INTEGER NOT NULL,
+	note TEXT NOT NULL
+);
+
+CREATE INDEX moneyspends_owner_date ON moneyspends (ownerid, date);
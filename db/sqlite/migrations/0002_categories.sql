@@ -0,0 +1,18 @@
+CREATE TABLE categories (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	name TEXT NOT NULL,
+	"parentId" TEXT NOT NULL,
+	color TEXT NOT NULL,
+	icon TEXT NOT NULL
+);
+
+CREATE INDEX categories_owner ON categories (ownerid);
+
+ALTER TABLE timespends ADD COLUMN "categoryId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX timespends_owner_category ON timespends (ownerid, "categoryId");
+
+ALTER TABLE moneyspends ADD COLUMN "categoryId" TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX moneyspends_owner_category ON moneyspends (ownerid, "categoryId");
@@ -0,0 +1,3 @@
+ALTER TABLE timespends ADD COLUMN tags TEXT;
+
+ALTER TABLE moneyspends ADD COLUMN tags TEXT;
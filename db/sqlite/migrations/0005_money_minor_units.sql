@@ -0,0 +1,12 @@
+-- money is kept in minor units of its currency, like cents of USD
+ALTER TABLE moneyspends RENAME COLUMN money TO money_real;
+
+ALTER TABLE moneyspends ADD COLUMN money INTEGER NOT NULL DEFAULT 0;
+
+UPDATE moneyspends SET money = CAST(ROUND(money_real * CASE
+	WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1
+	WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+	ELSE 100
+END) AS INTEGER);
+
+ALTER TABLE moneyspends DROP COLUMN money_real;
@@ -0,0 +1,14 @@
+CREATE TABLE incomes (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	money INTEGER NOT NULL,
+	currency TEXT NOT NULL,
+	date INTEGER NOT NULL,
+	note TEXT NOT NULL,
+	"categoryId" TEXT NOT NULL DEFAULT '',
+	tags TEXT
+);
+
+CREATE INDEX incomes_owner_date ON incomes (ownerid, date);
+
+CREATE INDEX incomes_owner_category ON incomes (ownerid, "categoryId");
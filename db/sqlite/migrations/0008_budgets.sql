@@ -0,0 +1,14 @@
+CREATE TABLE budgets (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	period TEXT NOT NULL,
+	money INTEGER NOT NULL,
+	currency TEXT NOT NULL,
+	duration INTEGER NOT NULL,
+	"categoryId" TEXT NOT NULL,
+	keyword TEXT NOT NULL
+);
+
+CREATE INDEX budgets_owner ON budgets (ownerid);
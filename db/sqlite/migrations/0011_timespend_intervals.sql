@@ -0,0 +1,5 @@
+ALTER TABLE timespends ADD COLUMN start INTEGER;
+
+ALTER TABLE timespends ADD COLUMN "end" INTEGER;
+
+CREATE INDEX timespends_owner_start ON timespends (ownerid, start);
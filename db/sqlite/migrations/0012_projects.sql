@@ -0,0 +1,16 @@
+CREATE TABLE projects (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	name TEXT NOT NULL,
+	client TEXT NOT NULL,
+	"hourlyRate" INTEGER NOT NULL,
+	currency TEXT NOT NULL
+);
+
+CREATE INDEX projects_owner ON projects (ownerid);
+
+ALTER TABLE timespends ADD COLUMN "projectId" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE timespends ADD COLUMN billable INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX timespends_owner_project ON timespends (ownerid, "projectId");
@@ -0,0 +1,25 @@
+CREATE TABLE invoices (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	number INTEGER NOT NULL,
+	client TEXT NOT NULL,
+	currency TEXT NOT NULL,
+	"hourlyRate" INTEGER NOT NULL,
+	start INTEGER NOT NULL,
+	"end" INTEGER NOT NULL,
+	"issuedAt" INTEGER NOT NULL,
+	lines TEXT,
+	duration INTEGER NOT NULL,
+	"timeTotal" INTEGER NOT NULL,
+	"expenseTotal" INTEGER NOT NULL,
+	total INTEGER NOT NULL,
+	document TEXT NOT NULL
+);
+
+CREATE INDEX invoices_owner ON invoices (ownerid);
+
+ALTER TABLE timespends ADD COLUMN "invoiceId" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE moneyspends ADD COLUMN "invoiceId" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE moneyspends ADD COLUMN reimbursable INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,24 @@
+CREATE TABLE "groups" (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	currency TEXT NOT NULL,
+	"createdBy" TEXT NOT NULL,
+	members TEXT,
+	invited TEXT
+);
+
+CREATE TABLE group_expenses (
+	id TEXT PRIMARY KEY,
+	groupid TEXT NOT NULL,
+	"createdBy" TEXT NOT NULL,
+	"payerId" TEXT NOT NULL,
+	money INTEGER NOT NULL,
+	currency TEXT NOT NULL,
+	date INTEGER NOT NULL,
+	note TEXT NOT NULL,
+	split TEXT NOT NULL,
+	shares TEXT,
+	settlement INTEGER NOT NULL
+);
+
+CREATE INDEX group_expenses_group_date ON group_expenses (groupid, date);
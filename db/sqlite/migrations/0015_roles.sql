@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+
+ALTER TABLE users ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	"tokenHash" TEXT NOT NULL,
+	"usedHashes" TEXT,
+	"createdAt" INTEGER NOT NULL,
+	"refreshedAt" INTEGER NOT NULL,
+	"expiresAt" INTEGER NOT NULL
+);
+
+CREATE INDEX sessions_owner ON sessions (ownerid);
+
+CREATE INDEX sessions_token ON sessions ("tokenHash");
@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+	id TEXT PRIMARY KEY,
+	ownerid TEXT NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	"tokenHash" TEXT NOT NULL,
+	scopes TEXT,
+	"createdAt" INTEGER NOT NULL,
+	"expiresAt" INTEGER
+);
+
+CREATE INDEX api_keys_owner ON api_keys (ownerid);
+
+CREATE UNIQUE INDEX api_keys_token ON api_keys ("tokenHash");
@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/sqlstore"
+	"github.com/SpectralJager/spender/types"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	UserTable         = "users"
+	TimespendTable    = "timespends"
+	MoneyspendTable   = "moneyspends"
+	IncomeTable       = "incomes"
+	AccountTable      = "accounts"
+	TransferTable     = "transfers"
+	BudgetTable       = "budgets"
+	RecurringTable    = "recurring"
+	TimerTable        = "timers"
+	SessionTable      = "sessions"
+	APIKeyTable       = "api_keys"
+	ProjectTable      = "projects"
+	InvoiceTable      = "invoices"
+	GroupTable        = "groups"
+	GroupExpenseTable = "group_expenses"
+	CategoryTable     = "categories"
+	ExchangeRateTable = "exchange_rates"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Dialect stores times as unix milliseconds, which keeps range comparisons
+// and aggregates on plain integers.
+var Dialect = sqlstore.Dialect{
+	Name: "sqlite",
+	Placeholder: func(int) string {
+		return "?"
+	},
+	Time: func(t time.Time) any {
+		return t.UnixMilli()
+	},
+	NotDistinct: func(left, right string) string {
+		return left + " IS " + right
+	},
+	JSONElements: func(column string) string {
+		return "json_each(" + column + ") AS elements"
+	},
+}
+
+// Open opens the database file at path, creating it if needed, and applies
+// pending schema migrations.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	path = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite serializes writers anyway, a single connection avoids busy errors
+	db.SetMaxOpenConns(1)
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func Migrate(ctx context.Context, db *sql.DB) error {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	migrations, err := sqlstore.LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+	return sqlstore.Migrate(ctx, db, Dialect, migrations)
+}
+
+// OwnedTables are the tables of entities deleted with their owner.
+var OwnedTables = []string{TimespendTable, MoneyspendTable, IncomeTable, TransferTable, AccountTable, BudgetTable, RecurringTable, TimerTable, ProjectTable, InvoiceTable, SessionTable, APIKeyTable, CategoryTable}
+
+func NewUserStore(db *sql.DB) sqlstore.UserStore {
+	return sqlstore.NewUserStore(db, Dialect, UserTable, OwnedTables...)
+}
+
+func NewTimespendStore(db *sql.DB) *sqlstore.Store[types.Timespend] {
+	return sqlstore.NewStore[types.Timespend](db, Dialect, TimespendTable, true)
+}
+
+func NewMoneyspendStore(db *sql.DB) *sqlstore.Store[types.Moneyspend] {
+	return sqlstore.NewStore[types.Moneyspend](db, Dialect, MoneyspendTable, true)
+}
+
+func NewIncomeStore(db *sql.DB) *sqlstore.Store[types.Income] {
+	return sqlstore.NewStore[types.Income](db, Dialect, IncomeTable, true)
+}
+
+func NewAccountStore(db *sql.DB) *sqlstore.Store[types.Account] {
+	return sqlstore.NewStore[types.Account](db, Dialect, AccountTable, true)
+}
+
+func NewTransferStore(db *sql.DB) *sqlstore.Store[types.Transfer] {
+	return sqlstore.NewStore[types.Transfer](db, Dialect, TransferTable, true)
+}
+
+func NewBudgetStore(db *sql.DB) *sqlstore.Store[types.Budget] {
+	return sqlstore.NewStore[types.Budget](db, Dialect, BudgetTable, true)
+}
+
+func NewRecurringStore(db *sql.DB) *sqlstore.Store[types.Recurring] {
+	return sqlstore.NewStore[types.Recurring](db, Dialect, RecurringTable, true)
+}
+
+func NewProjectStore(db *sql.DB) *sqlstore.Store[types.Project] {
+	return sqlstore.NewStore[types.Project](db, Dialect, ProjectTable, true)
+}
+
+func NewInvoiceStore(db *sql.DB) *sqlstore.Store[types.Invoice] {
+	return sqlstore.NewStore[types.Invoice](db, Dialect, InvoiceTable, true)
+}
+
+// NewGroupStore returns the store of groups shared by their members.
+func NewGroupStore(db *sql.DB) *sqlstore.Store[types.Group] {
+	return sqlstore.NewStore[types.Group](db, Dialect, GroupTable, false)
+}
+
+// NewGroupExpenseStore returns the store of group expenses, restricted to
+// the group of the scope.
+func NewGroupExpenseStore(sqlDB *sql.DB) *sqlstore.Store[types.GroupExpense] {
+	return sqlstore.NewScopedStore[types.GroupExpense](sqlDB, Dialect, GroupExpenseTable, db.GroupScoped)
+}
+
+func NewTimerStore(db *sql.DB) *sqlstore.Store[types.Timer] {
+	return sqlstore.NewStore[types.Timer](db, Dialect, TimerTable, true)
+}
+
+func NewSessionStore(db *sql.DB) *sqlstore.Store[types.Session] {
+	return sqlstore.NewStore[types.Session](db, Dialect, SessionTable, true)
+}
+
+func NewAPIKeyStore(db *sql.DB) *sqlstore.Store[types.APIKey] {
+	return sqlstore.NewStore[types.APIKey](db, Dialect, APIKeyTable, true)
+}
+
+func NewCategoryStore(db *sql.DB) *sqlstore.Store[types.Category] {
+	return sqlstore.NewStore[types.Category](db, Dialect, CategoryTable, true)
+}
+
+func NewExchangeRateStore(db *sql.DB) *sqlstore.Store[types.ExchangeRate] {
+	return sqlstore.NewStore[types.ExchangeRate](db, Dialect, ExchangeRateTable, false)
+}
@@ -0,0 +1,123 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/db/sqlite"
+	"github.com/SpectralJager/spender/db/sqlstore"
+	"github.com/SpectralJager/spender/db/storetest"
+	"github.com/SpectralJager/spender/types"
+)
+
+// open returns a migrated database in memory, Open keeps a single connection
+// so it lives until the test closes it.
+func open(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := sqlite.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+// crud returns the CRUD contract run on stores made by newStore on a fresh
+// database.
+func crud[T any, S db.BaseCRUDStore[T]](newStore func(*sql.DB) S, fx storetest.CRUDFixture[T]) func(t *testing.T) {
+	return func(t *testing.T) {
+		storetest.RunCRUDStore(t, func(t *testing.T) db.BaseCRUDStore[T] {
+			return newStore(open(t))
+		}, fx)
+	}
+}
+
+func TestCRUDStores(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"Timespend", crud(sqlite.NewTimespendStore, storetest.TimespendFixture())},
+		{"Moneyspend", crud(sqlite.NewMoneyspendStore, storetest.MoneyspendFixture())},
+		{"Category", crud(sqlite.NewCategoryStore, storetest.CategoryFixture())},
+		{"Income", crud(sqlite.NewIncomeStore, storetest.IncomeFixture())},
+		{"Account", crud(sqlite.NewAccountStore, storetest.AccountFixture())},
+		{"Budget", crud(sqlite.NewBudgetStore, storetest.BudgetFixture())},
+		{"Recurring", crud(sqlite.NewRecurringStore, storetest.RecurringFixture())},
+		{"Project", crud(sqlite.NewProjectStore, storetest.ProjectFixture())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	storetest.RunUserStore(t, func(t *testing.T) db.UserStore {
+		return sqlite.NewUserStore(open(t))
+	})
+}
+
+func TestUserDelete(t *testing.T) {
+	storetest.RunUserDelete(t, func(t *testing.T) storetest.UserDelete {
+		sqlDB := open(t)
+		return storetest.UserDelete{
+			Users:   sqlite.NewUserStore(sqlDB),
+			Failing: sqlstore.NewUserStore(sqlDB, sqlite.Dialect, sqlite.UserTable, append(slices.Clone(sqlite.OwnedTables), "missing")...),
+			Tables:  sqlite.OwnedTables,
+			Owned: storetest.OwnedStores{
+				Timespends:  sqlite.NewTimespendStore(sqlDB),
+				Moneyspends: sqlite.NewMoneyspendStore(sqlDB),
+				Incomes:     sqlite.NewIncomeStore(sqlDB),
+				Transfers:   sqlite.NewTransferStore(sqlDB),
+				Accounts:    sqlite.NewAccountStore(sqlDB),
+				Budgets:     sqlite.NewBudgetStore(sqlDB),
+				Recurring:   sqlite.NewRecurringStore(sqlDB),
+				Timers:      sqlite.NewTimerStore(sqlDB),
+				Projects:    sqlite.NewProjectStore(sqlDB),
+				Invoices:    sqlite.NewInvoiceStore(sqlDB),
+				Sessions:    sqlite.NewSessionStore(sqlDB),
+				APIKeys:     sqlite.NewAPIKeyStore(sqlDB),
+				Categories:  sqlite.NewCategoryStore(sqlDB),
+			},
+		}
+	})
+}
+
+func TestQuery(t *testing.T) {
+	storetest.RunQuery(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return sqlite.NewTimespendStore(open(t))
+	})
+}
+
+func TestMoneyAggregate(t *testing.T) {
+	storetest.RunMoneyAggregate(t, func(t *testing.T) db.SpendStor[types.Moneyspend] {
+		return sqlite.NewMoneyspendStore(open(t))
+	})
+}
+
+func TestTimerStore(t *testing.T) {
+	storetest.RunTimerStore(t, func(t *testing.T) db.TimerStore {
+		return sqlite.NewTimerStore(open(t))
+	})
+}
+
+func TestUpdateIf(t *testing.T) {
+	storetest.RunUpdateIf(t, func(t *testing.T) db.SpendStor[types.Timespend] {
+		return sqlite.NewTimespendStore(open(t))
+	})
+}
+
+func TestInvoiceStore(t *testing.T) {
+	storetest.RunInvoiceStore(t, func(t *testing.T) db.InvoiceStore {
+		return sqlite.NewInvoiceStore(open(t))
+	})
+}
+
+func TestUpdateSets(t *testing.T) {
+	storetest.RunUpdateSets(t, func(t *testing.T) db.GroupStore {
+		return sqlite.NewGroupStore(open(t))
+	})
+}
@@ -0,0 +1,112 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/SpectralJager/spender/db"
+)
+
+func (st *Store[T]) Aggregate(ctx context.Context, a db.Aggregation) ([]db.Aggregate, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	field, err := st.column(a.Field)
+	if err != nil {
+		return nil, err
+	}
+	q := st.query()
+	group := "NULL"
+	if len(a.GroupBy) != 0 {
+		group, err = st.column(a.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// buckets are numbered by a CASE over the boundaries, the number is mapped
+	// back to the bucket start after the query
+	bucket := "NULL"
+	conds := a.Conds
+	if len(a.BucketBy) != 0 {
+		column, err := st.column(a.BucketBy)
+		if err != nil {
+			return nil, err
+		}
+		var cases strings.Builder
+		cases.WriteString("CASE")
+		for i, boundary := range a.Buckets[1:] {
+			value, err := st.encodeValue(boundary)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&cases, " WHEN %s < %s THEN %d", column, q.arg(value), i)
+		}
+		cases.WriteString(" END")
+		bucket = cases.String()
+		conds = append(conds,
+			db.Cond{Field: a.BucketBy, Op: db.OpGte, Value: a.Buckets[0]},
+			db.Cond{Field: a.BucketBy, Op: db.OpLt, Value: a.Buckets[len(a.Buckets)-1]},
+		)
+	}
+	where, err := st.scope(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	condsWhere, err := st.conds(q, conds)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, condsWhere...)
+	from := quote(st.table)
+	if a.Unwind {
+		// filtering in a subquery keeps entity columns apart from the columns
+		// of the elements table
+		from = fmt.Sprintf("(SELECT * FROM %s%s) AS %s, %s",
+			quote(st.table), whereClause(where), entitiesAlias, st.dialect.JSONElements(entitiesAlias+"."+group),
+		)
+		where = nil
+		field = entitiesAlias + "." + field
+		group = elementsValue
+	}
+	stmt := fmt.Sprintf("SELECT %s, %s, COUNT(*), COALESCE(SUM(%s), 0), MIN(%s), MAX(%s), AVG(%s) FROM %s%s",
+		group, bucket, field, field, field, field, from, whereClause(where),
+	)
+	if a.Grouped() {
+		stmt += " GROUP BY 1, 2 ORDER BY 1, 2"
+	}
+	rows, err := st.db.QueryContext(ctx, stmt, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aggregates := []db.Aggregate{}
+	for rows.Next() {
+		var (
+			aggregate db.Aggregate
+			values    = make([]any, 7)
+		)
+		if err := rows.Scan(&aggregate.Group, &values[1], &values[2], &values[3], &values[4], &values[5], &values[6]); err != nil {
+			return nil, err
+		}
+		if b, ok := aggregate.Group.([]byte); ok {
+			aggregate.Group = string(b)
+		}
+		if len(a.BucketBy) != 0 {
+			var i int64
+			if err := decode(values[1], reflect.ValueOf(&i).Elem()); err != nil {
+				return nil, err
+			}
+			aggregate.Bucket = a.Buckets[i].UTC()
+		}
+		dest := []any{nil, nil, &aggregate.Count, &aggregate.Sum, &aggregate.Min, &aggregate.Max, &aggregate.Avg}
+		for i := 2; i < len(values); i++ {
+			if err := decode(values[i], reflect.ValueOf(dest[i]).Elem()); err != nil {
+				return nil, err
+			}
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	return aggregates, rows.Err()
+}
@@ -0,0 +1,179 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// encode converts a struct field to a driver value. Zero times and nil
+// pointers, slices and maps are stored as NULL.
+func (st *Store[T]) encode(v reflect.Value) (any, error) {
+	switch v.Type() {
+	case timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil, nil
+		}
+		return st.dialect.Time(t.UTC().Truncate(time.Millisecond)), nil
+	case durationType:
+		return v.Int(), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return st.encode(v.Elem())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		fallthrough
+	case reflect.Array, reflect.Struct:
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// decode stores a scanned driver value into dst.
+func decode(src any, dst reflect.Value) error {
+	if src == nil {
+		dst.SetZero()
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		elem := reflect.New(dst.Type().Elem())
+		if err := decode(src, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	if dst.Type() == timeType {
+		t, err := decodeTime(src)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.String:
+		switch x := src.(type) {
+		case string:
+			dst.SetString(x)
+			return nil
+		case []byte:
+			dst.SetString(string(x))
+			return nil
+		}
+	case reflect.Bool:
+		switch x := src.(type) {
+		case bool:
+			dst.SetBool(x)
+			return nil
+		case int64:
+			dst.SetBool(x != 0)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := decodeInt(src)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := decodeInt(src)
+		if err != nil {
+			return err
+		}
+		dst.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch x := src.(type) {
+		case float64:
+			dst.SetFloat(x)
+			return nil
+		case int64:
+			dst.SetFloat(float64(x))
+			return nil
+		case []byte:
+			f, err := strconv.ParseFloat(string(x), 64)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+			return nil
+		case string:
+			f, err := strconv.ParseFloat(x, 64)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Struct:
+		var data []byte
+		switch x := src.(type) {
+		case string:
+			data = []byte(x)
+		case []byte:
+			data = x
+		default:
+			return fmt.Errorf("can't decode %T into %s", src, dst.Type())
+		}
+		return json.Unmarshal(data, dst.Addr().Interface())
+	}
+	return fmt.Errorf("can't decode %T into %s", src, dst.Type())
+}
+
+func decodeInt(src any) (int64, error) {
+	switch x := src.(type) {
+	case int64:
+		return x, nil
+	case float64:
+		return int64(x), nil
+	case []byte:
+		return strconv.ParseInt(string(x), 10, 64)
+	case string:
+		return strconv.ParseInt(x, 10, 64)
+	}
+	return 0, fmt.Errorf("can't decode %T into integer", src)
+}
+
+func decodeTime(src any) (time.Time, error) {
+	switch x := src.(type) {
+	case time.Time:
+		return x.UTC(), nil
+	case int64:
+		return time.UnixMilli(x).UTC(), nil
+	case string:
+		return time.Parse(time.RFC3339Nano, x)
+	case []byte:
+		return time.Parse(time.RFC3339Nano, string(x))
+	}
+	return time.Time{}, fmt.Errorf("can't decode %T into time", src)
+}
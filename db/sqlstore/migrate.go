@@ -0,0 +1,98 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+}
+
+// LoadMigrations reads the *.sql files of fsys named <version>_<name>.sql.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := []Migration{}
+	for _, path := range paths {
+		name := strings.TrimSuffix(path, ".sql")
+		prefix, name, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: version should be a number", path)
+		}
+		up, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			Up:      string(up),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate applies every migration newer than the schema version, each one
+// in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect, migrations []Migration) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	var current int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := migrate(ctx, db, dialect, m); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, dialect Dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return err
+	}
+	q := &query{dialect: dialect}
+	_, err = tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%s, %s)", q.arg(m.Version), q.arg(m.Name)),
+		q.args...,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
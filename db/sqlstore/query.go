@@ -0,0 +1,139 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/SpectralJager/spender/db"
+)
+
+func (st *Store[T]) Query(ctx context.Context, q db.Query) (db.Page[T], error) {
+	sq := st.query()
+	where, err := st.scope(ctx, sq)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	conds, err := st.conds(sq, q.Conds)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	where = append(where, conds...)
+
+	sort, err := st.column(q.SortField())
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	cursor, after, err := db.DecodeCursor(q)
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if after {
+		if sort == quote(idColumn) {
+			where = append(where, quote(idColumn)+" "+cmp+" "+sq.arg(cursor.ID))
+		} else {
+			value, err := st.encodeValue(cursor.Value)
+			if err != nil {
+				return db.Page[T]{}, err
+			}
+			greater := sort + " " + cmp + " " + sq.arg(value)
+			equal := sort + " = " + sq.arg(value)
+			where = append(where, fmt.Sprintf("(%s OR (%s AND %s %s %s))",
+				greater, equal, quote(idColumn), cmp, sq.arg(cursor.ID),
+			))
+		}
+	}
+	orderBy := quote(idColumn) + " " + dir
+	if sort != quote(idColumn) {
+		orderBy = sort + " " + dir + ", " + orderBy
+	}
+	entities, err := st.selectAll(ctx, sq, where, fmt.Sprintf("%s LIMIT %d", orderBy, q.PageLimit()+1))
+	if err != nil {
+		return db.Page[T]{}, err
+	}
+	return db.NewPage(entities, q)
+}
+
+func (st *Store[T]) conds(q *query, conds []db.Cond) ([]string, error) {
+	where := []string{}
+	for _, cond := range conds {
+		column, err := st.column(cond.Field)
+		if err != nil {
+			return nil, err
+		}
+		switch cond.Op {
+		case db.OpEq, db.OpGt, db.OpGte, db.OpLt, db.OpLte:
+			value, err := st.encodeValue(cond.Value)
+			if err != nil {
+				return nil, err
+			}
+			where = append(where, column+" "+operators[cond.Op]+" "+q.arg(value))
+		case db.OpContains:
+			str, ok := cond.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s condition on %s needs a string", cond.Op, cond.Field)
+			}
+			pattern := "%" + likeEscaper.Replace(strings.ToLower(str)) + "%"
+			where = append(where, "LOWER("+column+") LIKE "+q.arg(pattern)+` ESCAPE '\'`)
+		case db.OpAny, db.OpAll:
+			values, ok := cond.Value.([]string)
+			if !ok || len(values) == 0 {
+				return nil, fmt.Errorf("%s condition on %s needs strings", cond.Op, cond.Field)
+			}
+			seen := map[string]bool{}
+			args := []string{}
+			for _, value := range values {
+				if !seen[value] {
+					seen[value] = true
+					args = append(args, q.arg(value))
+				}
+			}
+			in := fmt.Sprintf("FROM %s WHERE %s IN (%s)", st.dialect.JSONElements(column), elementsValue, strings.Join(args, ", "))
+			if cond.Op == db.OpAny {
+				where = append(where, "EXISTS (SELECT 1 "+in+")")
+			} else {
+				where = append(where, fmt.Sprintf("(SELECT COUNT(DISTINCT %s) %s) = %d", elementsValue, in, len(args)))
+			}
+		case db.OpEmpty:
+			where = append(where, "("+column+" IS NULL OR "+column+" = '')")
+		default:
+			return nil, fmt.Errorf("unsupported condition %s on %s", cond.Op, cond.Field)
+		}
+	}
+	return where, nil
+}
+
+var (
+	operators = map[db.Op]string{
+		db.OpEq:  "=",
+		db.OpGt:  ">",
+		db.OpGte: ">=",
+		db.OpLt:  "<",
+		db.OpLte: "<=",
+	}
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+)
+
+// column returns the quoted column of a bson field name, it is the only way
+// field names get into statements.
+func (st *Store[T]) column(name string) (string, error) {
+	if name == db.IDField {
+		return quote(idColumn), nil
+	}
+	if _, ok := st.field(name); !ok {
+		return "", fmt.Errorf("unknown field %s", name)
+	}
+	return quote(name), nil
+}
+
+func (st *Store[T]) encodeValue(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return st.encode(reflect.ValueOf(v))
+}
@@ -0,0 +1,502 @@
+// Package sqlstore implements the db store interfaces on top of database/sql.
+// Entities are mapped to table columns by their bson field names, so the same
+// types and updaters work for mongo and sql backends.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	idColumn    = "id"
+	ownerColumn = "ownerid"
+
+	entitiesAlias = "entities"
+	elementsValue = "elements.value"
+)
+
+type Dialect struct {
+	Name string
+	// Placeholder returns the bind parameter of the n-th query argument,
+	// counting from 1.
+	Placeholder func(n int) string
+	// Time converts a time to the value stored in time columns.
+	Time func(t time.Time) any
+	// NotDistinct returns a null-safe equality test of two expressions.
+	NotDistinct func(left, right string) string
+	// JSONElements returns a table expression aliased elements, with the
+	// elements of the json array in column as its value column.
+	JSONElements func(column string) string
+}
+
+// maxSetUpdateTries bounds the reads of UpdateSets while concurrent updates
+// change the arrays it changes.
+const maxSetUpdateTries = 10
+
+var stringsType = reflect.TypeOf([]string(nil))
+
+// docUpdater updates the fields of a bson document.
+type docUpdater bson.D
+
+func (updater docUpdater) ToBsonDoc() (*bson.D, error) {
+	doc := bson.D(updater)
+	return &doc, nil
+}
+
+type query struct {
+	dialect Dialect
+	args    []any
+}
+
+func (q *query) arg(v any) string {
+	q.args = append(q.args, v)
+	return q.dialect.Placeholder(len(q.args))
+}
+
+type field struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+type Store[T any] struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	scoping db.Scoping
+	idIndex []int
+	fields  []field
+}
+
+func NewStore[T any](sqlDB *sql.DB, dialect Dialect, table string, owned bool) *Store[T] {
+	if owned {
+		return NewScopedStore[T](sqlDB, dialect, table, db.OwnerScoped)
+	}
+	return NewScopedStore[T](sqlDB, dialect, table, db.Unscoped)
+}
+
+// NewScopedStore returns a store restricted to the tenant column named by
+// scoping.
+func NewScopedStore[T any](db *sql.DB, dialect Dialect, table string, scoping db.Scoping) *Store[T] {
+	var entity T
+	typ := reflect.TypeOf(entity)
+	var idIndex []int
+	fields := []field{}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("bson"), ",")
+		if name == "_id" {
+			idIndex = sf.Index
+			continue
+		}
+		if !sf.IsExported() || len(name) == 0 || name == "-" {
+			continue
+		}
+		fields = append(fields, field{
+			name:  name,
+			index: sf.Index,
+			typ:   sf.Type,
+		})
+	}
+	return &Store[T]{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+		scoping: scoping,
+		idIndex: idIndex,
+		fields:  fields,
+	}
+}
+
+func (st *Store[T]) Drop(ctx context.Context) error {
+	_, err := st.db.ExecContext(ctx, "DELETE FROM "+quote(st.table))
+	return err
+}
+
+func (st *Store[T]) GetAll(ctx context.Context) ([]T, error) {
+	q := st.query()
+	where, err := st.scope(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return st.selectAll(ctx, q, where, quote(idColumn))
+}
+
+func (st *Store[T]) GetByID(ctx context.Context, id string) (T, error) {
+	q := st.query()
+	where, err := st.scope(ctx, q)
+	if err != nil {
+		var entity T
+		return entity, err
+	}
+	where = append(where, quote(idColumn)+" = "+q.arg(id))
+	return st.selectOne(ctx, q, where)
+}
+
+func (st *Store[T]) Create(ctx context.Context, newEntity T) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	q := st.query()
+	columns := []string{quote(idColumn)}
+	values := []string{q.arg(id)}
+	entity := reflect.ValueOf(newEntity)
+	for _, f := range st.fields {
+		value, err := st.encode(entity.FieldByIndex(f.index))
+		if err != nil {
+			return "", fmt.Errorf("encode %s: %w", f.name, err)
+		}
+		columns = append(columns, quote(f.name))
+		values = append(values, q.arg(value))
+	}
+	_, err := st.db.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(st.table), strings.Join(columns, ", "), strings.Join(values, ", ")),
+		q.args...,
+	)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (st *Store[T]) Update(ctx context.Context, id string, updater db.Updater) error {
+	n, err := st.update(ctx, id, updater, nil, true, nil)
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return fmt.Errorf("no changes for entity with id = %s", id)
+	}
+	return nil
+}
+
+func (st *Store[T]) UpdateIf(ctx context.Context, id string, conds []db.Cond, updater db.Updater) error {
+	n, err := st.update(ctx, id, updater, conds, false, nil)
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return db.ErrNoMatch
+	}
+	return nil
+}
+
+// update sets the fields of updater on the entity with id matching conds,
+// with changed only when one of them differs and only while the columns of
+// current still hold its encoded values. It returns the number of rows updated.
+func (st *Store[T]) update(ctx context.Context, id string, updater db.Updater, conds []db.Cond, changed bool, current bson.D) (int64, error) {
+	entityBson, err := updater.ToBsonDoc()
+	if err != nil {
+		return 0, err
+	}
+	if entityBson == nil || len(*entityBson) == 0 {
+		return 0, fmt.Errorf("empty update for entity with id = %s", id)
+	}
+	// decoding the update into T gives every field its go type, so values are
+	// encoded the same way as on create
+	data, err := bson.Marshal(entityBson)
+	if err != nil {
+		return 0, err
+	}
+	var partial T
+	if err := bson.Unmarshal(data, &partial); err != nil {
+		return 0, err
+	}
+	entity := reflect.ValueOf(partial)
+
+	columns := []string{}
+	values := []any{}
+	for _, elem := range *entityBson {
+		f, ok := st.field(elem.Key)
+		if !ok {
+			return 0, fmt.Errorf("unknown field %s", elem.Key)
+		}
+		value, err := st.encode(entity.FieldByIndex(f.index))
+		if err != nil {
+			return 0, fmt.Errorf("encode %s: %w", f.name, err)
+		}
+		columns = append(columns, quote(f.name))
+		values = append(values, value)
+	}
+
+	// arguments are bound in the order they appear in the statement
+	q := st.query()
+	sets := []string{}
+	for i, column := range columns {
+		sets = append(sets, column+" = "+q.arg(values[i]))
+	}
+	where, err := st.scope(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	where = append(where, quote(idColumn)+" = "+q.arg(id))
+	condWhere, err := st.conds(q, conds)
+	if err != nil {
+		return 0, err
+	}
+	where = append(where, condWhere...)
+	for _, elem := range current {
+		where = append(where, st.dialect.NotDistinct(quote(elem.Key), q.arg(elem.Value)))
+	}
+	if changed {
+		unchanged := []string{}
+		for i, column := range columns {
+			unchanged = append(unchanged, st.dialect.NotDistinct(column, q.arg(values[i])))
+		}
+		where = append(where, "NOT ("+strings.Join(unchanged, " AND ")+")")
+	}
+	res, err := st.db.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET %s WHERE %s", quote(st.table), strings.Join(sets, ", "), strings.Join(where, " AND ")),
+		q.args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// UpdateSets reads the arrays and writes them changed as long as they still
+// hold what was read, and reads them again when a concurrent update changed
+// them meanwhile.
+func (st *Store[T]) UpdateSets(ctx context.Context, id string, conds []db.Cond, changes []db.SetChange) error {
+	for try := 0; try < maxSetUpdateTries; try++ {
+		page, err := st.Query(ctx, db.Query{
+			Conds: append(slices.Clone(conds), db.Cond{Field: db.IDField, Op: db.OpEq, Value: id}),
+			Limit: 1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(page.Items) == 0 {
+			return db.ErrNoMatch
+		}
+		entity := reflect.ValueOf(page.Items[0])
+		current := bson.D{}
+		arrays := map[string][]string{}
+		update := bson.D{}
+		for _, change := range changes {
+			array, ok := arrays[change.Field]
+			if !ok {
+				f, ok := st.field(change.Field)
+				if !ok || f.typ != stringsType {
+					return fmt.Errorf("%s is not an array of strings", change.Field)
+				}
+				value := entity.FieldByIndex(f.index)
+				encoded, err := st.encode(value)
+				if err != nil {
+					return fmt.Errorf("encode %s: %w", f.name, err)
+				}
+				current = append(current, bson.E{Key: f.name, Value: encoded})
+				array = value.Interface().([]string)
+				update = append(update, bson.E{Key: f.name})
+			}
+			without := slices.DeleteFunc(slices.Clone(array), func(value string) bool { return value == change.Value })
+			switch {
+			case change.Remove:
+				array = without
+			case len(without) == len(array):
+				array = append(without, change.Value)
+			}
+			arrays[change.Field] = array
+		}
+		for i := range update {
+			update[i].Value = arrays[update[i].Key]
+		}
+		n, err := st.update(ctx, id, docUpdater(update), conds, false, current)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("entity with id = %s is changed by too many concurrent updates", id)
+}
+
+func (st *Store[T]) Delete(ctx context.Context, id string) error {
+	q := st.query()
+	where, err := st.scope(ctx, q)
+	if err != nil {
+		return err
+	}
+	where = append(where, quote(idColumn)+" = "+q.arg(id))
+	res, err := st.db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE %s", quote(st.table), strings.Join(where, " AND ")),
+		q.args...,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n <= 0 {
+		return fmt.Errorf("can't delete entity with id = %s", id)
+	}
+	return nil
+}
+
+func (st *Store[T]) query() *query {
+	return &query{dialect: st.dialect}
+}
+
+func (st *Store[T]) scope(ctx context.Context, q *query) ([]string, error) {
+	tenantID, err := db.ScopeTenant(ctx, st.scoping)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenantID) == 0 {
+		return nil, nil
+	}
+	return []string{quote(string(st.scoping)) + " = " + q.arg(tenantID)}, nil
+}
+
+func (st *Store[T]) field(name string) (field, bool) {
+	for _, f := range st.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func (st *Store[T]) columns() string {
+	columns := []string{quote(idColumn)}
+	for _, f := range st.fields {
+		columns = append(columns, quote(f.name))
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (st *Store[T]) selectAll(ctx context.Context, q *query, where []string, orderBy string) ([]T, error) {
+	rows, err := st.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", st.columns(), quote(st.table), whereClause(where), orderBy),
+		q.args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := []T{}
+	for rows.Next() {
+		entity, err := st.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+func (st *Store[T]) selectOne(ctx context.Context, q *query, where []string) (T, error) {
+	row := st.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT %s FROM %s%s", st.columns(), quote(st.table), whereClause(where)),
+		q.args...,
+	)
+	entity, err := st.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity, db.ErrNotFound
+	}
+	return entity, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (st *Store[T]) scan(row scanner) (T, error) {
+	var entity T
+	values := make([]any, len(st.fields)+1)
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := row.Scan(dest...); err != nil {
+		return entity, err
+	}
+	v := reflect.ValueOf(&entity).Elem()
+	if st.idIndex != nil {
+		if err := decode(values[0], v.FieldByIndex(st.idIndex)); err != nil {
+			return entity, err
+		}
+	}
+	for i, f := range st.fields {
+		if err := decode(values[i+1], v.FieldByIndex(f.index)); err != nil {
+			return entity, fmt.Errorf("decode %s: %w", f.name, err)
+		}
+	}
+	return entity, nil
+}
+
+type UserStore struct {
+	*Store[types.User]
+	ownedTables []string
+}
+
+// NewUserStore returns a user store which deletes users together with their
+// rows in ownedTables.
+func NewUserStore(db *sql.DB, dialect Dialect, table string, ownedTables ...string) UserStore {
+	return UserStore{
+		Store:       NewStore[types.User](db, dialect, table, false),
+		ownedTables: ownedTables,
+	}
+}
+
+// Delete removes the user and everything the user owns in one transaction,
+// so a failure never leaves spends behind without their owner.
+func (st UserStore) Delete(ctx context.Context, id string) error {
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range st.ownedTables {
+		q := st.query()
+		_, err := tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE %s = %s", quote(table), quote(ownerColumn), q.arg(id)),
+			q.args...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	q := st.query()
+	res, err := tx.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE %s = %s", quote(st.table), quote(idColumn), q.arg(id)),
+		q.args...,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n <= 0 {
+		return fmt.Errorf("can't delete entity with id = %s", id)
+	}
+	return tx.Commit()
+}
+
+func (st UserStore) GetByEmail(ctx context.Context, email string) (types.User, error) {
+	q := st.query()
+	return st.selectOne(ctx, q, []string{quote("email") + " = " + q.arg(email)})
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 
 	"github.com/SpectralJager/spender/types"
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,12 +15,89 @@ type UserStore interface {
 	CreateStorer[types.User]
 	DeleteStorer
 	UpdateStorer
+	QueryStorer[types.User]
 
 	GetByEmail(ctx context.Context, email string) (types.User, error)
 }
 
 type SpendStor[T any] interface {
 	BaseCRUDStore[T]
+	ConditionalUpdateStorer
+	QueryStorer[T]
+	Aggregator
+}
+
+type CategoryStore interface {
+	BaseCRUDStore[types.Category]
+	QueryStorer[types.Category]
+}
+
+type AccountStore interface {
+	BaseCRUDStore[types.Account]
+	QueryStorer[types.Account]
+}
+
+type BudgetStore interface {
+	BaseCRUDStore[types.Budget]
+	QueryStorer[types.Budget]
+}
+
+type ProjectStore interface {
+	BaseCRUDStore[types.Project]
+	QueryStorer[types.Project]
+}
+
+type InvoiceStore interface {
+	BaseCRUDStore[types.Invoice]
+	QueryStorer[types.Invoice]
+}
+
+// GroupStore keeps groups shared by their members, handlers check
+// membership before they use a group.
+type GroupStore interface {
+	BaseCRUDStore[types.Group]
+	SetUpdateStorer
+	QueryStorer[types.Group]
+}
+
+// GroupExpenseStore is restricted to the group of the scope.
+type GroupExpenseStore interface {
+	BaseCRUDStore[types.GroupExpense]
+	QueryStorer[types.GroupExpense]
+}
+
+// TimerStore keeps the running timer of each user.
+type TimerStore interface {
+	BaseCRUDStore[types.Timer]
+	QueryStorer[types.Timer]
+}
+
+// SessionStore keeps the login sessions of users, looking them up by the
+// hash of their refresh token needs a system scope.
+type SessionStore interface {
+	BaseCRUDStore[types.Session]
+	ConditionalUpdateStorer
+	QueryStorer[types.Session]
+}
+
+// APIKeyStore keeps the API keys of users, looking them up by the hash of
+// the key needs a system scope.
+type APIKeyStore interface {
+	BaseCRUDStore[types.APIKey]
+	QueryStorer[types.APIKey]
+}
+
+type RecurringStore interface {
+	BaseCRUDStore[types.Recurring]
+	QueryStorer[types.Recurring]
+}
+
+// ExchangeRateStore keeps the exchange rates shared by all users.
+type ExchangeRateStore interface {
+	Dropper
+	CreateStorer[types.ExchangeRate]
+	UpdateStorer
+	QueryStorer[types.ExchangeRate]
 }
 
 type MongoUserStore struct {
@@ -28,6 +106,7 @@ type MongoUserStore struct {
 	DefaultMongoCreateStore[types.User]
 	DefaultMongoUpdateStore
 	DefaultMongoDeleteStore
+	DefaultMongoQueryStore[types.User]
 	coll *mongo.Collection
 }
 
@@ -35,10 +114,11 @@ func NewMongoUserStore(cl *mongo.Client, dbname, collname string) *MongoUserStor
 	coll := cl.Database(dbname).Collection(collname)
 	return &MongoUserStore{
 		DefaultMongoDropStore:   DefaultMongoDropStore{coll},
-		DefaultMongoGetStore:    DefaultMongoGetStore[types.User]{coll},
+		DefaultMongoGetStore:    DefaultMongoGetStore[types.User]{coll, Unscoped},
 		DefaultMongoCreateStore: DefaultMongoCreateStore[types.User]{coll},
-		DefaultMongoUpdateStore: DefaultMongoUpdateStore{coll},
-		DefaultMongoDeleteStore: DefaultMongoDeleteStore{coll},
+		DefaultMongoUpdateStore: DefaultMongoUpdateStore{coll, Unscoped},
+		DefaultMongoDeleteStore: DefaultMongoDeleteStore{coll, Unscoped},
+		DefaultMongoQueryStore:  DefaultMongoQueryStore[types.User]{coll, Unscoped},
 		coll:                    coll,
 	}
 }
@@ -47,6 +127,9 @@ func (st MongoUserStore) GetByEmail(ctx context.Context, email string) (types.Us
 	res := st.coll.FindOne(ctx, bson.M{"email": email})
 	var user types.User
 	err := res.Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return types.User{}, ErrNotFound
+	}
 	if err != nil {
 		return types.User{}, err
 	}
@@ -76,3 +159,178 @@ func NewMongoMoneyspendStore(cl *mongo.Client, dbname string, collname string) M
 		),
 	}
 }
+
+type MongoIncomeStore struct {
+	DefaultMongoStore[types.Income]
+}
+
+func NewMongoIncomeStore(cl *mongo.Client, dbname string, collname string) MongoIncomeStore {
+	return MongoIncomeStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Income](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoAccountStore struct {
+	DefaultMongoStore[types.Account]
+}
+
+func NewMongoAccountStore(cl *mongo.Client, dbname string, collname string) MongoAccountStore {
+	return MongoAccountStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Account](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoTransferStore struct {
+	DefaultMongoStore[types.Transfer]
+}
+
+func NewMongoTransferStore(cl *mongo.Client, dbname string, collname string) MongoTransferStore {
+	return MongoTransferStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Transfer](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoBudgetStore struct {
+	DefaultMongoStore[types.Budget]
+}
+
+func NewMongoBudgetStore(cl *mongo.Client, dbname string, collname string) MongoBudgetStore {
+	return MongoBudgetStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Budget](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoRecurringStore struct {
+	DefaultMongoStore[types.Recurring]
+}
+
+func NewMongoRecurringStore(cl *mongo.Client, dbname string, collname string) MongoRecurringStore {
+	return MongoRecurringStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Recurring](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoProjectStore struct {
+	DefaultMongoStore[types.Project]
+}
+
+func NewMongoProjectStore(cl *mongo.Client, dbname string, collname string) MongoProjectStore {
+	return MongoProjectStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Project](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoInvoiceStore struct {
+	DefaultMongoStore[types.Invoice]
+}
+
+func NewMongoInvoiceStore(cl *mongo.Client, dbname string, collname string) MongoInvoiceStore {
+	return MongoInvoiceStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Invoice](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoGroupStore struct {
+	DefaultMongoStore[types.Group]
+}
+
+func NewMongoGroupStore(cl *mongo.Client, dbname string, collname string) MongoGroupStore {
+	return MongoGroupStore{
+		DefaultMongoStore: NewScopedMongoStore[types.Group](
+			cl.Database(dbname).Collection(collname),
+			Unscoped,
+		),
+	}
+}
+
+type MongoGroupExpenseStore struct {
+	DefaultMongoStore[types.GroupExpense]
+}
+
+func NewMongoGroupExpenseStore(cl *mongo.Client, dbname string, collname string) MongoGroupExpenseStore {
+	return MongoGroupExpenseStore{
+		DefaultMongoStore: NewScopedMongoStore[types.GroupExpense](
+			cl.Database(dbname).Collection(collname),
+			GroupScoped,
+		),
+	}
+}
+
+type MongoTimerStore struct {
+	DefaultMongoStore[types.Timer]
+}
+
+func NewMongoTimerStore(cl *mongo.Client, dbname string, collname string) MongoTimerStore {
+	return MongoTimerStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Timer](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoSessionStore struct {
+	DefaultMongoStore[types.Session]
+}
+
+func NewMongoSessionStore(cl *mongo.Client, dbname string, collname string) MongoSessionStore {
+	return MongoSessionStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Session](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoAPIKeyStore struct {
+	DefaultMongoStore[types.APIKey]
+}
+
+func NewMongoAPIKeyStore(cl *mongo.Client, dbname string, collname string) MongoAPIKeyStore {
+	return MongoAPIKeyStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.APIKey](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoCategoryStore struct {
+	DefaultMongoStore[types.Category]
+}
+
+func NewMongoCategoryStore(cl *mongo.Client, dbname string, collname string) MongoCategoryStore {
+	return MongoCategoryStore{
+		DefaultMongoStore: NewDefaultMongoStore[types.Category](
+			cl.Database(dbname).Collection(collname),
+		),
+	}
+}
+
+type MongoExchangeRateStore struct {
+	DefaultMongoDropStore
+	DefaultMongoCreateStore[types.ExchangeRate]
+	DefaultMongoUpdateStore
+	DefaultMongoQueryStore[types.ExchangeRate]
+}
+
+func NewMongoExchangeRateStore(cl *mongo.Client, dbname string, collname string) MongoExchangeRateStore {
+	coll := cl.Database(dbname).Collection(collname)
+	return MongoExchangeRateStore{
+		DefaultMongoDropStore:   DefaultMongoDropStore{coll},
+		DefaultMongoCreateStore: DefaultMongoCreateStore[types.ExchangeRate]{coll},
+		DefaultMongoUpdateStore: DefaultMongoUpdateStore{coll, Unscoped},
+		DefaultMongoQueryStore:  DefaultMongoQueryStore[types.ExchangeRate]{coll, Unscoped},
+	}
+}
@@ -0,0 +1,890 @@
+// Package storetest holds the behavioural contract shared by every store
+// backend. Backends call RunCRUDStore, RunUserStore and the other Run
+// functions from their own tests with a factory returning a fresh, empty
+// store.
+package storetest
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Owners the suite creates entities for. Backends which reference users from
+// owned entities should seed users with these IDs in their factory.
+const (
+	OwnerA = "6650c0ffee0000000000000a"
+	OwnerB = "6650c0ffee0000000000000b"
+)
+
+type CRUDFixture[T any] struct {
+	// Owned marks stores that must filter by the owner from the tenant scope.
+	Owned bool
+	// New returns the same valid entity owned by ownerID without an ID on every
+	// call. Times should be in UTC and truncated to milliseconds, the precision
+	// every backend keeps.
+	New func(ownerID string) T
+	// SetID returns entity with its ID replaced.
+	SetID func(entity T, id string) T
+	// Update is a partial update applied to entities returned by New.
+	Update db.Updater
+	// Apply returns the entity expected after Update was applied to it.
+	Apply func(entity T) T
+}
+
+func RunCRUDStore[T any](t *testing.T, factory func(t *testing.T) db.BaseCRUDStore[T], fx CRUDFixture[T]) {
+	ownerA, ownerB := OwnerA, OwnerB
+	scoped := func(ownerID string) context.Context {
+		if !fx.Owned {
+			return context.Background()
+		}
+		return tenant.WithOwner(context.Background(), ownerID)
+	}
+
+	t.Run("CreateReturnsObjectID", func(t *testing.T) {
+		st := factory(t)
+		id, err := st.Create(scoped(ownerA), fx.New(ownerA))
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if !primitive.IsValidObjectID(id) {
+			t.Fatalf("create returned %q, want 24 hex characters", id)
+		}
+		other, err := st.Create(scoped(ownerA), fx.New(ownerA))
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if id == other {
+			t.Fatalf("create returned the same id %q twice", id)
+		}
+	})
+
+	t.Run("GetByIDReturnsCreated", func(t *testing.T) {
+		st := factory(t)
+		entity := fx.New(ownerA)
+		id := mustCreate(t, st, scoped(ownerA), entity)
+		got, err := st.GetByID(scoped(ownerA), id)
+		if err != nil {
+			t.Fatalf("get by id: %v", err)
+		}
+		if want := fx.SetID(entity, id); !reflect.DeepEqual(got, want) {
+			t.Fatalf("get by id returned %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		st := factory(t)
+		for _, id := range []string{primitive.NewObjectID().Hex(), "not-an-id", ""} {
+			if _, err := st.GetByID(scoped(ownerA), id); !errors.Is(err, db.ErrNotFound) {
+				t.Fatalf("get by id %q returned %v, want %v", id, err, db.ErrNotFound)
+			}
+		}
+	})
+
+	t.Run("GetAllReturnsCreated", func(t *testing.T) {
+		st := factory(t)
+		entities, err := st.GetAll(scoped(ownerA))
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		if entities == nil || len(entities) != 0 {
+			t.Fatalf("get all on empty store returned %#v, want empty slice", entities)
+		}
+		first := mustCreate(t, st, scoped(ownerA), fx.New(ownerA))
+		second := mustCreate(t, st, scoped(ownerA), fx.New(ownerA))
+		entities, err = st.GetAll(scoped(ownerA))
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		want := []T{fx.SetID(fx.New(ownerA), first), fx.SetID(fx.New(ownerA), second)}
+		if !reflect.DeepEqual(entities, want) {
+			t.Fatalf("get all returned %+v, want %+v", entities, want)
+		}
+	})
+
+	t.Run("UpdateIsPartial", func(t *testing.T) {
+		st := factory(t)
+		entity := fx.New(ownerA)
+		id := mustCreate(t, st, scoped(ownerA), entity)
+		if err := st.Update(scoped(ownerA), id, fx.Update); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		got, err := st.GetByID(scoped(ownerA), id)
+		if err != nil {
+			t.Fatalf("get by id: %v", err)
+		}
+		if want := fx.SetID(fx.Apply(entity), id); !reflect.DeepEqual(got, want) {
+			t.Fatalf("updated entity is %+v, want %+v", got, want)
+		}
+		if err := st.Update(scoped(ownerA), id, fx.Update); err == nil {
+			t.Fatalf("repeated update succeeded, want no changes error")
+		}
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		st := factory(t)
+		if err := st.Update(scoped(ownerA), primitive.NewObjectID().Hex(), fx.Update); err == nil {
+			t.Fatalf("update of missing entity succeeded")
+		}
+	})
+
+	t.Run("DeleteRemoves", func(t *testing.T) {
+		st := factory(t)
+		id := mustCreate(t, st, scoped(ownerA), fx.New(ownerA))
+		if err := st.Delete(scoped(ownerA), id); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := st.GetByID(scoped(ownerA), id); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get by id after delete returned %v, want %v", err, db.ErrNotFound)
+		}
+		if err := st.Delete(scoped(ownerA), id); err == nil {
+			t.Fatalf("repeated delete succeeded")
+		}
+	})
+
+	if !fx.Owned {
+		return
+	}
+
+	t.Run("OwnerIsolation", func(t *testing.T) {
+		st := factory(t)
+		entity := fx.New(ownerA)
+		id := mustCreate(t, st, scoped(ownerA), entity)
+		mustCreate(t, st, scoped(ownerB), fx.New(ownerB))
+
+		if _, err := st.GetByID(scoped(ownerB), id); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("foreign get by id returned %v, want %v", err, db.ErrNotFound)
+		}
+		if err := st.Update(scoped(ownerB), id, fx.Update); err == nil {
+			t.Fatalf("foreign update succeeded")
+		}
+		if err := st.Delete(scoped(ownerB), id); err == nil {
+			t.Fatalf("foreign delete succeeded")
+		}
+		got, err := st.GetByID(scoped(ownerA), id)
+		if err != nil {
+			t.Fatalf("get by id: %v", err)
+		}
+		if want := fx.SetID(entity, id); !reflect.DeepEqual(got, want) {
+			t.Fatalf("entity changed by foreign owner to %+v, want %+v", got, want)
+		}
+		entities, err := st.GetAll(scoped(ownerB))
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		if len(entities) != 1 {
+			t.Fatalf("get all returned %d entities, want only the owner's one", len(entities))
+		}
+	})
+
+	t.Run("UnscopedIsRefused", func(t *testing.T) {
+		st := factory(t)
+		id := mustCreate(t, st, scoped(ownerA), fx.New(ownerA))
+		ctx := context.Background()
+		if _, err := st.GetAll(ctx); !errors.Is(err, db.ErrNoScope) {
+			t.Fatalf("unscoped get all returned %v, want %v", err, db.ErrNoScope)
+		}
+		if _, err := st.GetByID(ctx, id); !errors.Is(err, db.ErrNoScope) {
+			t.Fatalf("unscoped get by id returned %v, want %v", err, db.ErrNoScope)
+		}
+		if err := st.Update(ctx, id, fx.Update); !errors.Is(err, db.ErrNoScope) {
+			t.Fatalf("unscoped update returned %v, want %v", err, db.ErrNoScope)
+		}
+		if err := st.Delete(ctx, id); !errors.Is(err, db.ErrNoScope) {
+			t.Fatalf("unscoped delete returned %v, want %v", err, db.ErrNoScope)
+		}
+		if _, err := st.GetAll(tenant.WithOwner(ctx, "")); !errors.Is(err, db.ErrNoScope) {
+			t.Fatalf("get all with empty owner returned %v, want %v", err, db.ErrNoScope)
+		}
+	})
+
+	t.Run("SystemScopeSeesAll", func(t *testing.T) {
+		st := factory(t)
+		mustCreate(t, st, scoped(ownerA), fx.New(ownerA))
+		mustCreate(t, st, scoped(ownerB), fx.New(ownerB))
+		entities, err := st.GetAll(tenant.WithSystem(context.Background()))
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		if len(entities) != 2 {
+			t.Fatalf("system get all returned %d entities, want 2", len(entities))
+		}
+	})
+}
+
+func newUser(email string) types.User {
+	return types.User{
+		FirstName:    "John",
+		LastName:     "Doe",
+		Email:        email,
+		HashPassword: "hash",
+	}
+}
+
+func RunUserStore(t *testing.T, factory func(t *testing.T) db.UserStore) {
+	ctx := context.Background()
+
+	t.Run("GetByEmail", func(t *testing.T) {
+		st := factory(t)
+		user := newUser("john@doe.com")
+		id, err := st.Create(ctx, user)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		mustCreate[types.User](t, st, ctx, newUser("jane@doe.com"))
+		got, err := st.GetByEmail(ctx, user.Email)
+		if err != nil {
+			t.Fatalf("get by email: %v", err)
+		}
+		user.ID = id
+		if !reflect.DeepEqual(got, user) {
+			t.Fatalf("get by email returned %+v, want %+v", got, user)
+		}
+		if _, err := st.GetByEmail(ctx, "nobody@doe.com"); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get by unknown email returned %v, want %v", err, db.ErrNotFound)
+		}
+	})
+
+	t.Run("UpdateIsPartial", func(t *testing.T) {
+		st := factory(t)
+		user := newUser("john@doe.com")
+		id := mustCreate[types.User](t, st, ctx, user)
+		if err := st.Update(ctx, id, types.UpdateUserParams{LastName: "Smith"}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		got, err := st.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get by id: %v", err)
+		}
+		user.ID = id
+		user.LastName = "Smith"
+		if !reflect.DeepEqual(got, user) {
+			t.Fatalf("updated user is %+v, want %+v", got, user)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		st := factory(t)
+		id := mustCreate[types.User](t, st, ctx, newUser("john@doe.com"))
+		if err := st.Delete(ctx, id); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := st.GetByID(ctx, id); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get by id after delete returned %v, want %v", err, db.ErrNotFound)
+		}
+		if _, err := st.GetByEmail(ctx, "john@doe.com"); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get by email after delete returned %v, want %v", err, db.ErrNotFound)
+		}
+	})
+}
+
+// OwnedStores are the stores of every entity deleted with its owner.
+type OwnedStores struct {
+	Timespends  db.BaseCRUDStore[types.Timespend]
+	Moneyspends db.BaseCRUDStore[types.Moneyspend]
+	Incomes     db.BaseCRUDStore[types.Income]
+	Transfers   db.BaseCRUDStore[types.Transfer]
+	Accounts    db.BaseCRUDStore[types.Account]
+	Budgets     db.BaseCRUDStore[types.Budget]
+	Recurring   db.BaseCRUDStore[types.Recurring]
+	Timers      db.BaseCRUDStore[types.Timer]
+	Projects    db.BaseCRUDStore[types.Project]
+	Invoices    db.BaseCRUDStore[types.Invoice]
+	Sessions    db.BaseCRUDStore[types.Session]
+	APIKeys     db.BaseCRUDStore[types.APIKey]
+	Categories  db.BaseCRUDStore[types.Category]
+}
+
+// owned creates and counts the entities of one store of OwnedStores.
+type owned struct {
+	name   string
+	create func(ctx context.Context, ownerID string) error
+	count  func(ctx context.Context) (int, error)
+}
+
+func newOwned[T any](name string, st db.BaseCRUDStore[T], newEntity func(ownerID string) T) owned {
+	return owned{
+		name: name,
+		create: func(ctx context.Context, ownerID string) error {
+			_, err := st.Create(ctx, newEntity(ownerID))
+			return err
+		},
+		count: func(ctx context.Context) (int, error) {
+			entities, err := st.GetAll(ctx)
+			return len(entities), err
+		},
+	}
+}
+
+func (stores OwnedStores) owned() []owned {
+	date := time.Date(2024, time.March, 5, 9, 0, 0, 0, time.UTC)
+	return []owned{
+		newOwned("timespends", stores.Timespends, TimespendFixture().New),
+		newOwned("moneyspends", stores.Moneyspends, MoneyspendFixture().New),
+		newOwned("incomes", stores.Incomes, IncomeFixture().New),
+		newOwned("transfers", stores.Transfers, func(ownerID string) types.Transfer {
+			return types.Transfer{OwnerID: ownerID, Money: 1000, FromCurrency: "USD", ToMoney: 1000, ToCurrency: "USD", Date: date}
+		}),
+		newOwned("accounts", stores.Accounts, AccountFixture().New),
+		newOwned("budgets", stores.Budgets, BudgetFixture().New),
+		newOwned("recurring", stores.Recurring, RecurringFixture().New),
+		newOwned("timers", stores.Timers, func(ownerID string) types.Timer {
+			return types.Timer{OwnerID: ownerID, Start: date}
+		}),
+		newOwned("projects", stores.Projects, ProjectFixture().New),
+		newOwned("invoices", stores.Invoices, func(ownerID string) types.Invoice {
+			return types.Invoice{OwnerID: ownerID, Number: 1, Client: "acme", Currency: "EUR", Start: date, End: date.AddDate(0, 1, 0), IssuedAt: date}
+		}),
+		newOwned("sessions", stores.Sessions, func(ownerID string) types.Session {
+			return types.NewSession(ownerID, "session-"+ownerID, date, time.Hour)
+		}),
+		newOwned("api keys", stores.APIKeys, func(ownerID string) types.APIKey {
+			return types.APIKey{OwnerID: ownerID, Name: "ci", Prefix: "spk_", TokenHash: "key-" + ownerID, Scopes: []string{"read"}, CreatedAt: date, ExpiresAt: date.AddDate(1, 0, 0)}
+		}),
+		newOwned("categories", stores.Categories, CategoryFixture().New),
+	}
+}
+
+// UserDelete are the stores RunUserDelete runs on, all on one database.
+type UserDelete struct {
+	Users db.UserStore
+	// Failing deletes like Users but fails after deleting the owned
+	// entities.
+	Failing db.UserStore
+	// Tables are the tables Users deletes the entities of the user from.
+	Tables []string
+	Owned  OwnedStores
+}
+
+// RunUserDelete checks that deleting a user deletes every entity the user
+// owns at once, all of them or none when the delete fails partway through.
+func RunUserDelete(t *testing.T, factory func(t *testing.T) UserDelete) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (UserDelete, []owned, string, string) {
+		t.Helper()
+		stores := factory(t)
+		owned := stores.Owned.owned()
+		if len(owned) != len(stores.Tables) {
+			t.Fatalf("the suite covers %d owned stores, the user store deletes from %d tables", len(owned), len(stores.Tables))
+		}
+		john := mustCreate[types.User](t, stores.Users, ctx, newUser("john@doe.com"))
+		jane := mustCreate[types.User](t, stores.Users, ctx, newUser("jane@doe.com"))
+		for _, st := range owned {
+			for _, ownerID := range []string{john, jane} {
+				if err := st.create(tenant.WithOwner(ctx, ownerID), ownerID); err != nil {
+					t.Fatalf("create %s: %v", st.name, err)
+				}
+			}
+		}
+		return stores, owned, john, jane
+	}
+	count := func(t *testing.T, st owned, ownerID string) int {
+		t.Helper()
+		n, err := st.count(tenant.WithOwner(ctx, ownerID))
+		if err != nil {
+			t.Fatalf("count %s: %v", st.name, err)
+		}
+		return n
+	}
+
+	t.Run("DeletesOwned", func(t *testing.T) {
+		stores, owned, john, jane := setup(t)
+		if err := stores.Users.Delete(ctx, john); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := stores.Users.GetByID(ctx, john); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get by id after delete returned %v, want %v", err, db.ErrNotFound)
+		}
+		for _, st := range owned {
+			if n := count(t, st, john); n != 0 {
+				t.Fatalf("%d %s of the deleted user are left", n, st.name)
+			}
+			if n := count(t, st, jane); n != 1 {
+				t.Fatalf("%d %s of another user are left, want 1", n, st.name)
+			}
+		}
+	})
+
+	t.Run("FailureDeletesNothing", func(t *testing.T) {
+		stores, owned, john, _ := setup(t)
+		if err := stores.Failing.Delete(ctx, john); err == nil {
+			t.Fatalf("failing delete succeeded")
+		}
+		if _, err := stores.Users.GetByID(ctx, john); err != nil {
+			t.Fatalf("get by id after failed delete: %v", err)
+		}
+		for _, st := range owned {
+			if n := count(t, st, john); n != 1 {
+				t.Fatalf("%d %s of the user are left after a failed delete, want 1", n, st.name)
+			}
+		}
+	})
+}
+
+// RunQuery checks that cursors page through timespends sharing sort values
+// in both orders without duplicates or gaps, and that cursors which were
+// tampered with or made for another order are refused.
+func RunQuery(t *testing.T, factory func(t *testing.T) db.SpendStor[types.Timespend]) {
+	ctx := tenant.WithOwner(context.Background(), OwnerA)
+	fx := TimespendFixture()
+	st := factory(t)
+	durations := map[string]time.Duration{}
+	for _, hours := range []time.Duration{2, 1, 3, 2, 1, 2, 3} {
+		timespend := fx.New(OwnerA)
+		timespend.Duration = hours * time.Hour
+		durations[mustCreate(t, st, ctx, timespend)] = timespend.Duration
+	}
+	mustCreate(t, st, tenant.WithOwner(context.Background(), OwnerB), fx.New(OwnerB))
+
+	for _, desc := range []bool{false, true} {
+		q := db.Query{Sort: "duration", Desc: desc, Limit: 2}
+		seen := map[string]bool{}
+		var last time.Duration
+		for pages := 0; ; pages++ {
+			if pages > len(durations) {
+				t.Fatalf("paging with desc %v doesn't end", desc)
+			}
+			page, err := st.Query(ctx, q)
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			for _, timespend := range page.Items {
+				if _, ok := durations[timespend.ID]; !ok {
+					t.Fatalf("query with desc %v returned timespend %s of another owner", desc, timespend.ID)
+				}
+				if seen[timespend.ID] {
+					t.Fatalf("query with desc %v returned timespend %s twice", desc, timespend.ID)
+				}
+				if len(seen) != 0 && (timespend.Duration < last) != desc && timespend.Duration != last {
+					t.Fatalf("query with desc %v returned %v after %v", desc, timespend.Duration, last)
+				}
+				seen[timespend.ID] = true
+				last = timespend.Duration
+			}
+			if len(page.Next) == 0 {
+				break
+			}
+			q.After = page.Next
+		}
+		if len(seen) != len(durations) {
+			t.Fatalf("pages with desc %v returned %d of %d timespends", desc, len(seen), len(durations))
+		}
+	}
+
+	page, err := st.Query(ctx, db.Query{Sort: "duration", Limit: 2})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	for _, q := range []db.Query{
+		{Sort: "duration", After: page.Next + "!"},
+		{Sort: "duration", After: base64.RawURLEncoding.EncodeToString([]byte("not json"))},
+		{Sort: "duration", After: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"duration","k":"int","v":"an hour","id":"x"}`))},
+		{Sort: "duration", After: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"duration","k":"bytes","v":"1","id":"x"}`))},
+		{Sort: "duration", Desc: true, After: page.Next},
+		{Sort: "date", After: page.Next},
+	} {
+		if _, err := st.Query(ctx, q); err == nil {
+			t.Fatalf("query with cursor %q sorted by %s, desc %v succeeded", q.After, q.Sort, q.Desc)
+		}
+	}
+}
+
+// RunMoneyAggregate checks aggregates of money are exact, also beyond the
+// integers a float64 holds.
+func RunMoneyAggregate(t *testing.T, factory func(t *testing.T) db.SpendStor[types.Moneyspend]) {
+	ctx := tenant.WithOwner(context.Background(), OwnerA)
+	fx := MoneyspendFixture()
+	st := factory(t)
+	for _, money := range []types.Money{1 << 53, 1, 2} {
+		moneyspend := fx.New(OwnerA)
+		moneyspend.Money = money
+		mustCreate(t, st, ctx, moneyspend)
+	}
+	aggregates, err := st.Aggregate(ctx, db.Aggregation{Field: "money"})
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	want := db.Aggregate{Count: 3, Sum: 1<<53 + 3, Min: 1, Max: 1 << 53}
+	if len(aggregates) != 1 {
+		t.Fatalf("aggregate returned %d aggregates, want 1", len(aggregates))
+	}
+	got := aggregates[0]
+	got.Avg = 0
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("aggregate returned %+v, want %+v", got, want)
+	}
+}
+
+// RunUpdateIf checks that conditional updates change an entity only while
+// it matches their conditions, with timespends marked as invoiced.
+func RunUpdateIf(t *testing.T, factory func(t *testing.T) db.SpendStor[types.Timespend]) {
+	ctxA := tenant.WithOwner(context.Background(), OwnerA)
+	ctxB := tenant.WithOwner(context.Background(), OwnerB)
+	uninvoiced := []db.Cond{{Field: "invoiceId", Op: db.OpEmpty}}
+	st := factory(t)
+	id := mustCreate(t, st, ctxA, TimespendFixture().New(OwnerA))
+
+	if err := st.UpdateIf(ctxB, id, uninvoiced, types.InvoicedParams{InvoiceID: "first"}); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update of another owner returned %v, want ErrNoMatch", err)
+	}
+	if err := st.UpdateIf(ctxA, id, uninvoiced, types.InvoicedParams{InvoiceID: "first"}); err != nil {
+		t.Fatalf("update if: %v", err)
+	}
+	if err := st.UpdateIf(ctxA, id, uninvoiced, types.InvoicedParams{InvoiceID: "second"}); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update of an invoiced timespend returned %v, want ErrNoMatch", err)
+	}
+	got, err := st.GetByID(ctxA, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.InvoiceID != "first" {
+		t.Fatalf("timespend has invoice %q, want first", got.InvoiceID)
+	}
+
+	billedOnSecond := []db.Cond{{Field: "invoiceId", Op: db.OpEq, Value: "second"}}
+	if err := st.UpdateIf(ctxA, id, billedOnSecond, types.InvoicedParams{}); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update with a condition not matching returned %v, want ErrNoMatch", err)
+	}
+	billedOnFirst := []db.Cond{{Field: "invoiceId", Op: db.OpEq, Value: "first"}}
+	if err := st.UpdateIf(ctxA, id, billedOnFirst, types.InvoicedParams{}); err != nil {
+		t.Fatalf("update if: %v", err)
+	}
+	if err := st.UpdateIf(ctxA, id, uninvoiced, types.InvoicedParams{InvoiceID: "second"}); err != nil {
+		t.Fatalf("update of a timespend no longer invoiced: %v", err)
+	}
+	if err := st.UpdateIf(ctxA, primitive.NewObjectID().Hex(), nil, types.InvoicedParams{}); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update of a missing timespend returned %v, want ErrNoMatch", err)
+	}
+}
+
+// RunUpdateSets checks that set updates add values once, remove them, apply
+// only to entities matching their conditions and keep concurrent changes of
+// the same array, with the members of groups.
+func RunUpdateSets(t *testing.T, factory func(t *testing.T) db.GroupStore) {
+	ctx := context.Background()
+	st := factory(t)
+	id := mustCreate(t, st, ctx, types.Group{Name: "flat", Currency: "EUR", CreatedBy: OwnerA, Members: []string{OwnerA}})
+	members := func() ([]string, []string) {
+		t.Helper()
+		group, err := st.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		return group.Members, group.Invited
+	}
+
+	invite := []db.SetChange{{Field: "invited", Value: OwnerB}}
+	for range 2 {
+		if err := st.UpdateSets(ctx, id, nil, invite); err != nil {
+			t.Fatalf("update sets: %v", err)
+		}
+	}
+	if _, invited := members(); !reflect.DeepEqual(invited, []string{OwnerB}) {
+		t.Fatalf("invited are %v after inviting twice, want [%s]", invited, OwnerB)
+	}
+
+	invitedB := []db.Cond{{Field: "invited", Op: db.OpAny, Value: []string{OwnerB}}}
+	join := []db.SetChange{{Field: "invited", Value: OwnerB, Remove: true}, {Field: "members", Value: OwnerB}}
+	if err := st.UpdateSets(ctx, id, invitedB, join); err != nil {
+		t.Fatalf("update sets: %v", err)
+	}
+	if err := st.UpdateSets(ctx, id, invitedB, join); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update of a group not matching returned %v, want ErrNoMatch", err)
+	}
+	if got, invited := members(); !reflect.DeepEqual(got, []string{OwnerA, OwnerB}) || len(invited) != 0 {
+		t.Fatalf("members are %v and invited %v after joining, want [%s %s] and none", got, invited, OwnerA, OwnerB)
+	}
+
+	added := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range added {
+		added[i] = primitive.NewObjectID().Hex()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := st.UpdateSets(ctx, id, nil, []db.SetChange{{Field: "invited", Value: added[i]}}); err != nil {
+				t.Errorf("concurrent update sets: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, invited := members(); len(invited) != len(added) {
+		t.Fatalf("%d users are invited after %d concurrent invitations", len(invited), len(added))
+	}
+
+	if err := st.UpdateSets(ctx, id, nil, []db.SetChange{{Field: "members", Value: OwnerB, Remove: true}}); err != nil {
+		t.Fatalf("update sets: %v", err)
+	}
+	if got, _ := members(); !reflect.DeepEqual(got, []string{OwnerA}) {
+		t.Fatalf("members are %v after leaving, want [%s]", got, OwnerA)
+	}
+	if err := st.UpdateSets(ctx, primitive.NewObjectID().Hex(), nil, invite); !errors.Is(err, db.ErrNoMatch) {
+		t.Fatalf("update of a missing group returned %v, want ErrNoMatch", err)
+	}
+}
+
+// RunTimerStore checks that users have one timer at most, a second timer is
+// refused until the first one is deleted.
+func RunTimerStore(t *testing.T, factory func(t *testing.T) db.TimerStore) {
+	ctxA := tenant.WithOwner(context.Background(), OwnerA)
+	ctxB := tenant.WithOwner(context.Background(), OwnerB)
+	start := time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC)
+	st := factory(t)
+	id := mustCreate(t, st, ctxA, types.Timer{OwnerID: OwnerA, Start: start, Note: "first"})
+	if _, err := st.Create(ctxA, types.Timer{OwnerID: OwnerA, Start: start, Note: "second"}); err == nil {
+		t.Fatalf("second timer of the same owner was created")
+	}
+	mustCreate(t, st, ctxB, types.Timer{OwnerID: OwnerB, Start: start, Note: "other owner"})
+	if err := st.Delete(ctxA, id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	mustCreate(t, st, ctxA, types.Timer{OwnerID: OwnerA, Start: start, Note: "after delete"})
+}
+
+// RunInvoiceStore checks that invoice numbers are unique per owner.
+func RunInvoiceStore(t *testing.T, factory func(t *testing.T) db.InvoiceStore) {
+	ctxA := tenant.WithOwner(context.Background(), OwnerA)
+	ctxB := tenant.WithOwner(context.Background(), OwnerB)
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	invoice := func(ownerID string, number int) types.Invoice {
+		return types.Invoice{OwnerID: ownerID, Number: number, Client: "Acme", Currency: "EUR", Start: day, End: day, IssuedAt: day}
+	}
+	st := factory(t)
+	mustCreate(t, st, ctxA, invoice(OwnerA, 1))
+	if _, err := st.Create(ctxA, invoice(OwnerA, 1)); err == nil {
+		t.Fatalf("second invoice of the same owner with the same number was created")
+	}
+	mustCreate(t, st, ctxA, invoice(OwnerA, 2))
+	mustCreate(t, st, ctxB, invoice(OwnerB, 1))
+}
+
+func TimespendFixture() CRUDFixture[types.Timespend] {
+	date := time.Date(2024, time.May, 1, 10, 30, 0, 0, time.UTC)
+	return CRUDFixture[types.Timespend]{
+		Owned: true,
+		New: func(ownerID string) types.Timespend {
+			return types.Timespend{
+				OwnerID:  ownerID,
+				Duration: time.Hour,
+				Date:     date,
+				Start:    date,
+				End:      date.Add(time.Hour),
+				Note:     "code review",
+				Billable: true,
+				Tags:     []string{"client-acme", "review"},
+			}
+		},
+		SetID: func(entity types.Timespend, id string) types.Timespend {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateTimespendParams{Note: "pair programming"},
+		Apply: func(entity types.Timespend) types.Timespend {
+			entity.Note = "pair programming"
+			return entity
+		},
+	}
+}
+
+func MoneyspendFixture() CRUDFixture[types.Moneyspend] {
+	date := time.Date(2024, time.May, 1, 10, 30, 0, 0, time.UTC)
+	return CRUDFixture[types.Moneyspend]{
+		Owned: true,
+		New: func(ownerID string) types.Moneyspend {
+			return types.Moneyspend{
+				OwnerID:      ownerID,
+				Money:        1250,
+				Currency:     "USD",
+				Date:         date,
+				Note:         "groceries",
+				Reimbursable: true,
+			}
+		},
+		SetID: func(entity types.Moneyspend, id string) types.Moneyspend {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateMoneyspendParams{Money: "20", Currency: "USD"},
+		Apply: func(entity types.Moneyspend) types.Moneyspend {
+			entity.Money = 2000
+			return entity
+		},
+	}
+}
+
+func mustCreate[T any](t *testing.T, st db.CreateStorer[T], ctx context.Context, entity T) string {
+	t.Helper()
+	id, err := st.Create(ctx, entity)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return id
+}
+
+func CategoryFixture() CRUDFixture[types.Category] {
+	return CRUDFixture[types.Category]{
+		Owned: true,
+		New: func(ownerID string) types.Category {
+			return types.Category{
+				OwnerID: ownerID,
+				Name:    "groceries",
+				Color:   "#4caf50",
+			}
+		},
+		SetID: func(entity types.Category, id string) types.Category {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateCategoryParams{Icon: "cart"},
+		Apply: func(entity types.Category) types.Category {
+			entity.Icon = "cart"
+			return entity
+		},
+	}
+}
+
+func IncomeFixture() CRUDFixture[types.Income] {
+	date := time.Date(2024, time.May, 31, 9, 0, 0, 0, time.UTC)
+	return CRUDFixture[types.Income]{
+		Owned: true,
+		New: func(ownerID string) types.Income {
+			return types.Income{
+				OwnerID:  ownerID,
+				Money:    250000,
+				Currency: "EUR",
+				Date:     date,
+				Note:     "salary",
+				Tags:     []string{"work"},
+			}
+		},
+		SetID: func(entity types.Income, id string) types.Income {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateIncomeParams{Money: "2600", Currency: "EUR"},
+		Apply: func(entity types.Income) types.Income {
+			entity.Money = 260000
+			return entity
+		},
+	}
+}
+
+func AccountFixture() CRUDFixture[types.Account] {
+	return CRUDFixture[types.Account]{
+		Owned: true,
+		New: func(ownerID string) types.Account {
+			return types.Account{
+				OwnerID:        ownerID,
+				Name:           "wallet",
+				Kind:           types.AccountCash,
+				Currency:       "USD",
+				OpeningBalance: 5000,
+			}
+		},
+		SetID: func(entity types.Account, id string) types.Account {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateAccountParams{Name: "pocket", OpeningBalance: "75.25", Currency: "USD"},
+		Apply: func(entity types.Account) types.Account {
+			entity.Name = "pocket"
+			entity.OpeningBalance = 7525
+			return entity
+		},
+	}
+}
+
+func BudgetFixture() CRUDFixture[types.Budget] {
+	return CRUDFixture[types.Budget]{
+		Owned: true,
+		New: func(ownerID string) types.Budget {
+			return types.Budget{
+				OwnerID:  ownerID,
+				Name:     "groceries",
+				Kind:     types.BudgetMoney,
+				Period:   types.BudgetMonth,
+				Money:    40000,
+				Currency: "USD",
+				Keyword:  "market",
+			}
+		},
+		SetID: func(entity types.Budget, id string) types.Budget {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateBudgetParams{Period: types.BudgetWeek, Money: "100", Currency: "USD", Kind: types.BudgetMoney},
+		Apply: func(entity types.Budget) types.Budget {
+			entity.Period = types.BudgetWeek
+			entity.Money = 10000
+			return entity
+		},
+	}
+}
+
+func RecurringFixture() CRUDFixture[types.Recurring] {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+	return CRUDFixture[types.Recurring]{
+		Owned: true,
+		New: func(ownerID string) types.Recurring {
+			return types.Recurring{
+				OwnerID:  ownerID,
+				Kind:     types.RecurringMoney,
+				Freq:     types.RecurringMonthly,
+				Interval: 1,
+				MonthDay: 31,
+				Start:    start,
+				Count:    12,
+				Money:    120000,
+				Currency: "USD",
+				Note:     "rent",
+				Tags:     []string{"home"},
+				Skipped:  []string{"2024-03-31"},
+				Next:     start,
+			}
+		},
+		SetID: func(entity types.Recurring, id string) types.Recurring {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateRecurringParams{Note: "flat", Money: "1250", Currency: "USD", Kind: types.RecurringMoney},
+		Apply: func(entity types.Recurring) types.Recurring {
+			entity.Note = "flat"
+			entity.Money = 125000
+			return entity
+		},
+	}
+}
+
+func ProjectFixture() CRUDFixture[types.Project] {
+	return CRUDFixture[types.Project]{
+		Owned: true,
+		New: func(ownerID string) types.Project {
+			return types.Project{
+				OwnerID:    ownerID,
+				Name:       "website",
+				Client:     "acme",
+				HourlyRate: 9000,
+				Currency:   "EUR",
+			}
+		},
+		SetID: func(entity types.Project, id string) types.Project {
+			entity.ID = id
+			return entity
+		},
+		Update: types.UpdateProjectParams{Client: "globex", HourlyRate: "95.5", Currency: "EUR"},
+		Apply: func(entity types.Project) types.Project {
+			entity.Client = "globex"
+			entity.HourlyRate = 9550
+			return entity
+		},
+	}
+}
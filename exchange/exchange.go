@@ -0,0 +1,307 @@
+// Package exchange reads exchange rate files into the rate store and turns
+// stored rates into dated series converting one currency to another.
+package exchange
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// Load reads the rates of a .csv or .json file, see ParseCSV and ParseJSON
+// for their formats.
+func Load(path string) ([]types.ExchangeRate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(file)
+	case ".json":
+		return ParseJSON(file)
+	}
+	return nil, fmt.Errorf("rates file %s should be .csv or .json", path)
+}
+
+// ParseCSV reads rates from csv with a header naming the date, from, to and
+// rate columns, in any order. Dates are days like 2006-01-02.
+func ParseCSV(r io.Reader) ([]types.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "from", "to", "rate"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("header should have a %s column", name)
+		}
+	}
+	rates := []types.ExchangeRate{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[index["rate"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: rate should be a number", line)
+		}
+		date, err := time.Parse(dateLayout, strings.TrimSpace(record[index["date"]]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: date should be like %s", line, dateLayout)
+		}
+		rates = append(rates, newRate(record[index["from"]], record[index["to"]], rate, date))
+	}
+	return rates, nil
+}
+
+type rateJSON struct {
+	Date string  `json:"date"`
+	From string  `json:"from"`
+	To   string  `json:"to"`
+	Rate float64 `json:"rate"`
+}
+
+// ParseJSON reads rates from a json array of objects with date, from, to
+// and rate fields. Dates are days like 2006-01-02.
+func ParseJSON(r io.Reader) ([]types.ExchangeRate, error) {
+	var entries []rateJSON
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	rates := []types.ExchangeRate{}
+	for i, entry := range entries {
+		date, err := time.Parse(dateLayout, entry.Date)
+		if err != nil {
+			return nil, fmt.Errorf("rate %d: date should be like %s", i, dateLayout)
+		}
+		rates = append(rates, newRate(entry.From, entry.To, entry.Rate, date))
+	}
+	return rates, nil
+}
+
+func newRate(from, to string, rate float64, date time.Time) types.ExchangeRate {
+	return types.ExchangeRate{
+		From: strings.ToUpper(strings.TrimSpace(from)),
+		To:   strings.ToUpper(strings.TrimSpace(to)),
+		Rate: rate,
+		Date: date,
+	}
+}
+
+// Import stores rates, replacing stored rates of the same pair and date, so
+// files can be imported again after being extended or corrected. It returns
+// the number of rates created or changed.
+func Import(ctx context.Context, store db.ExchangeRateStore, rates []types.ExchangeRate) (int, error) {
+	for i, rate := range rates {
+		if errs := rate.Validate(); len(errs) != 0 {
+			return 0, fmt.Errorf("rate %d: %v", i, errs)
+		}
+	}
+	imported := 0
+	for _, rate := range rates {
+		page, err := store.Query(ctx, db.Query{
+			Conds: []db.Cond{
+				{Field: "from", Op: db.OpEq, Value: rate.From},
+				{Field: "to", Op: db.OpEq, Value: rate.To},
+				{Field: "date", Op: db.OpEq, Value: rate.Date},
+			},
+			Limit: 1,
+		})
+		if err != nil {
+			return imported, err
+		}
+		if len(page.Items) == 0 {
+			if _, err := store.Create(ctx, rate); err != nil {
+				return imported, err
+			}
+			imported++
+			continue
+		}
+		if page.Items[0].Rate == rate.Rate {
+			continue
+		}
+		if err := store.Update(ctx, page.Items[0].ID, types.UpdateExchangeRateParams{Rate: rate.Rate}); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// Period is a rate valid from Start until the Start of the next period of
+// its series.
+type Period struct {
+	Start time.Time
+	Rate  float64
+}
+
+// Series converts one currency to another, its periods are ordered by
+// start. Before the first period there is no rate.
+type Series []Period
+
+// At returns the rate valid at t.
+func (s Series) At(t time.Time) (float64, bool) {
+	i := sort.Search(len(s), func(i int) bool {
+		return s[i].Start.After(t)
+	})
+	if i == 0 {
+		return 0, false
+	}
+	return s[i-1].Rate, true
+}
+
+// Lookup returns the series converting from to the to currency. It uses the
+// rates of the pair, the inverted rates of the reversed pair, or else the
+// rates of both currencies to a common third one. The series is empty when
+// there are no such rates.
+func Lookup(ctx context.Context, store db.ExchangeRateStore, from, to string) (Series, error) {
+	if from == to {
+		return Series{{Rate: 1}}, nil
+	}
+	fromRates, err := ratesOf(ctx, store, from)
+	if err != nil {
+		return nil, err
+	}
+	if direct := pairSeries(fromRates, from, to); len(direct) != 0 {
+		return direct, nil
+	}
+	toRates, err := ratesOf(ctx, store, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, pivot := range pivots(fromRates, toRates, from, to) {
+		cross := crossSeries(pairSeries(fromRates, from, pivot), pairSeries(toRates, pivot, to))
+		if len(cross) != 0 {
+			return cross, nil
+		}
+	}
+	return nil, nil
+}
+
+func ratesOf(ctx context.Context, store db.ExchangeRateStore, currency string) ([]types.ExchangeRate, error) {
+	rates := []types.ExchangeRate{}
+	for _, field := range []string{"from", "to"} {
+		q := db.Query{
+			Conds: []db.Cond{{Field: field, Op: db.OpEq, Value: currency}},
+			Sort:  "date",
+			Limit: db.MaxLimit,
+		}
+		for {
+			page, err := store.Query(ctx, q)
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, page.Items...)
+			if len(page.Next) == 0 {
+				break
+			}
+			q.After = page.Next
+		}
+	}
+	return rates, nil
+}
+
+// pairSeries picks the rates converting from to the to currency, inverting
+// rates of the reversed pair where the pair has none of its own.
+func pairSeries(rates []types.ExchangeRate, from, to string) Series {
+	byDate := map[time.Time]float64{}
+	for _, rate := range rates {
+		if rate.From == to && rate.To == from {
+			byDate[rate.Date.UTC()] = 1 / rate.Rate
+		}
+	}
+	for _, rate := range rates {
+		if rate.From == from && rate.To == to {
+			byDate[rate.Date.UTC()] = rate.Rate
+		}
+	}
+	series := Series{}
+	for date, rate := range byDate {
+		series = append(series, Period{Start: date, Rate: rate})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Start.Before(series[j].Start)
+	})
+	return series
+}
+
+// pivots returns the currencies both from and to have rates with, sorted.
+func pivots(fromRates, toRates []types.ExchangeRate, from, to string) []string {
+	counterparts := func(rates []types.ExchangeRate, currency string) map[string]bool {
+		set := map[string]bool{}
+		for _, rate := range rates {
+			if rate.From == currency {
+				set[rate.To] = true
+			} else {
+				set[rate.From] = true
+			}
+		}
+		return set
+	}
+	toSet := counterparts(toRates, to)
+	common := []string{}
+	for currency := range counterparts(fromRates, from) {
+		if toSet[currency] && currency != from && currency != to {
+			common = append(common, currency)
+		}
+	}
+	sort.Strings(common)
+	return common
+}
+
+// crossSeries chains two series, it starts once both have a rate.
+func crossSeries(first, second Series) Series {
+	if len(first) == 0 || len(second) == 0 {
+		return nil
+	}
+	starts := []time.Time{}
+	for _, s := range []Series{first, second} {
+		for _, period := range s {
+			starts = append(starts, period.Start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+	series := Series{}
+	for _, start := range starts {
+		if len(series) != 0 && !series[len(series)-1].Start.Before(start) {
+			continue
+		}
+		a, ok := first.At(start)
+		if !ok {
+			continue
+		}
+		b, ok := second.At(start)
+		if !ok {
+			continue
+		}
+		series = append(series, Period{Start: start, Rate: a * b})
+	}
+	return series
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	accountField     = "accountId"
+	fromAccountField = "fromAccountId"
+	toAccountField   = "toAccountId"
+)
+
+type AccountHandler struct {
+	accountStore    db.AccountStore
+	transferStore   db.SpendStor[types.Transfer]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	incomeStore     db.SpendStor[types.Income]
+	recurringStore  db.RecurringStore
+	userStore       db.UserStore
+}
+
+func NewAccountHandler(accountStore db.AccountStore, transferStore db.SpendStor[types.Transfer], moneyspendStore db.SpendStor[types.Moneyspend], incomeStore db.SpendStor[types.Income], recurringStore db.RecurringStore, userStore db.UserStore) *AccountHandler {
+	return &AccountHandler{
+		accountStore:    accountStore,
+		transferStore:   transferStore,
+		moneyspendStore: moneyspendStore,
+		incomeStore:     incomeStore,
+		recurringStore:  recurringStore,
+		userStore:       userStore,
+	}
+}
+
+func (h AccountHandler) GetAllAccounts(ctx echo.Context) error {
+	accounts, err := h.accountStore.GetAll(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"accounts": accounts})
+}
+
+func (h AccountHandler) PostAccount(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateAccountParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, ownerID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	account, err := types.NewAccountFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	account.OwnerID = ownerID
+	id, err := h.accountStore.Create(c, account)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h AccountHandler) GetAccount(ctx echo.Context) error {
+	id := ctx.Param("id")
+	account, err := h.accountStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"account": account})
+}
+
+func (h AccountHandler) PutAccount(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateAccountParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	account, err := h.accountStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = account.Currency
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.accountStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// DeleteAccount refuses to delete accounts that still have moneyspends,
+// incomes or transfers, so their balances stay reconcilable, or recurring
+// rules which would create moneyspends of a missing account.
+func (h AccountHandler) DeleteAccount(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	conds := []db.Cond{{Field: accountField, Op: db.OpEq, Value: id}}
+	moneyStats, err := aggregateTotal(c, h.moneyspendStore, "money", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	incomeStats, err := aggregateTotal(c, h.incomeStore, "money", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if moneyStats.Count != 0 || incomeStats.Count != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "account is used by moneyspends or incomes"})
+	}
+	for _, field := range []string{fromAccountField, toAccountField} {
+		stats, err := aggregateTotal(c, h.transferStore, "money", []db.Cond{{Field: field, Op: db.OpEq, Value: id}})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		if stats.Count != 0 {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "account is used by transfers"})
+		}
+	}
+	rules, err := h.recurringStore.Query(c, db.Query{Conds: conds, Limit: 1})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(rules.Items) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "account is used by recurring rules"})
+	}
+	if err := h.accountStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+type accountBalance struct {
+	AccountID string       `json:"accountId"`
+	Name      string       `json:"name"`
+	Balance   types.Amount `json:"balance"`
+}
+
+// GetBalances returns the balance of every account at the end of the date
+// day, today by default.
+func (h AccountHandler) GetBalances(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	until, date, err := balanceDate(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	accounts, err := h.accountStore.GetAll(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	balances := []accountBalance{}
+	for _, account := range accounts {
+		balance, err := h.balance(c, account, until)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		balances = append(balances, balance)
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"date": date, "balances": balances})
+}
+
+// GetBalance returns the balance of the account at the end of the date day,
+// today by default.
+func (h AccountHandler) GetBalance(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	until, date, err := balanceDate(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	account, err := h.accountStore.GetByID(c, ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	balance, err := h.balance(c, account, until)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"date": date, "balance": balance})
+}
+
+// balanceDate returns the end of the date parameter day in UTC, the end of
+// today without it, along with the day.
+func balanceDate(ctx echo.Context) (time.Time, string, error) {
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if param := ctx.QueryParam("date"); len(param) != 0 {
+		var err error
+		day, err = time.Parse(dateLayout, param)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("date should be a date like %s", dateLayout)
+		}
+	}
+	return day.AddDate(0, 0, 1), day.Format(dateLayout), nil
+}
+
+// balance sums the opening balance of account with its incomes and incoming
+// transfers less its moneyspends and outgoing transfers, dated from its
+// opening date until before until. Accounts opened at or after until have
+// no balance yet.
+func (h AccountHandler) balance(ctx context.Context, account types.Account, until time.Time) (accountBalance, error) {
+	balance := accountBalance{
+		AccountID: account.ID,
+		Name:      account.Name,
+		Balance:   types.Amount{Currency: account.Currency},
+	}
+	if !until.After(account.OpeningDate) {
+		return balance, nil
+	}
+	balance.Balance.Money = account.OpeningBalance
+	for _, source := range []struct {
+		store db.Aggregator
+		field string
+		by    string
+		sign  types.Money
+	}{
+		{h.incomeStore, "money", accountField, 1},
+		{h.moneyspendStore, "money", accountField, -1},
+		{h.transferStore, "toMoney", toAccountField, 1},
+		{h.transferStore, "money", fromAccountField, -1},
+	} {
+		conds := []db.Cond{
+			{Field: source.by, Op: db.OpEq, Value: account.ID},
+			{Field: dateField, Op: db.OpLt, Value: until},
+		}
+		if !account.OpeningDate.IsZero() {
+			conds = append(conds, db.Cond{Field: dateField, Op: db.OpGte, Value: account.OpeningDate})
+		}
+		stats, err := aggregateTotal(ctx, source.store, source.field, conds)
+		if err != nil {
+			return accountBalance{}, err
+		}
+		balance.Balance.Money += source.sign * types.Money(stats.Sum)
+	}
+	return balance, nil
+}
+
+// findAccount returns the account id, or a zero account when id is empty or
+// not one of the owner's accounts.
+func findAccount(ctx context.Context, store db.AccountStore, id string) (types.Account, error) {
+	if len(id) == 0 {
+		return types.Account{}, nil
+	}
+	account, err := store.GetByID(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return types.Account{}, nil
+	}
+	return account, err
+}
+
+// validateAccount adds a validation error to errs unless id is empty or the
+// found account, whose currency should be the one of the money entry.
+func validateAccount(account types.Account, id, currency string, errs map[string]string) {
+	if len(id) == 0 {
+		return
+	}
+	if len(account.ID) == 0 {
+		errs[accountField] = fmt.Sprintf("account with id = %s doesn't exist", id)
+		return
+	}
+	if account.Currency != currency {
+		errs[currencyField] = fmt.Sprintf("currency should be %s of the account", account.Currency)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler serves the admin routes, which see users and entities of all
+// users.
+type AdminHandler struct {
+	userStore       db.UserStore
+	timespendStore  db.SpendStor[types.Timespend]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	incomeStore     db.SpendStor[types.Income]
+	groupStore      db.GroupStore
+}
+
+func NewAdminHandler(userStore db.UserStore, timespendStore db.SpendStor[types.Timespend], moneyspendStore db.SpendStor[types.Moneyspend], incomeStore db.SpendStor[types.Income], groupStore db.GroupStore) *AdminHandler {
+	return &AdminHandler{
+		userStore:       userStore,
+		timespendStore:  timespendStore,
+		moneyspendStore: moneyspendStore,
+		incomeStore:     incomeStore,
+		groupStore:      groupStore,
+	}
+}
+
+func (h AdminHandler) GetAllUsers(ctx echo.Context) error {
+	q := db.Query{}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.userStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for i := range page.Items {
+		page.Items[i].Role = page.Items[i].UserRole()
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"users": page.Items, "next": page.Next})
+}
+
+func (h AdminHandler) GetUser(ctx echo.Context) error {
+	id := ctx.Param("id")
+	user, err := h.userStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	user.Role = user.UserRole()
+	return ctx.JSON(http.StatusOK, echo.Map{"user": user})
+}
+
+// PutUserRole changes the role of a user, tokens issued with the former
+// role are refused so the user logs in again. Admins can't change their own
+// role.
+func (h AdminHandler) PutUserRole(ctx echo.Context) error {
+	params, err := utils.DecodeBody[types.UserRoleParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	return h.updateUser(ctx, params)
+}
+
+// PostDisableUser disables the account of a user, the tokens of the user
+// are refused from then on. Admins can't disable themselves.
+func (h AdminHandler) PostDisableUser(ctx echo.Context) error {
+	return h.updateUser(ctx, types.UserStatusParams{Disabled: true})
+}
+
+func (h AdminHandler) PostEnableUser(ctx echo.Context) error {
+	return h.updateUser(ctx, types.UserStatusParams{Disabled: false})
+}
+
+func (h AdminHandler) updateUser(ctx echo.Context, params db.Updater) error {
+	id := ctx.Param("id")
+	if id == middleware.GetUserIDFromRequest(ctx.Request()) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "admins can't change their own account"})
+	}
+	c := ctx.Request().Context()
+	if _, err := h.userStore.GetByID(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := h.userStore.Update(c, id, params); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+type userStats struct {
+	Count    int `json:"count"`
+	Admins   int `json:"admins"`
+	Disabled int `json:"disabled"`
+}
+
+type timeStats struct {
+	Count    int64         `json:"count"`
+	Duration time.Duration `json:"duration"`
+}
+
+// GetStats counts users and entities of all users.
+func (h AdminHandler) GetStats(ctx echo.Context) error {
+	c := tenant.WithSystem(ctx.Request().Context())
+	users, err := queryAll(c, h.userStore, db.Query{})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	userTotals := userStats{Count: len(users)}
+	for _, user := range users {
+		if user.UserRole() == types.RoleAdmin {
+			userTotals.Admins++
+		}
+		if user.Disabled {
+			userTotals.Disabled++
+		}
+	}
+	timespends, err := aggregateTotal(c, h.timespendStore, "duration", nil)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	moneyspends, err := aggregateTotal(c, h.moneyspendStore, "money", nil)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	incomes, err := aggregateTotal(c, h.incomeStore, "money", nil)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	groups, err := queryAll(c, h.groupStore, db.Query{})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"users":       userTotals,
+		"timespends":  timeStats{Count: timespends.Count, Duration: time.Duration(timespends.Sum)},
+		"moneyspends": echo.Map{"count": moneyspends.Count},
+		"incomes":     echo.Map{"count": incomes.Count},
+		"groups":      echo.Map{"count": len(groups)},
+	})
+}
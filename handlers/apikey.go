@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHandler manages the API keys of the user, its routes accept access
+// tokens only so a key can't make other keys.
+type APIKeyHandler struct {
+	apiKeyStore db.APIKeyStore
+}
+
+func NewAPIKeyHandler(apiKeyStore db.APIKeyStore) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyStore: apiKeyStore,
+	}
+}
+
+func (h APIKeyHandler) GetAllAPIKeys(ctx echo.Context) error {
+	q := db.Query{}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.apiKeyStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"tokens": page.Items, "next": page.Next})
+}
+
+// PostAPIKey creates an API key, the key itself is returned only once.
+func (h APIKeyHandler) PostAPIKey(ctx echo.Context) error {
+	params, err := utils.DecodeBody[types.CreateAPIKeyParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	now := time.Now()
+	errs := params.Validate()
+	if !params.ExpiresAt.IsZero() && !params.ExpiresAt.After(now) {
+		errs["expiresAt"] = "expiresAt should be in the future"
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	token, err := utils.NewToken(types.APIKeyPrefix)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	key := types.NewAPIKeyFromParams(params, token, utils.HashToken(token), now)
+	key.OwnerID = middleware.GetUserIDFromRequest(ctx.Request())
+	id, err := h.apiKeyStore.Create(ctx.Request().Context(), key)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id, "token": token})
+}
+
+// DeleteAPIKey revokes an API key.
+func (h APIKeyHandler) DeleteAPIKey(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := h.apiKeyStore.Delete(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
@@ -2,23 +2,38 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/SpectralJager/spender/db"
 	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/tenant"
 	"github.com/SpectralJager/spender/types"
 	"github.com/SpectralJager/spender/utils"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// refreshTokenTTL is how long a session lasts without being refreshed.
+	refreshTokenTTL    = 30 * 24 * time.Hour
+	refreshTokenPrefix = "spr_"
+
+	tokenHashField  = "tokenHash"
+	usedHashesField = "usedHashes"
+	expiresAtField  = "expiresAt"
+)
+
 type AuthHandler struct {
-	userStore db.UserStore
+	userStore    db.UserStore
+	sessionStore db.SessionStore
 }
 
-func NewAuthHandler(userStore db.UserStore) *AuthHandler {
+func NewAuthHandler(userStore db.UserStore, sessionStore db.SessionStore) *AuthHandler {
 	return &AuthHandler{
-		userStore: userStore,
+		userStore:    userStore,
+		sessionStore: sessionStore,
 	}
 }
 
@@ -38,17 +53,22 @@ func (h AuthHandler) Authenticate(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	tokenStr, err := middleware.NewJWTTokenString(user.ID)
-	if err != nil {
+	if user.Disabled {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "user account is disabled"})
+	}
+
+	if err := h.pruneSessions(ctx.Request().Context(), user.ID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	ctx.Response().Header().Set("X-Api-Token", tokenStr)
+	if err := h.startSession(ctx, user); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done"})
 }
 
-func (h UserHandler) Register(ctx echo.Context) error {
+func (h AuthHandler) Register(ctx echo.Context) error {
 	params, err := utils.DecodeBody[types.CreateUserParams](ctx.Request().Body)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
@@ -66,17 +86,166 @@ func (h UserHandler) Register(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "such email already in use"})
 	}
 
-	userID, err := h.userStore.Create(context.TODO(), user)
+	user.ID, err = h.userStore.Create(context.TODO(), user)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	tokenStr, err := middleware.NewJWTTokenString(userID)
-	if err != nil {
+	if err := h.startSession(ctx, user); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	ctx.Response().Header().Set("X-Api-Token", tokenStr)
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done"})
+}
 
+// Refresh rotates the refresh token of a session and issues a new access
+// token. A refresh token is good for one refresh only, presenting one again
+// means it leaked, so the whole session is revoked.
+func (h AuthHandler) Refresh(ctx echo.Context) error {
+	params, err := utils.DecodeBody[types.RefreshParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := tenant.WithSystem(ctx.Request().Context())
+	hash := utils.HashToken(params.RefreshToken)
+	page, err := h.sessionStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: tokenHashField, Op: db.OpEq, Value: hash}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(page.Items) == 0 {
+		return h.refuseRefresh(ctx, c, hash)
+	}
+	session := page.Items[0]
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		if err := h.sessionStore.Delete(c, session.ID); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "refresh token is expired"})
+	}
+	user, err := h.userStore.GetByID(c, session.OwnerID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if user.Disabled {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "user account is disabled"})
+	}
+
+	refreshToken, err := utils.NewToken(refreshTokenPrefix)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	// the token is replaced only while it is the one read, of concurrent
+	// refreshes with the same token one wins and the others revoke the
+	// session like a reuse does
+	err = h.sessionStore.UpdateIf(c, session.ID,
+		[]db.Cond{{Field: tokenHashField, Op: db.OpEq, Value: hash}},
+		session.Rotate(utils.HashToken(refreshToken), now, refreshTokenTTL),
+	)
+	if errors.Is(err, db.ErrNoMatch) {
+		return h.revokeSession(ctx, c, session.ID)
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := setTokens(ctx, user, session.ID, refreshToken); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done"})
 }
+
+// refuseRefresh refuses the refresh token hashed to hash, which is either
+// unknown or already used, and revokes the session it was used in.
+func (h AuthHandler) refuseRefresh(ctx echo.Context, c context.Context, hash string) error {
+	page, err := h.sessionStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: usedHashesField, Op: db.OpAny, Value: []string{hash}}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(page.Items) == 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "invalid refresh token"})
+	}
+	return h.revokeSession(ctx, c, page.Items[0].ID)
+}
+
+// revokeSession deletes the session with id whose refresh token was used
+// again, a concurrent revocation may have deleted it already.
+func (h AuthHandler) revokeSession(ctx echo.Context, c context.Context, id string) error {
+	if err := h.sessionStore.Delete(c, id); err != nil {
+		if _, getErr := h.sessionStore.GetByID(c, id); !errors.Is(getErr, db.ErrNotFound) {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "refresh token was already used, the session is revoked"})
+}
+
+// Logout ends the session the request was authenticated in, its access and
+// refresh tokens are refused from then on.
+func (h AuthHandler) Logout(ctx echo.Context) error {
+	id := middleware.GetSessionID(ctx)
+	if err := h.sessionStore.Delete(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// LogoutAll ends every session of the user.
+func (h AuthHandler) LogoutAll(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	sessions, err := queryAll(c, h.sessionStore, db.Query{})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, session := range sessions {
+		if err := h.sessionStore.Delete(c, session.ID); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "sessions": len(sessions)})
+}
+
+// startSession starts a session of user and sets its tokens on the response.
+func (h AuthHandler) startSession(ctx echo.Context, user types.User) error {
+	refreshToken, err := utils.NewToken(refreshTokenPrefix)
+	if err != nil {
+		return err
+	}
+	session := types.NewSession(user.ID, utils.HashToken(refreshToken), time.Now(), refreshTokenTTL)
+	id, err := h.sessionStore.Create(ctx.Request().Context(), session)
+	if err != nil {
+		return err
+	}
+	return setTokens(ctx, user, id, refreshToken)
+}
+
+// pruneSessions deletes the expired sessions of the user with userID.
+func (h AuthHandler) pruneSessions(ctx context.Context, userID string) error {
+	ctx = tenant.WithOwner(ctx, userID)
+	expired, err := queryAll(ctx, h.sessionStore, db.Query{
+		Conds: []db.Cond{{Field: expiresAtField, Op: db.OpLt, Value: time.Now()}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, session := range expired {
+		if err := h.sessionStore.Delete(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setTokens(ctx echo.Context, user types.User, sessionID, refreshToken string) error {
+	tokenStr, err := middleware.NewJWTTokenString(user, sessionID)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set("X-Api-Token", tokenStr)
+	ctx.Response().Header().Set("X-Refresh-Token", refreshToken)
+	return nil
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	budgetOK     = "ok"
+	budgetAtRisk = "at-risk"
+	budgetOver   = "over"
+)
+
+type BudgetHandler struct {
+	budgetStore   db.BudgetStore
+	categoryStore db.CategoryStore
+	userStore     db.UserStore
+}
+
+func NewBudgetHandler(budgetStore db.BudgetStore, categoryStore db.CategoryStore, userStore db.UserStore) *BudgetHandler {
+	return &BudgetHandler{
+		budgetStore:   budgetStore,
+		categoryStore: categoryStore,
+		userStore:     userStore,
+	}
+}
+
+func (h BudgetHandler) GetAllBudgets(ctx echo.Context) error {
+	budgets, err := h.budgetStore.GetAll(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"budgets": budgets})
+}
+
+func (h BudgetHandler) PostBudget(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateBudgetParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	params.Currency = strings.ToUpper(params.Currency)
+	if params.Kind == types.BudgetMoney && len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, ownerID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	errs := params.Validate()
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	budget, err := types.NewBudgetFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	budget.OwnerID = ownerID
+	id, err := h.budgetStore.Create(c, budget)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h BudgetHandler) GetBudget(ctx echo.Context) error {
+	id := ctx.Param("id")
+	budget, err := h.budgetStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"budget": budget})
+}
+
+func (h BudgetHandler) PutBudget(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateBudgetParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	budget, err := h.budgetStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Kind = budget.Kind
+	params.Currency = strings.ToUpper(params.Currency)
+	if budget.Kind == types.BudgetMoney && len(params.Currency) == 0 {
+		params.Currency = budget.Currency
+	}
+	errs := params.Validate()
+	// stored minor units would mean another limit in another currency
+	if budget.Kind == types.BudgetMoney && params.Currency != budget.Currency && len(params.Money) == 0 {
+		errs["money"] = "money should be set when currency changes"
+	}
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.budgetStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h BudgetHandler) DeleteBudget(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := h.budgetStore.Delete(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// budgetStatus compares the spends of the current period of a budget with
+// its limit. Spent, Limit, Remaining and Projected are amounts of money
+// budgets and durations of time budgets.
+type budgetStatus struct {
+	Budget    types.Budget `json:"budget"`
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Spent     any          `json:"spent"`
+	Limit     any          `json:"limit"`
+	Remaining any          `json:"remaining"`
+	Percent   float64      `json:"percent"`
+	Projected any          `json:"projected"`
+	Status    string       `json:"status"`
+	Count     int64        `json:"count"`
+	// Unconverted moneyspends are left out of Spent.
+	Unconverted []unconverted `json:"unconverted,omitempty"`
+}
+
+// GetBudgets reports every budget for its period containing the date day,
+// today by default, in the tz time zone, UTC by default. Spends are counted
+// from the start of the period until the end of the day and projected to
+// the end of the period at the same pace. A budget is over when more than
+// its limit is spent, and at risk when the projection is more than it.
+func (h ReportHandler) GetBudgets(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	day, loc, err := dayParams(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	budgets, err := h.budgetStore.GetAll(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	categories, err := h.categoryStore.GetAll(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	parents := map[string]string{}
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	report := []budgetStatus{}
+	for _, budget := range budgets {
+		status, err := h.budgetStatus(c, budget, day, parents)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		report = append(report, status)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Percent != report[j].Percent {
+			return report[i].Percent > report[j].Percent
+		}
+		return report[i].Budget.Name < report[j].Budget.Name
+	})
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"date":     day.Format(dateLayout),
+		"timezone": loc.String(),
+		"budgets":  report,
+	})
+}
+
+func (h ReportHandler) budgetStatus(ctx context.Context, budget types.Budget, day time.Time, parents map[string]string) (budgetStatus, error) {
+	boundaries, err := bucketBoundaries(budget.Period, day, day)
+	if err != nil {
+		return budgetStatus{}, err
+	}
+	start, end := boundaries[0], boundaries[1]
+	until := day.AddDate(0, 0, 1)
+	if until.After(end) {
+		until = end
+	}
+	a := db.Aggregation{
+		Conds: []db.Cond{
+			{Field: dateField, Op: db.OpGte, Value: start},
+			{Field: dateField, Op: db.OpLt, Value: until},
+		},
+	}
+	if len(budget.Keyword) != 0 {
+		a.Conds = append(a.Conds, db.Cond{Field: noteField, Op: db.OpContains, Value: budget.Keyword})
+	}
+	if len(budget.CategoryID) != 0 {
+		a.GroupBy = categoryField
+	}
+
+	status := budgetStatus{Budget: budget, Start: start, End: end}
+	var aggregates []db.Aggregate
+	var limit int64
+	var value func(int64) any
+	if budget.Kind == types.BudgetMoney {
+		a.Field = "money"
+		aggregates, status.Unconverted, err = h.aggregateMoney(ctx, h.moneyspendStore, budget.Currency, a)
+		limit = int64(budget.Money)
+		value = func(v int64) any { return types.Amount{Money: types.Money(v), Currency: budget.Currency} }
+	} else {
+		a.Field = "duration"
+		aggregates, err = h.timespendStore.Aggregate(ctx, a)
+		limit = int64(budget.Duration)
+		value = func(v int64) any { return time.Duration(v) }
+	}
+	if err != nil {
+		return budgetStatus{}, err
+	}
+
+	var spent int64
+	for _, aggregate := range aggregates {
+		if len(budget.CategoryID) != 0 {
+			id, _ := aggregate.Group.(string)
+			if !inCategory(id, budget.CategoryID, parents) {
+				continue
+			}
+		}
+		spent += aggregate.Sum
+		status.Count += aggregate.Count
+	}
+	projected := spent
+	if elapsed := until.Sub(start); elapsed > 0 {
+		projected = int64(math.Round(float64(spent) * float64(end.Sub(start)) / float64(elapsed)))
+	}
+	status.Spent = value(spent)
+	status.Limit = value(limit)
+	status.Remaining = value(limit - spent)
+	status.Projected = value(projected)
+	status.Percent = percent(float64(spent), float64(limit))
+	switch {
+	case spent > limit:
+		status.Status = budgetOver
+	case projected > limit:
+		status.Status = budgetAtRisk
+	default:
+		status.Status = budgetOK
+	}
+	return status, nil
+}
+
+// dayParams reads the start of the date day, today by default, in the tz
+// time zone, UTC by default.
+func dayParams(ctx echo.Context) (time.Time, *time.Location, error) {
+	loc, err := time.LoadLocation(ctx.QueryParam("tz"))
+	if err != nil {
+		return time.Time{}, nil, errors.New("tz should be an IANA time zone")
+	}
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if param := ctx.QueryParam("date"); len(param) != 0 {
+		day, err = time.ParseInLocation(dateLayout, param, loc)
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("date should be a date like %s", dateLayout)
+		}
+	}
+	return day, loc, nil
+}
+
+// inCategory reports whether the category id is ancestor or one of its
+// descendants.
+func inCategory(id, ancestor string, parents map[string]string) bool {
+	seen := map[string]bool{}
+	for len(id) != 0 && !seen[id] {
+		if id == ancestor {
+			return true
+		}
+		seen[id] = true
+		id = parents[id]
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+type cashflowBucket struct {
+	Label           string       `json:"label"`
+	Start           time.Time    `json:"start"`
+	End             time.Time    `json:"end"`
+	Income          types.Amount `json:"income"`
+	Expenses        types.Amount `json:"expenses"`
+	Net             types.Amount `json:"net"`
+	IncomeCount     int64        `json:"incomeCount"`
+	MoneyspendCount int64        `json:"moneyspendCount"`
+}
+
+// GetCashflow totals incomes, expenses and their difference per period, with
+// the parameters of GetSeries. Money is converted like in GetTotalSpend.
+func (h ReportHandler) GetCashflow(ctx echo.Context) error {
+	c := ctx.Request().Context()
+
+	unit, loc, boundaries, err := seriesRange(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	currency, err := h.reportCurrency(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	zero := types.Amount{Currency: currency}
+	series := make([]cashflowBucket, len(boundaries)-1)
+	index := map[int64]int{}
+	for i := range series {
+		series[i] = cashflowBucket{
+			Label:    bucketLabel(unit, boundaries[i]),
+			Start:    boundaries[i],
+			End:      boundaries[i+1],
+			Income:   zero,
+			Expenses: zero,
+			Net:      zero,
+		}
+		index[boundaries[i].UnixMilli()] = i
+	}
+	aggregation := db.Aggregation{
+		Field:    "money",
+		BucketBy: dateField,
+		Buckets:  boundaries,
+	}
+	incomes, missingIncomes, err := h.aggregateMoney(c, h.incomeStore, currency, aggregation)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	monies, missingMonies, err := h.aggregateMoney(c, h.moneyspendStore, currency, aggregation)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	totalIncome, totalExpenses := zero, zero
+	for _, aggregate := range incomes {
+		if i, ok := index[aggregate.Bucket.UnixMilli()]; ok {
+			series[i].Income = amount(aggregate.Sum, currency)
+			series[i].IncomeCount = aggregate.Count
+			totalIncome.Money += series[i].Income.Money
+		}
+	}
+	for _, aggregate := range monies {
+		if i, ok := index[aggregate.Bucket.UnixMilli()]; ok {
+			series[i].Expenses = amount(aggregate.Sum, currency)
+			series[i].MoneyspendCount = aggregate.Count
+			totalExpenses.Money += series[i].Expenses.Money
+		}
+	}
+	for i := range series {
+		series[i].Net.Money = series[i].Income.Money - series[i].Expenses.Money
+	}
+	net := types.Amount{Money: totalIncome.Money - totalExpenses.Money, Currency: currency}
+
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"unit":     unit,
+		"timezone": loc.String(),
+		"currency": currency,
+		"series":   series,
+		"income":   totalIncome,
+		"expenses": totalExpenses,
+		"net":      net,
+		"unconverted": echo.Map{
+			"income":   missingIncomes,
+			"expenses": missingMonies,
+		},
+	})
+}
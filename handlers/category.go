@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	categoryField = "categoryId"
+	parentField   = "parentId"
+)
+
+type CategoryHandler struct {
+	categoryStore   db.CategoryStore
+	timespendStore  db.SpendStor[types.Timespend]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	incomeStore     db.SpendStor[types.Income]
+	budgetStore     db.BudgetStore
+	recurringStore  db.RecurringStore
+	timerStore      db.TimerStore
+}
+
+func NewCategoryHandler(categoryStore db.CategoryStore, timespendStore db.SpendStor[types.Timespend], moneyspendStore db.SpendStor[types.Moneyspend], incomeStore db.SpendStor[types.Income], budgetStore db.BudgetStore, recurringStore db.RecurringStore, timerStore db.TimerStore) *CategoryHandler {
+	return &CategoryHandler{
+		categoryStore:   categoryStore,
+		timespendStore:  timespendStore,
+		moneyspendStore: moneyspendStore,
+		incomeStore:     incomeStore,
+		budgetStore:     budgetStore,
+		recurringStore:  recurringStore,
+		timerStore:      timerStore,
+	}
+}
+
+func (h CategoryHandler) GetAllCategories(ctx echo.Context) error {
+	categories, err := h.categoryStore.GetAll(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"categories": categories})
+}
+
+func (h CategoryHandler) PostCategory(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateCategoryParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	errs := params.Validate()
+	if err := validateCategory(c, h.categoryStore, parentField, params.ParentID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	category := types.NewCategoryFromParams(params)
+	category.OwnerID = ownerID
+	id, err := h.categoryStore.Create(c, category)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h CategoryHandler) GetCategory(ctx echo.Context) error {
+	id := ctx.Param("id")
+	category, err := h.categoryStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"category": category})
+}
+
+func (h CategoryHandler) PutCategory(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateCategoryParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	errs := params.Validate()
+	if err := validateCategory(c, h.categoryStore, parentField, params.Parent(), errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if _, ok := errs[parentField]; !ok && len(params.Parent()) != 0 {
+		cycle, err := isDescendant(c, h.categoryStore, params.Parent(), id)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		if cycle {
+			errs[parentField] = "category can't be nested into itself"
+		}
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.categoryStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// DeleteCategory refuses to delete categories that still have subcategories,
+// spends, incomes, budgets, recurring rules or a running timer, so nothing
+// is left pointing to a missing category.
+func (h CategoryHandler) DeleteCategory(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	children, err := h.categoryStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: parentField, Op: db.OpEq, Value: id}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(children.Items) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category has subcategories"})
+	}
+	conds := []db.Cond{{Field: categoryField, Op: db.OpEq, Value: id}}
+	timeStats, err := aggregateTotal(c, h.timespendStore, "duration", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	moneyStats, err := aggregateTotal(c, h.moneyspendStore, "money", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	incomeStats, err := aggregateTotal(c, h.incomeStore, "money", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if timeStats.Count != 0 || moneyStats.Count != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category is used by spends"})
+	}
+	if incomeStats.Count != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category is used by incomes"})
+	}
+	budgets, err := h.budgetStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: categoryField, Op: db.OpEq, Value: id}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(budgets.Items) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category is used by budgets"})
+	}
+	rules, err := h.recurringStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: categoryField, Op: db.OpEq, Value: id}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(rules.Items) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category is used by recurring rules"})
+	}
+	timers, err := h.timerStore.Query(c, db.Query{
+		Conds: []db.Cond{{Field: categoryField, Op: db.OpEq, Value: id}},
+		Limit: 1,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(timers.Items) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "category is used by the running timer"})
+	}
+	if err := h.categoryStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// validateCategory adds a validation error for field to errs unless id is
+// empty or one of the owner's categories.
+func validateCategory(ctx context.Context, store db.CategoryStore, field, id string, errs map[string]string) error {
+	if len(id) == 0 {
+		return nil
+	}
+	_, err := store.GetByID(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		errs[field] = fmt.Sprintf("category with id = %s doesn't exist", id)
+		return nil
+	}
+	return err
+}
+
+// isDescendant reports whether the category id is ancestor itself or one of
+// its descendants, following parents up from id.
+func isDescendant(ctx context.Context, store db.CategoryStore, id, ancestor string) (bool, error) {
+	seen := map[string]bool{}
+	for len(id) != 0 && !seen[id] {
+		if id == ancestor {
+			return true, nil
+		}
+		seen[id] = true
+		category, err := store.GetByID(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		id = category.ParentID
+	}
+	return false, nil
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/exchange"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const currencyField = "currency"
+
+// maxTime closes the last rate period of a series.
+var maxTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+type ExchangeRateHandler struct {
+	rateStore db.ExchangeRateStore
+}
+
+func NewExchangeRateHandler(rateStore db.ExchangeRateStore) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		rateStore: rateStore,
+	}
+}
+
+// GetRate returns the rate converting the from to the to currency valid on
+// the date day, today by default.
+func (h ExchangeRateHandler) GetRate(ctx echo.Context) error {
+	from := strings.ToUpper(ctx.QueryParam("from"))
+	to := strings.ToUpper(ctx.QueryParam("to"))
+	if !types.IsCurrency(from) || !types.IsCurrency(to) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "from and to should be ISO 4217 codes"})
+	}
+	date := time.Now().UTC()
+	if param := ctx.QueryParam("date"); len(param) != 0 {
+		var err error
+		date, err = time.Parse(dateLayout, param)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": fmt.Sprintf("date should be a date like %s", dateLayout)})
+		}
+		// rates of the day are valid during the whole day
+		date = date.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+	series, err := exchange.Lookup(ctx.Request().Context(), h.rateStore, from, to)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	rate, ok := series.At(date)
+	if !ok {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": fmt.Sprintf("no rate from %s to %s on %s", from, to, date.Format(dateLayout))})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"from": from, "to": to, "date": date.Format(dateLayout), "rate": rate})
+}
+
+// unconverted sums the entries of a currency without a rate to the report
+// currency on their dates.
+type unconverted struct {
+	Currency string       `json:"currency"`
+	Money    types.Amount `json:"money"`
+	Count    int64        `json:"count"`
+}
+
+// amount returns minor units of currency as an amount.
+func amount(money int64, currency string) types.Amount {
+	return types.Amount{Money: types.Money(money), Currency: currency}
+}
+
+// convertedAggregate sums an aggregate converted at several rates, it keeps
+// the fractions of minor units until the end.
+type convertedAggregate struct {
+	group  any
+	bucket time.Time
+	count  int64
+	sum    float64
+	min    float64
+	max    float64
+}
+
+func (c *convertedAggregate) add(aggregate db.Aggregate, rate float64) {
+	if c.count == 0 {
+		c.min, c.max = float64(aggregate.Min)*rate, float64(aggregate.Max)*rate
+	}
+	c.count += aggregate.Count
+	c.sum += float64(aggregate.Sum) * rate
+	c.min = min(c.min, float64(aggregate.Min)*rate)
+	c.max = max(c.max, float64(aggregate.Max)*rate)
+}
+
+// aggregate rounds the converted sums to minor units.
+func (c *convertedAggregate) aggregate() db.Aggregate {
+	return db.Aggregate{
+		Group:  c.group,
+		Bucket: c.bucket,
+		Count:  c.count,
+		Sum:    int64(math.Round(c.sum)),
+		Min:    int64(math.Round(c.min)),
+		Max:    int64(math.Round(c.max)),
+		Avg:    c.sum / float64(c.count),
+	}
+}
+
+// reportCurrency returns the currency query parameter, or the base currency
+// of the user without it.
+func (h ReportHandler) reportCurrency(ctx echo.Context) (string, error) {
+	if currency := strings.ToUpper(ctx.QueryParam(currencyField)); len(currency) != 0 {
+		if !types.IsCurrency(currency) {
+			return "", fmt.Errorf("currency %q should be an ISO 4217 code", currency)
+		}
+		return currency, nil
+	}
+	user, err := h.userStore.GetByID(ctx.Request().Context(), middleware.GetUserIDFromRequest(ctx.Request()))
+	if err != nil {
+		return "", err
+	}
+	return user.Currency(), nil
+}
+
+// aggregateMoney runs a money aggregation over store, moneyspends or
+// incomes, with every entry converted to minor units of base at the rate
+// valid on its date. Buckets, if any, should be on the date field. Entries
+// without a rate are left out and summed apart.
+func (h ReportHandler) aggregateMoney(ctx context.Context, store db.Aggregator, base string, a db.Aggregation) ([]db.Aggregate, []unconverted, error) {
+	if len(a.BucketBy) != 0 && a.BucketBy != dateField {
+		return nil, nil, fmt.Errorf("money can only be bucketed by %s", dateField)
+	}
+	currencies, err := store.Aggregate(ctx, db.Aggregation{
+		Field:   a.Field,
+		Conds:   a.Conds,
+		GroupBy: currencyField,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type key struct {
+		group  any
+		bucket int64
+	}
+	results := map[key]*convertedAggregate{}
+	keys := []key{}
+	add := func(aggregate db.Aggregate, bucket time.Time, rate float64) {
+		k := key{group: aggregate.Group, bucket: bucket.UnixMilli()}
+		result, ok := results[k]
+		if !ok {
+			result = &convertedAggregate{group: aggregate.Group, bucket: bucket}
+			results[k] = result
+			keys = append(keys, k)
+		}
+		result.add(aggregate, rate)
+	}
+	missing := []unconverted{}
+
+	for _, group := range currencies {
+		// moneyspends stored before currencies were introduced have none
+		currency, _ := group.Group.(string)
+		if len(currency) == 0 {
+			currency = types.DefaultCurrency
+		}
+		conds := append(append([]db.Cond{}, a.Conds...), db.Cond{Field: currencyField, Op: db.OpEq, Value: group.Group})
+		if currency == base {
+			converted := a
+			converted.Conds = conds
+			aggregates, err := store.Aggregate(ctx, converted)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, aggregate := range aggregates {
+				if aggregate.Count != 0 {
+					add(aggregate, aggregate.Bucket, 1)
+				}
+			}
+			continue
+		}
+		series, err := exchange.Lookup(ctx, h.rateStore, currency, base)
+		if err != nil {
+			return nil, nil, err
+		}
+		first, last := time.Time{}, maxTime
+		if len(a.BucketBy) != 0 {
+			first, last = a.Buckets[0], a.Buckets[len(a.Buckets)-1]
+		}
+		// moneyspends dated before the first rate can't be converted
+		if len(series) == 0 || series[0].Start.After(first) {
+			before := last
+			if len(series) != 0 && series[0].Start.Before(last) {
+				before = series[0].Start
+			}
+			earlier := append(append([]db.Cond{}, conds...), db.Cond{Field: dateField, Op: db.OpLt, Value: before})
+			if !first.IsZero() {
+				earlier = append(earlier, db.Cond{Field: dateField, Op: db.OpGte, Value: first})
+			}
+			stats, err := aggregateTotal(ctx, store, a.Field, earlier)
+			if err != nil {
+				return nil, nil, err
+			}
+			if stats.Count != 0 {
+				missing = append(missing, unconverted{Currency: currency, Money: amount(stats.Sum, currency), Count: stats.Count})
+			}
+			if len(series) == 0 || !series[0].Start.Before(last) {
+				continue
+			}
+			first = series[0].Start
+		}
+		// rates convert whole units, minor units of the currencies may differ
+		scale := math.Pow10(types.MinorUnits(base) - types.MinorUnits(currency))
+		boundaries := rateBoundaries(a.Buckets, series, first, last)
+		for len(boundaries) > 1 {
+			window := boundaries[:min(len(boundaries), db.MaxBuckets+1)]
+			boundaries = boundaries[len(window)-1:]
+			aggregates, err := store.Aggregate(ctx, db.Aggregation{
+				Field:    a.Field,
+				Conds:    conds,
+				GroupBy:  a.GroupBy,
+				Unwind:   a.Unwind,
+				BucketBy: dateField,
+				Buckets:  window,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, aggregate := range aggregates {
+				rate, _ := series.At(aggregate.Bucket)
+				var bucket time.Time
+				if len(a.BucketBy) != 0 {
+					i := sort.Search(len(a.Buckets), func(i int) bool {
+						return a.Buckets[i].After(aggregate.Bucket)
+					})
+					bucket = a.Buckets[i-1].UTC()
+				}
+				add(aggregate, bucket, rate*scale)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		gi, gj := fmt.Sprint(keys[i].group), fmt.Sprint(keys[j].group)
+		if gi != gj {
+			return gi < gj
+		}
+		return keys[i].bucket < keys[j].bucket
+	})
+	aggregates := []db.Aggregate{}
+	for _, k := range keys {
+		aggregates = append(aggregates, results[k].aggregate())
+	}
+	if !a.Grouped() && len(aggregates) == 0 {
+		aggregates = append(aggregates, db.Aggregate{})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Currency < missing[j].Currency
+	})
+	return aggregates, missing, nil
+}
+
+// rateBoundaries merges the report buckets, if any, with the rate periods of
+// series from first until last, so every merged bucket lies in one report
+// bucket and one rate period.
+func rateBoundaries(buckets []time.Time, series exchange.Series, first, last time.Time) []time.Time {
+	starts := []time.Time{first, last}
+	for _, boundary := range buckets {
+		if boundary.After(first) && boundary.Before(last) {
+			starts = append(starts, boundary)
+		}
+	}
+	for _, period := range series {
+		if period.Start.After(first) && period.Start.Before(last) {
+			starts = append(starts, period.Start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+	boundaries := []time.Time{}
+	for _, start := range starts {
+		if len(boundaries) == 0 || boundaries[len(boundaries)-1].Before(start) {
+			boundaries = append(boundaries, start)
+		}
+	}
+	return boundaries
+}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	membersField = "members"
+	invitedField = "invited"
+
+	settlementNote = "settle up"
+)
+
+// GroupHandler serves groups and their expenses. Routes of a group go
+// through middleware.GroupMembership, so its expense store is scoped to the
+// group.
+type GroupHandler struct {
+	groupStore        db.GroupStore
+	groupExpenseStore db.GroupExpenseStore
+	userStore         db.UserStore
+}
+
+func NewGroupHandler(groupStore db.GroupStore, groupExpenseStore db.GroupExpenseStore, userStore db.UserStore) *GroupHandler {
+	return &GroupHandler{
+		groupStore:        groupStore,
+		groupExpenseStore: groupExpenseStore,
+		userStore:         userStore,
+	}
+}
+
+// GetAllGroups lists the groups the user is a member of.
+func (h GroupHandler) GetAllGroups(ctx echo.Context) error {
+	return h.listGroups(ctx, membersField)
+}
+
+// GetInvitations lists the groups the user is invited to.
+func (h GroupHandler) GetInvitations(ctx echo.Context) error {
+	return h.listGroups(ctx, invitedField)
+}
+
+func (h GroupHandler) listGroups(ctx echo.Context, field string) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	q := db.Query{Conds: []db.Cond{{Field: field, Op: db.OpAny, Value: []string{userID}}}}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.groupStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"groups": page.Items, "next": page.Next})
+}
+
+func (h GroupHandler) PostGroup(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateGroupParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, userID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	id, err := h.groupStore.Create(c, types.NewGroupFromParams(params, userID))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h GroupHandler) GetGroup(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, echo.Map{"group": middleware.GetGroup(ctx)})
+}
+
+func (h GroupHandler) PutGroup(ctx echo.Context) error {
+	group := middleware.GetGroup(ctx)
+	params, err := utils.DecodeBody[types.UpdateGroupParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	if err := h.groupStore.Update(ctx.Request().Context(), group.ID, params); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": group.ID})
+}
+
+// DeleteGroup deletes the group with its expenses, only the user who
+// created the group can delete it.
+func (h GroupHandler) DeleteGroup(ctx echo.Context) error {
+	group := middleware.GetGroup(ctx)
+	if group.CreatedBy != middleware.GetUserIDFromRequest(ctx.Request()) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "only the creator of the group can delete it"})
+	}
+	if err := deleteGroup(ctx.Request().Context(), h.groupStore, h.groupExpenseStore, group); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": group.ID})
+}
+
+// PostInvitation invites the user registered with an email to the group.
+func (h GroupHandler) PostInvitation(ctx echo.Context) error {
+	group := middleware.GetGroup(ctx)
+	params, err := utils.DecodeBody[types.InviteParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	errs := map[string]string{}
+	user, err := h.userStore.GetByEmail(c, params.Email)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		errs["email"] = fmt.Sprintf("user with email = %s doesn't exist", params.Email)
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	case group.IsMember(user.ID):
+		errs["email"] = "user is already a member of the group"
+	case group.IsInvited(user.ID):
+		errs["email"] = "user is already invited to the group"
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.groupStore.UpdateSets(c, group.ID, nil, []db.SetChange{{Field: invitedField, Value: user.ID}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": group.ID})
+}
+
+// PostJoin accepts the invitation of the user to the group, it is the only
+// group route open to users who are not members.
+func (h GroupHandler) PostJoin(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	// the invitation is checked by the update, so it is accepted once
+	err := h.groupStore.UpdateSets(c, id,
+		[]db.Cond{{Field: invitedField, Op: db.OpAny, Value: []string{userID}}},
+		[]db.SetChange{{Field: invitedField, Value: userID, Remove: true}, {Field: membersField, Value: userID}},
+	)
+	if errors.Is(err, db.ErrNoMatch) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "user is not invited to the group"})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// PostLeave removes the user from the group once the balance of the user is
+// settled. The creator of the group deletes it instead.
+func (h GroupHandler) PostLeave(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	group := middleware.GetGroup(ctx)
+	if group.CreatedBy == userID {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "the creator of the group can't leave it"})
+	}
+	c := ctx.Request().Context()
+	expenses, err := queryAll(c, h.groupExpenseStore, db.Query{})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, balance := range types.GroupBalances(group.Members, expenses) {
+		if balance.UserID == userID && balance.Balance != 0 {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "balance of the user should be settled up before leaving"})
+		}
+	}
+	err = h.groupStore.UpdateSets(c, group.ID, nil, []db.SetChange{{Field: membersField, Value: userID, Remove: true}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": group.ID})
+}
+
+// GetAllExpenses lists the expenses of the group dated from the optional
+// from day up to and including the to day.
+func (h GroupHandler) GetAllExpenses(ctx echo.Context) error {
+	conds, err := dateRange(ctx, "from", "to")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	q := db.Query{Conds: conds, Sort: dateField}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.groupExpenseStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"expenses": page.Items, "next": page.Next})
+}
+
+// PostExpense splits an expense paid by a member among members of the
+// group, equally among all of them by default.
+func (h GroupHandler) PostExpense(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	group := middleware.GetGroup(ctx)
+	params, err := utils.DecodeBody[types.CreateGroupExpenseParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = group.Currency
+	if len(params.PayerID) == 0 {
+		params.PayerID = userID
+	}
+	if len(params.Split) == 0 {
+		params.Split = types.SplitEqual
+	}
+	if len(params.Shares) == 0 && params.Split == types.SplitEqual {
+		for _, member := range group.Members {
+			params.Shares = append(params.Shares, types.ShareParams{UserID: member})
+		}
+	}
+	errs := params.Validate()
+	validateMembers(group, params.PayerID, params.Shares, errs)
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	expense, err := types.NewGroupExpenseFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return h.createExpense(ctx, group, userID, expense)
+}
+
+// PostSettle records a payment from the user to another member, it is
+// an expense of the user owed by that member.
+func (h GroupHandler) PostSettle(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	group := middleware.GetGroup(ctx)
+	params, err := utils.DecodeBody[types.SettleParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if params.Date.IsZero() {
+		params.Date = time.Now().UTC()
+	}
+	expenseParams := types.CreateGroupExpenseParams{
+		PayerID:  userID,
+		Money:    params.Money,
+		Currency: group.Currency,
+		Date:     params.Date,
+		Note:     settlementNote,
+		Split:    types.SplitExact,
+		Shares:   []types.ShareParams{{UserID: params.To, Value: params.Money}},
+	}
+	errs := expenseParams.Validate()
+	if params.To == userID {
+		errs["to"] = "user can't settle up with themselves"
+	} else if !group.IsMember(params.To) {
+		errs["to"] = fmt.Sprintf("user with id = %s is not a member of the group", params.To)
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	expense, err := types.NewGroupExpenseFromParams(expenseParams)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	expense.Settlement = true
+	return h.createExpense(ctx, group, userID, expense)
+}
+
+func (h GroupHandler) createExpense(ctx echo.Context, group types.Group, userID string, expense types.GroupExpense) error {
+	expense.GroupID = group.ID
+	expense.CreatedBy = userID
+	id, err := h.groupExpenseStore.Create(ctx.Request().Context(), expense)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h GroupHandler) GetExpense(ctx echo.Context) error {
+	id := ctx.Param("expenseId")
+	expense, err := h.groupExpenseStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"expense": expense})
+}
+
+// DeleteExpense deletes an expense of the group, only the member who added
+// it, its payer and the creator of the group can delete it.
+func (h GroupHandler) DeleteExpense(ctx echo.Context) error {
+	userID := middleware.GetUserIDFromRequest(ctx.Request())
+	group := middleware.GetGroup(ctx)
+	id := ctx.Param("expenseId")
+	c := ctx.Request().Context()
+	expense, err := h.groupExpenseStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if userID != expense.CreatedBy && userID != expense.PayerID && userID != group.CreatedBy {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "only the member who added the expense, its payer and the creator of the group can delete it"})
+	}
+	if err := h.groupExpenseStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+type memberBalance struct {
+	UserID  string       `json:"userId"`
+	Paid    types.Amount `json:"paid"`
+	Owed    types.Amount `json:"owed"`
+	Balance types.Amount `json:"balance"`
+}
+
+type settleTransfer struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Amount types.Amount `json:"amount"`
+}
+
+// GetBalances returns what every member paid for the group and owes to it,
+// and the transfers settling up the balances.
+func (h GroupHandler) GetBalances(ctx echo.Context) error {
+	group := middleware.GetGroup(ctx)
+	expenses, err := queryAll(ctx.Request().Context(), h.groupExpenseStore, db.Query{})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	amount := func(money types.Money) types.Amount {
+		return types.Amount{Money: money, Currency: group.Currency}
+	}
+	balances := types.GroupBalances(group.Members, expenses)
+	report := []memberBalance{}
+	for _, balance := range balances {
+		report = append(report, memberBalance{
+			UserID:  balance.UserID,
+			Paid:    amount(balance.Paid),
+			Owed:    amount(balance.Owed),
+			Balance: amount(balance.Balance),
+		})
+	}
+	transfers := []settleTransfer{}
+	for _, transfer := range types.SettleUp(balances) {
+		transfers = append(transfers, settleTransfer{
+			From:   transfer.From,
+			To:     transfer.To,
+			Amount: amount(transfer.Amount),
+		})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"currency": group.Currency, "balances": report, "transfers": transfers})
+}
+
+// deleteGroup deletes group with its expenses.
+func deleteGroup(ctx context.Context, groupStore db.GroupStore, groupExpenseStore db.GroupExpenseStore, group types.Group) error {
+	ctx = tenant.WithGroup(ctx, group.ID)
+	expenses, err := queryAll(ctx, groupExpenseStore, db.Query{})
+	if err != nil {
+		return err
+	}
+	for _, expense := range expenses {
+		if err := groupExpenseStore.Delete(ctx, expense.ID); err != nil {
+			return err
+		}
+	}
+	return groupStore.Delete(ctx, group.ID)
+}
+
+// validateMembers adds validation errors unless the payer and the users of
+// shares are members of group.
+func validateMembers(group types.Group, payerID string, shares []types.ShareParams, errs map[string]string) {
+	if !group.IsMember(payerID) {
+		errs["payerId"] = fmt.Sprintf("user with id = %s is not a member of the group", payerID)
+	}
+	for _, share := range shares {
+		if len(share.UserID) != 0 && !group.IsMember(share.UserID) {
+			errs["shares"] = fmt.Sprintf("user with id = %s is not a member of the group", share.UserID)
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type IncomeHandler struct {
+	incomeStore   db.SpendStor[types.Income]
+	categoryStore db.CategoryStore
+	accountStore  db.AccountStore
+	userStore     db.UserStore
+}
+
+func NewIncomeHandler(incomeStore db.SpendStor[types.Income], categoryStore db.CategoryStore, accountStore db.AccountStore, userStore db.UserStore) *IncomeHandler {
+	return &IncomeHandler{
+		incomeStore:   incomeStore,
+		categoryStore: categoryStore,
+		accountStore:  accountStore,
+		userStore:     userStore,
+	}
+}
+
+// GetAllIncomes lists incomes with the filters of moneyspends.
+func (h IncomeHandler) GetAllIncomes(ctx echo.Context) error {
+	q, err := moneyQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.incomeStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"incomes": page.Items, "next": page.Next})
+}
+
+func (h IncomeHandler) PostIncome(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateIncomeParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	c := ctx.Request().Context()
+	account, err := findAccount(c, h.accountStore, params.AccountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 && len(account.ID) != 0 {
+		params.Currency = account.Currency
+	}
+	if len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, ownerID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	errs := params.Validate()
+	validateAccount(account, params.AccountID, params.Currency, errs)
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	income, err := types.NewIncomeFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	income.OwnerID = ownerID
+	id, err := h.incomeStore.Create(c, income)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h IncomeHandler) GetIncome(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	income, err := h.incomeStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"income": income})
+}
+
+func (h IncomeHandler) PutIncome(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateIncomeParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	income, err := h.incomeStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	params.Currency = strings.ToUpper(params.Currency)
+	currency := income.Currency
+	if len(params.Currency) == 0 {
+		params.Currency = currency
+	}
+	errs := params.Validate()
+	// stored minor units would mean another amount in another currency
+	if params.Currency != currency && len(params.Money) == 0 {
+		errs["money"] = "money should be set when currency changes"
+	}
+	accountID := params.AccountID
+	if len(accountID) == 0 {
+		accountID = income.AccountID
+	}
+	account, err := findAccount(c, h.accountStore, accountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	validateAccount(account, accountID, params.Currency, errs)
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.incomeStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h IncomeHandler) DeleteIncome(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	err := h.incomeStore.Delete(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/billing"
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	invoiceNumberField = "number"
+	invoiceIDField     = "invoiceId"
+	reimbursableField  = "reimbursable"
+
+	// maxInvoiceNumberTries bounds the numbers tried for an invoice while
+	// concurrent invoices take them.
+	maxInvoiceNumberTries = 5
+)
+
+var (
+	errInvoiced       = errors.New("entry is invoiced, void its invoice to change it")
+	errInvoiceChanged = errors.New("entries of the invoice were billed or deleted meanwhile, try again")
+)
+
+type InvoiceHandler struct {
+	invoiceStore    db.InvoiceStore
+	timespendStore  db.SpendStor[types.Timespend]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	userStore       db.UserStore
+}
+
+func NewInvoiceHandler(invoiceStore db.InvoiceStore, timespendStore db.SpendStor[types.Timespend], moneyspendStore db.SpendStor[types.Moneyspend], userStore db.UserStore) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceStore:    invoiceStore,
+		timespendStore:  timespendStore,
+		moneyspendStore: moneyspendStore,
+		userStore:       userStore,
+	}
+}
+
+// GetAllInvoices lists invoices from the latest one.
+func (h InvoiceHandler) GetAllInvoices(ctx echo.Context) error {
+	q := db.Query{Sort: invoiceNumberField, Desc: true}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.invoiceStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"invoices": page.Items, "next": page.Next})
+}
+
+// PostInvoice bills the uninvoiced timespends at the hourly rate and the
+// reimbursable moneyspends in the currency of the invoice, then marks them
+// as invoiced so they are not billed twice. Entries are marked only while
+// they are uninvoiced, when a concurrent request billed or deleted one of
+// them the invoice is withdrawn.
+func (h InvoiceHandler) PostInvoice(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateInvoiceParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	user, err := h.userStore.GetByID(c, ownerID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 {
+		params.Currency = user.Currency()
+	}
+	if tags := types.NormalizeTags([]string{params.Tag}); len(tags) != 0 {
+		params.Tag = tags[0]
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	invoice, err := types.NewInvoiceFromParams(params, time.Now().UTC())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	invoice.OwnerID = ownerID
+
+	conds := []db.Cond{
+		{Field: dateField, Op: db.OpGte, Value: invoice.Start},
+		{Field: dateField, Op: db.OpLt, Value: invoice.End.AddDate(0, 0, 1)},
+	}
+	if len(params.Tag) != 0 {
+		conds = append(conds, db.Cond{Field: tagsField, Op: db.OpAny, Value: []string{params.Tag}})
+	}
+	if len(params.NotePrefix) != 0 {
+		conds = append(conds, db.Cond{Field: noteField, Op: db.OpContains, Value: params.NotePrefix})
+	}
+	timespends, err := queryAll(c, h.timespendStore, db.Query{Conds: conds, Sort: dateField})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	moneyspends, err := queryAll(c, h.moneyspendStore, db.Query{
+		Conds: append(append([]db.Cond{}, conds...),
+			db.Cond{Field: reimbursableField, Op: db.OpEq, Value: true},
+			db.Cond{Field: currencyField, Op: db.OpEq, Value: invoice.Currency},
+		),
+		Sort: dateField,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	// entries stored before invoices have no invoiceId at all, so uninvoiced
+	// ones are told apart here instead of by a condition
+	for _, timespend := range timespends {
+		if len(timespend.InvoiceID) == 0 && hasNotePrefix(timespend.Note, params.NotePrefix) {
+			invoice.AddTime(timespend)
+		}
+	}
+	for _, moneyspend := range moneyspends {
+		if len(moneyspend.InvoiceID) == 0 && hasNotePrefix(moneyspend.Note, params.NotePrefix) {
+			invoice.AddExpense(moneyspend)
+		}
+	}
+	if len(invoice.Lines) == 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "there is nothing to invoice in the range"})
+	}
+
+	id, err := h.createNumbered(c, &invoice, user)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	invoice.ID = id
+	if marked, err := h.mark(c, invoice.Lines, id); err != nil {
+		if errors.Is(err, db.ErrNoMatch) {
+			err = errInvoiceChanged
+		}
+		err = errors.Join(err, h.unmark(c, invoice.Lines[:marked], id), h.invoiceStore.Delete(c, id))
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id, "invoice": invoice})
+}
+
+func (h InvoiceHandler) GetInvoice(ctx echo.Context) error {
+	id := ctx.Param("id")
+	invoice, err := h.invoiceStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"invoice": invoice})
+}
+
+// GetInvoiceDocument returns the html document stored with the invoice.
+func (h InvoiceHandler) GetInvoiceDocument(ctx echo.Context) error {
+	id := ctx.Param("id")
+	invoice, err := h.invoiceStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.HTML(http.StatusOK, invoice.Document)
+}
+
+// DeleteInvoice voids the invoice, its entries can be invoiced again.
+func (h InvoiceHandler) DeleteInvoice(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	invoice, err := h.invoiceStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := h.unmark(c, invoice.Lines, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := h.invoiceStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// createNumbered stores invoice with the number following the last invoice
+// of the owner and renders its document. Numbers are unique per owner, so
+// when a concurrent invoice took the number the next one is tried.
+func (h InvoiceHandler) createNumbered(ctx context.Context, invoice *types.Invoice, user types.User) (string, error) {
+	for try := 1; ; try++ {
+		number, err := h.nextInvoiceNumber(ctx)
+		if err != nil {
+			return "", err
+		}
+		invoice.Number = number
+		invoice.Document, err = billing.RenderHTML(*invoice, user)
+		if err != nil {
+			return "", err
+		}
+		id, err := h.invoiceStore.Create(ctx, *invoice)
+		if err == nil {
+			return id, nil
+		}
+		next, nextErr := h.nextInvoiceNumber(ctx)
+		if nextErr != nil || next == number || try == maxInvoiceNumberTries {
+			return "", err
+		}
+	}
+}
+
+// nextInvoiceNumber returns the number following the last invoice of the
+// owner in ctx.
+func (h InvoiceHandler) nextInvoiceNumber(ctx context.Context) (int, error) {
+	last, err := h.invoiceStore.Query(ctx, db.Query{Sort: invoiceNumberField, Desc: true, Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(last.Items) == 0 {
+		return 1, nil
+	}
+	return last.Items[0].Number + 1, nil
+}
+
+// mark sets the invoice id of the uninvoiced entries billed on lines to
+// invoiceID. It stops at the first entry billed elsewhere or deleted with
+// db.ErrNoMatch, and returns the number of lines marked before it.
+func (h InvoiceHandler) mark(ctx context.Context, lines []types.InvoiceLine, invoiceID string) (int, error) {
+	for i, line := range lines {
+		if err := markEntry(ctx, h.entryStore(line), line.SourceID, "", invoiceID); err != nil {
+			return i, err
+		}
+	}
+	return len(lines), nil
+}
+
+// unmark makes the entries billed on lines with invoiceID billable again,
+// entries deleted since or billed on another invoice are left alone.
+func (h InvoiceHandler) unmark(ctx context.Context, lines []types.InvoiceLine, invoiceID string) error {
+	for _, line := range lines {
+		err := markEntry(ctx, h.entryStore(line), line.SourceID, invoiceID, "")
+		if err != nil && !errors.Is(err, db.ErrNoMatch) {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryStore returns the store of the entry billed on line.
+func (h InvoiceHandler) entryStore(line types.InvoiceLine) db.ConditionalUpdateStorer {
+	if line.Kind == types.InvoiceLineExpense {
+		return h.moneyspendStore
+	}
+	return h.timespendStore
+}
+
+// markEntry changes the invoice id of the entry with id from from, or from
+// none when from is empty, to invoiceID. The invoice id is checked by the
+// update, it returns db.ErrNoMatch when the entry doesn't exist or has
+// another invoice id.
+func markEntry(ctx context.Context, store db.ConditionalUpdateStorer, id, from, invoiceID string) error {
+	cond := db.Cond{Field: invoiceIDField, Op: db.OpEq, Value: from}
+	if len(from) == 0 {
+		cond = db.Cond{Field: invoiceIDField, Op: db.OpEmpty}
+	}
+	return store.UpdateIf(ctx, id, []db.Cond{cond}, types.InvoicedParams{InvoiceID: invoiceID})
+}
+
+// updateUninvoiced updates the entry with id unless it is invoiced, the
+// check is part of the update so the entry can't be invoiced meanwhile.
+func updateUninvoiced(ctx context.Context, store db.ConditionalUpdateStorer, id string, updater db.Updater) error {
+	err := store.UpdateIf(ctx, id, []db.Cond{{Field: invoiceIDField, Op: db.OpEmpty}}, updater)
+	if errors.Is(err, db.ErrNoMatch) {
+		return errInvoiced
+	}
+	return err
+}
+
+// hasNotePrefix reports whether note starts with prefix, ignoring case like
+// the note filters of lists.
+func hasNotePrefix(note, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(note), strings.ToLower(prefix))
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type KeyHandler struct {
+	keyring *middleware.Keyring
+}
+
+func NewKeyHandler(keyring *middleware.Keyring) *KeyHandler {
+	return &KeyHandler{
+		keyring: keyring,
+	}
+}
+
+// GetJWKS returns the public keys tokens are verified with, so other services
+// can verify them.
+func (h KeyHandler) GetJWKS(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, echo.Map{"keys": h.keyring.JWKS()})
+}
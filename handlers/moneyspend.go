@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
+	"strings"
 
 	"github.com/SpectralJager/spender/db"
 	"github.com/SpectralJager/spender/middleware"
@@ -13,22 +13,30 @@ import (
 
 type MoneyspendHandler struct {
 	moneyspendStore db.SpendStor[types.Moneyspend]
+	categoryStore   db.CategoryStore
+	accountStore    db.AccountStore
+	userStore       db.UserStore
 }
 
-func NewMoneyspendHandler(moneyspendStore db.SpendStor[types.Moneyspend]) *MoneyspendHandler {
+func NewMoneyspendHandler(moneyspendStore db.SpendStor[types.Moneyspend], categoryStore db.CategoryStore, accountStore db.AccountStore, userStore db.UserStore) *MoneyspendHandler {
 	return &MoneyspendHandler{
 		moneyspendStore: moneyspendStore,
+		categoryStore:   categoryStore,
+		accountStore:    accountStore,
+		userStore:       userStore,
 	}
 }
 
 func (h MoneyspendHandler) GetAllMonies(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	monies, err := h.moneyspendStore.GetAll(c)
+	q, err := moneyQuery(ctx)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, echo.Map{"monies": monies})
+	page, err := h.moneyspendStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"monies": page.Items, "next": page.Next})
 }
 
 func (h MoneyspendHandler) PostMoneyspend(ctx echo.Context) error {
@@ -37,12 +45,37 @@ func (h MoneyspendHandler) PostMoneyspend(ctx echo.Context) error {
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	if errs := params.Validate(); len(errs) != 0 {
+	params.Tags = types.NormalizeTags(params.Tags)
+	c := ctx.Request().Context()
+	account, err := findAccount(c, h.accountStore, params.AccountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 && len(account.ID) != 0 {
+		params.Currency = account.Currency
+	}
+	if len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, ownerID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	errs := params.Validate()
+	validateAccount(account, params.AccountID, params.Currency, errs)
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
 	}
-	moneyspend := types.NewMoneyspendFromParams(params)
+	moneyspend, err := types.NewMoneyspendFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 	moneyspend.OwnerID = ownerID
-	id, err := h.moneyspendStore.Create(context.TODO(), moneyspend)
+	id, err := h.moneyspendStore.Create(c, moneyspend)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
@@ -50,9 +83,8 @@ func (h MoneyspendHandler) PostMoneyspend(ctx echo.Context) error {
 }
 
 func (h MoneyspendHandler) GetMoneyspend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
+	c := ctx.Request().Context()
 	moneyspend, err := h.moneyspendStore.GetByID(c, id)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
@@ -60,31 +92,71 @@ func (h MoneyspendHandler) GetMoneyspend(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, echo.Map{"money": moneyspend})
 }
 
+// PutMoneyspend changes a moneyspend unless it is invoiced, invoiced
+// moneyspends change only once their invoice is voided.
 func (h MoneyspendHandler) PutMoneyspend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
 	params, err := utils.DecodeBody[types.UpdateMoneyspendParams](ctx.Request().Body)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	if errs := params.Validate(); len(errs) != 0 {
+	c := ctx.Request().Context()
+	moneyspend, err := h.moneyspendStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(moneyspend.InvoiceID) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": errInvoiced.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	params.Currency = strings.ToUpper(params.Currency)
+	currency := moneyspend.Currency
+	if len(currency) == 0 {
+		currency = types.DefaultCurrency
+	}
+	if len(params.Currency) == 0 {
+		params.Currency = currency
+	}
+	errs := params.Validate()
+	// stored minor units would mean another amount in another currency
+	if params.Currency != currency && len(params.Money) == 0 {
+		errs["money"] = "money should be set when currency changes"
+	}
+	accountID := params.AccountID
+	if len(accountID) == 0 {
+		accountID = moneyspend.AccountID
+	}
+	account, err := findAccount(c, h.accountStore, accountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	validateAccount(account, accountID, params.Currency, errs)
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
 	}
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	err = h.moneyspendStore.Update(c, id, params)
+	err = updateUninvoiced(c, h.moneyspendStore, id, params)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
 }
 
+// DeleteMoneyspend refuses invoiced moneyspends like PutMoneyspend.
 func (h MoneyspendHandler) DeleteMoneyspend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	err := h.moneyspendStore.Delete(c, id)
+	c := ctx.Request().Context()
+	moneyspend, err := h.moneyspendStore.GetByID(c, id)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
+	if len(moneyspend.InvoiceID) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": errInvoiced.Error()})
+	}
+	if err := h.moneyspendStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
 }
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	projectField  = "projectId"
+	billableField = "billable"
+)
+
+type ProjectHandler struct {
+	projectStore   db.ProjectStore
+	timespendStore db.SpendStor[types.Timespend]
+	userStore      db.UserStore
+}
+
+func NewProjectHandler(projectStore db.ProjectStore, timespendStore db.SpendStor[types.Timespend], userStore db.UserStore) *ProjectHandler {
+	return &ProjectHandler{
+		projectStore:   projectStore,
+		timespendStore: timespendStore,
+		userStore:      userStore,
+	}
+}
+
+func (h ProjectHandler) GetAllProjects(ctx echo.Context) error {
+	projects, err := h.projectStore.GetAll(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"projects": projects})
+}
+
+func (h ProjectHandler) PostProject(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateProjectParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 {
+		user, err := h.userStore.GetByID(c, ownerID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		params.Currency = user.Currency()
+	}
+	if errs := params.Validate(); len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	project, err := types.NewProjectFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	project.OwnerID = ownerID
+	id, err := h.projectStore.Create(c, project)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h ProjectHandler) GetProject(ctx echo.Context) error {
+	id := ctx.Param("id")
+	project, err := h.projectStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"project": project})
+}
+
+func (h ProjectHandler) PutProject(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateProjectParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	project, err := h.projectStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = strings.ToUpper(params.Currency)
+	if len(params.Currency) == 0 {
+		params.Currency = project.Currency
+	}
+	errs := params.Validate()
+	// stored minor units would mean another rate in another currency
+	if params.Currency != project.Currency && len(params.HourlyRate) == 0 {
+		errs["hourlyRate"] = "hourlyRate should be set when currency changes"
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	err = h.projectStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// DeleteProject refuses to delete projects that still have timespends, so
+// billable time keeps its rate.
+func (h ProjectHandler) DeleteProject(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.Request().Context()
+	stats, err := aggregateTotal(c, h.timespendStore, "duration", []db.Cond{{Field: projectField, Op: db.OpEq, Value: id}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if stats.Count != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "project is used by timespends"})
+	}
+	if err := h.projectStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// validateProject adds a validation error to errs unless id is empty or one
+// of the owner's projects.
+func validateProject(ctx context.Context, store db.ProjectStore, id string, errs map[string]string) error {
+	if len(id) == 0 {
+		return nil
+	}
+	_, err := store.GetByID(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		errs[projectField] = fmt.Sprintf("project with id = %s doesn't exist", id)
+		return nil
+	}
+	return err
+}
+
+// projectBilling prices the billable time of a project at its hourly rate.
+type projectBilling struct {
+	ProjectID   string        `json:"projectId"`
+	Name        string        `json:"name"`
+	Client      string        `json:"client,omitempty"`
+	Currency    string        `json:"currency"`
+	HourlyRate  types.Amount  `json:"hourlyRate"`
+	Billable    time.Duration `json:"billable"`
+	NonBillable time.Duration `json:"nonBillable"`
+	Amount      types.Amount  `json:"amount"`
+	Count       int64         `json:"count"`
+}
+
+type currencyTotal struct {
+	Currency string       `json:"currency"`
+	Amount   types.Amount `json:"amount"`
+}
+
+// GetBillable prices the billable timespends of every project dated within
+// the optional start and end days, both days are included. Amounts are in
+// the currency of each project and totaled per currency.
+func (h ReportHandler) GetBillable(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	conds, err := dateRange(ctx, "start", "end")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	projects, err := h.projectStore.GetAll(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	times := map[bool]map[string]db.Aggregate{}
+	for _, billable := range []bool{true, false} {
+		aggregates, err := h.timespendStore.Aggregate(c, db.Aggregation{
+			Field:   "duration",
+			Conds:   append(append([]db.Cond{}, conds...), db.Cond{Field: billableField, Op: db.OpEq, Value: billable}),
+			GroupBy: projectField,
+		})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		times[billable] = map[string]db.Aggregate{}
+		for _, aggregate := range aggregates {
+			if id, _ := aggregate.Group.(string); len(id) != 0 {
+				times[billable][id] = aggregate
+			}
+		}
+	}
+
+	report := []projectBilling{}
+	totals := map[string]types.Money{}
+	for _, project := range projects {
+		billable := times[true][project.ID]
+		duration := time.Duration(billable.Sum)
+		money := types.HourlyAmount(project.HourlyRate, duration)
+		report = append(report, projectBilling{
+			ProjectID:   project.ID,
+			Name:        project.Name,
+			Client:      project.Client,
+			Currency:    project.Currency,
+			HourlyRate:  types.Amount{Money: project.HourlyRate, Currency: project.Currency},
+			Billable:    duration,
+			NonBillable: time.Duration(times[false][project.ID].Sum),
+			Amount:      types.Amount{Money: money, Currency: project.Currency},
+			Count:       billable.Count,
+		})
+		totals[project.Currency] += money
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Client != report[j].Client {
+			return report[i].Client < report[j].Client
+		}
+		return report[i].Name < report[j].Name
+	})
+	total := []currencyTotal{}
+	for currency, money := range totals {
+		total = append(total, currencyTotal{Currency: currency, Amount: types.Amount{Money: money, Currency: currency}})
+	}
+	sort.Slice(total, func(i, j int) bool { return total[i].Currency < total[j].Currency })
+	return ctx.JSON(http.StatusOK, echo.Map{"projects": report, "total": total})
+}
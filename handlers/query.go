@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	dateField = "date"
+	noteField = "note"
+	tagsField = "tags"
+)
+
+// spendQuery reads the list parameters shared by spend endpoints: an
+// inclusive from/to date range, min/max bounds of amountField, a note
+// substring, a category, the recurring rule spends were created by, comma
+// separated tags of which any or all must be
+// set, the sort field and order, and the page limit and cursor.
+func spendQuery(ctx echo.Context, amountField string, parseAmount func(string) (any, error)) (db.Query, error) {
+	q := db.Query{
+		Sort: dateField,
+	}
+	conds, err := dateRange(ctx, "from", "to")
+	if err != nil {
+		return db.Query{}, err
+	}
+	q.Conds = append(q.Conds, conds...)
+	for param, op := range map[string]db.Op{"min": db.OpGte, "max": db.OpLte} {
+		value := ctx.QueryParam(param)
+		if len(value) == 0 {
+			continue
+		}
+		amount, err := parseAmount(value)
+		if err != nil {
+			return db.Query{}, fmt.Errorf("%s: %w", param, err)
+		}
+		q.Conds = append(q.Conds, db.Cond{Field: amountField, Op: op, Value: amount})
+	}
+	if note := ctx.QueryParam("note"); len(note) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: noteField, Op: db.OpContains, Value: note})
+	}
+	if category := ctx.QueryParam("category"); len(category) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: categoryField, Op: db.OpEq, Value: category})
+	}
+	if rule := ctx.QueryParam("recurring"); len(rule) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: recurringField, Op: db.OpEq, Value: rule})
+	}
+	for param, op := range map[string]db.Op{"tagsAny": db.OpAny, "tagsAll": db.OpAll} {
+		if tags := types.NormalizeTags(strings.Split(ctx.QueryParam(param), ",")); len(tags) != 0 {
+			q.Conds = append(q.Conds, db.Cond{Field: tagsField, Op: op, Value: tags})
+		}
+	}
+	if sort := ctx.QueryParam("sort"); len(sort) != 0 {
+		if sort != dateField && sort != amountField && sort != noteField {
+			return db.Query{}, fmt.Errorf("sort should be one of %s, %s, %s", dateField, amountField, noteField)
+		}
+		q.Sort = sort
+	}
+	switch order := strings.ToLower(ctx.QueryParam("order")); order {
+	case "", orderASC:
+	case orderDESC:
+		q.Desc = true
+	default:
+		return db.Query{}, fmt.Errorf("order should be %s or %s", orderASC, orderDESC)
+	}
+	if err := pageParams(ctx, &q); err != nil {
+		return db.Query{}, err
+	}
+	return q, nil
+}
+
+// pageParams reads the page limit and cursor into q.
+func pageParams(ctx echo.Context, q *db.Query) error {
+	if limit := ctx.QueryParam("limit"); len(limit) != 0 {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > db.MaxLimit {
+			return fmt.Errorf("limit should be a number from 1 to %d", db.MaxLimit)
+		}
+		q.Limit = n
+	}
+	q.After = ctx.QueryParam("cursor")
+	return nil
+}
+
+// dateRange returns the conditions selecting spends from the day in
+// startParam up to and including the day in endParam. Either may be empty.
+func dateRange(ctx echo.Context, startParam, endParam string) ([]db.Cond, error) {
+	conds := []db.Cond{}
+	var start, end time.Time
+	if param := ctx.QueryParam(startParam); len(param) != 0 {
+		date, err := time.Parse(dateLayout, param)
+		if err != nil {
+			return nil, fmt.Errorf("%s should be a date like %s", startParam, dateLayout)
+		}
+		start = date
+		conds = append(conds, db.Cond{Field: dateField, Op: db.OpGte, Value: start})
+	}
+	if param := ctx.QueryParam(endParam); len(param) != 0 {
+		date, err := time.Parse(dateLayout, param)
+		if err != nil {
+			return nil, fmt.Errorf("%s should be a date like %s", endParam, dateLayout)
+		}
+		end = date
+		conds = append(conds, db.Cond{Field: dateField, Op: db.OpLt, Value: end.AddDate(0, 0, 1)})
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return nil, fmt.Errorf("%s should not be before %s", endParam, startParam)
+	}
+	return conds, nil
+}
+
+// moneyQuery reads the list parameters of moneyspends and incomes, those of
+// spendQuery and a currency and an account. Money bounds are in the
+// currency.
+func moneyQuery(ctx echo.Context) (db.Query, error) {
+	currency := strings.ToUpper(ctx.QueryParam(currencyField))
+	if len(currency) != 0 && !types.IsCurrency(currency) {
+		return db.Query{}, fmt.Errorf("currency %q should be an ISO 4217 code", currency)
+	}
+	q, err := spendQuery(ctx, "money", moneyParser(currency))
+	if err != nil {
+		return db.Query{}, err
+	}
+	if len(currency) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: currencyField, Op: db.OpEq, Value: currency})
+	}
+	if account := ctx.QueryParam("account"); len(account) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: accountField, Op: db.OpEq, Value: account})
+	}
+	return q, nil
+}
+
+// moneyParser parses money bounds in the decimals of currency, bounds can't
+// be compared across currencies, so they need one.
+func moneyParser(currency string) func(string) (any, error) {
+	return func(value string) (any, error) {
+		if len(currency) == 0 {
+			return nil, fmt.Errorf("money bounds need a currency")
+		}
+		return types.ParseMoney(types.Decimal(value), currency)
+	}
+}
+
+func parseDuration(value string) (any, error) {
+	return time.ParseDuration(value)
+}
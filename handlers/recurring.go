@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	recurringField = "recurringId"
+
+	defaultUpcomingLimit = 10
+)
+
+type RecurringHandler struct {
+	recurringStore db.RecurringStore
+	categoryStore  db.CategoryStore
+	accountStore   db.AccountStore
+	userStore      db.UserStore
+}
+
+func NewRecurringHandler(recurringStore db.RecurringStore, categoryStore db.CategoryStore, accountStore db.AccountStore, userStore db.UserStore) *RecurringHandler {
+	return &RecurringHandler{
+		recurringStore: recurringStore,
+		categoryStore:  categoryStore,
+		accountStore:   accountStore,
+		userStore:      userStore,
+	}
+}
+
+func (h RecurringHandler) GetAllRecurring(ctx echo.Context) error {
+	rules, err := h.recurringStore.GetAll(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"recurring": rules})
+}
+
+// PostRecurring creates a rule, its occurrences up to now are materialized
+// by the scheduler on its next run.
+func (h RecurringHandler) PostRecurring(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateRecurringParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	c := ctx.Request().Context()
+	account, err := findAccount(c, h.accountStore, params.AccountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Currency = strings.ToUpper(params.Currency)
+	if params.Kind == types.RecurringMoney && len(params.Currency) == 0 {
+		params.Currency = account.Currency
+		if len(params.Currency) == 0 {
+			user, err := h.userStore.GetByID(c, ownerID)
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			}
+			params.Currency = user.Currency()
+		}
+	}
+	errs := params.Validate()
+	if params.Kind == types.RecurringMoney {
+		validateAccount(account, params.AccountID, params.Currency, errs)
+	} else if len(params.AccountID) != 0 {
+		errs[accountField] = "account is only for money rules"
+	}
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	rule, err := types.NewRecurringFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	rule.OwnerID = ownerID
+	id, err := h.recurringStore.Create(c, rule)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h RecurringHandler) GetRecurring(ctx echo.Context) error {
+	id := ctx.Param("id")
+	rule, err := h.recurringStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"recurring": rule})
+}
+
+// PutRecurring changes the entries a rule creates from its next occurrence
+// on, entries already created are left as they are.
+func (h RecurringHandler) PutRecurring(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[types.UpdateRecurringParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	rule, err := h.recurringStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	params.Kind = rule.Kind
+	params.Currency = strings.ToUpper(params.Currency)
+	if rule.Kind == types.RecurringMoney && len(params.Currency) == 0 {
+		params.Currency = rule.Currency
+	}
+	errs := params.Validate()
+	if rule.Kind == types.RecurringMoney {
+		// stored minor units would mean another amount in another currency
+		if params.Currency != rule.Currency && len(params.Money) == 0 {
+			errs["money"] = "money should be set when currency changes"
+		}
+		accountID := params.AccountID
+		if len(accountID) == 0 {
+			accountID = rule.AccountID
+		}
+		account, err := findAccount(c, h.accountStore, accountID)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		validateAccount(account, accountID, params.Currency, errs)
+	}
+	if !params.Until.IsZero() && params.Until.Before(rule.Start.Truncate(24*time.Hour)) {
+		errs["until"] = "until should be not before start"
+	}
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	if !params.Until.IsZero() {
+		rule.Until = params.Until
+	}
+	if params.Count != 0 {
+		rule.Count = params.Count
+	}
+	params.Done = rule.Ended(rule.NextIndex)
+	err = h.recurringStore.Update(c, id, params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// DeleteRecurring deletes a rule, the entries it created are kept.
+func (h RecurringHandler) DeleteRecurring(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := h.recurringStore.Delete(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+type occurrence struct {
+	Date    time.Time `json:"date"`
+	Skipped bool      `json:"skipped"`
+}
+
+// GetUpcoming lists the next occurrences of a rule which aren't materialized
+// yet, limit of them, 10 by default.
+func (h RecurringHandler) GetUpcoming(ctx echo.Context) error {
+	limit := defaultUpcomingLimit
+	if param := ctx.QueryParam("limit"); len(param) != 0 {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > db.MaxLimit {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": fmt.Sprintf("limit should be a number from 1 to %d", db.MaxLimit)})
+		}
+		limit = n
+	}
+	rule, err := h.recurringStore.GetByID(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	upcoming := []occurrence{}
+	for _, date := range rule.Upcoming(limit) {
+		upcoming = append(upcoming, occurrence{Date: date, Skipped: rule.IsSkipped(date)})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"upcoming": upcoming})
+}
+
+type skipParams struct {
+	Date string `json:"date"`
+}
+
+// PostSkip skips the upcoming occurrence of a rule on the date day, so the
+// scheduler doesn't materialize it.
+func (h RecurringHandler) PostSkip(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params, err := utils.DecodeBody[skipParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	rule, err := h.recurringStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	date, ok := rule.UpcomingOn(params.Date)
+	if !ok {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": map[string]string{
+			dateField: fmt.Sprintf("date should be a day like %s of an upcoming occurrence", dateLayout),
+		}})
+	}
+	if !rule.IsSkipped(date) {
+		skipped := append(rule.Skipped, date.Format(dateLayout))
+		if err := h.recurringStore.Update(c, id, types.SkipRecurringParams{Skipped: skipped}); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
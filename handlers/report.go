@@ -2,78 +2,493 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/SpectralJager/spender/db"
 	"github.com/SpectralJager/spender/middleware"
 	"github.com/SpectralJager/spender/types"
-	"github.com/SpectralJager/spender/utils"
 	"github.com/labstack/echo/v4"
 )
 
 type ReportHandler struct {
-	timespendStore  db.MongoTimespendStore
-	moneyspendStore db.MongoMoneyspendStore
+	timespendStore  db.SpendStor[types.Timespend]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	incomeStore     db.SpendStor[types.Income]
+	categoryStore   db.CategoryStore
+	budgetStore     db.BudgetStore
+	projectStore    db.ProjectStore
+	userStore       db.UserStore
+	rateStore       db.ExchangeRateStore
 }
 
-func NewReportHandler(timespendStore db.MongoTimespendStore, moneyspendStore db.MongoMoneyspendStore) *ReportHandler {
+func NewReportHandler(timespendStore db.SpendStor[types.Timespend], moneyspendStore db.SpendStor[types.Moneyspend], incomeStore db.SpendStor[types.Income], categoryStore db.CategoryStore, budgetStore db.BudgetStore, projectStore db.ProjectStore, userStore db.UserStore, rateStore db.ExchangeRateStore) *ReportHandler {
 	return &ReportHandler{
 		timespendStore:  timespendStore,
 		moneyspendStore: moneyspendStore,
+		incomeStore:     incomeStore,
+		categoryStore:   categoryStore,
+		budgetStore:     budgetStore,
+		projectStore:    projectStore,
+		userStore:       userStore,
+		rateStore:       rateStore,
 	}
 }
 
+// GetTotalSpend sums the spends dated within the optional start and end
+// days, both days are included. Money is converted to the currency
+// parameter, the base currency of the user by default.
 func (h ReportHandler) GetTotalSpend(ctx echo.Context) error {
 	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
+	c := ctx.Request().Context()
 
-	times, err := h.timespendStore.GetAll(c)
+	conds, err := dateRange(ctx, "start", "end")
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	monies, err := h.moneyspendStore.GetAll(c)
+	currency, err := h.reportCurrency(ctx)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	start := ctx.QueryParam("start")
-	end := ctx.QueryParam("end")
-	if len(start) != 0 && len(end) != 0 {
-		dateStart, err := time.Parse(time.DateOnly, start)
-		if err != nil {
-			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	timeStats, err := aggregateTotal(c, h.timespendStore, "duration", conds)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	monies, missing, err := h.aggregateMoney(c, h.moneyspendStore, currency, db.Aggregation{
+		Field: "money",
+		Conds: conds,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	moneyStats := newMoneyStats(monies[0], currency)
+
+	totalTime := types.Timespend{
+		OwnerID:  ownerID,
+		Duration: time.Duration(timeStats.Sum),
+	}
+	totalMoney := types.Moneyspend{
+		OwnerID:  ownerID,
+		Money:    moneyStats.Sum.Money,
+		Currency: currency,
+	}
+
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"timespend":       totalTime,
+		"moneyspend":      totalMoney,
+		"timespendStats":  timeStats,
+		"moneyspendStats": moneyStats,
+		"unconverted":     missing,
+	})
+}
+
+// moneyStats is a money aggregate in exact amounts of its currency.
+type moneyStats struct {
+	Count int64        `json:"count"`
+	Sum   types.Amount `json:"sum"`
+	Min   types.Amount `json:"min"`
+	Max   types.Amount `json:"max"`
+	Avg   types.Amount `json:"avg"`
+}
+
+func newMoneyStats(aggregate db.Aggregate, currency string) moneyStats {
+	return moneyStats{
+		Count: aggregate.Count,
+		Sum:   amount(aggregate.Sum, currency),
+		Min:   amount(aggregate.Min, currency),
+		Max:   amount(aggregate.Max, currency),
+		Avg:   amount(int64(math.Round(aggregate.Avg)), currency),
+	}
+}
+
+func aggregateTotal(ctx context.Context, store db.Aggregator, field string, conds []db.Cond) (db.Aggregate, error) {
+	aggregates, err := store.Aggregate(ctx, db.Aggregation{
+		Field: field,
+		Conds: conds,
+	})
+	if err != nil {
+		return db.Aggregate{}, err
+	}
+	if len(aggregates) == 0 {
+		return db.Aggregate{}, nil
+	}
+	return aggregates[0], nil
+}
+
+const (
+	unitDay   = "day"
+	unitWeek  = "week"
+	unitMonth = "month"
+	unitYear  = "year"
+)
+
+type seriesBucket struct {
+	Label           string        `json:"label"`
+	Start           time.Time     `json:"start"`
+	End             time.Time     `json:"end"`
+	Money           types.Amount  `json:"money"`
+	Duration        time.Duration `json:"duration"`
+	MoneyspendCount int64         `json:"moneyspendCount"`
+	TimespendCount  int64         `json:"timespendCount"`
+}
+
+// GetSeries totals spends per day, ISO week, month or year from the start
+// day up to and including the end day. Days begin at midnight in the tz time
+// zone, UTC by default. Money is converted like in GetTotalSpend.
+func (h ReportHandler) GetSeries(ctx echo.Context) error {
+	c := ctx.Request().Context()
+
+	unit, loc, boundaries, err := seriesRange(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	currency, err := h.reportCurrency(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	series := make([]seriesBucket, len(boundaries)-1)
+	index := map[int64]int{}
+	for i := range series {
+		series[i] = seriesBucket{
+			Label: bucketLabel(unit, boundaries[i]),
+			Start: boundaries[i],
+			End:   boundaries[i+1],
+			Money: types.Amount{Currency: currency},
+		}
+		index[boundaries[i].UnixMilli()] = i
+	}
+	monies, missing, err := h.aggregateMoney(c, h.moneyspendStore, currency, db.Aggregation{
+		Field:    "money",
+		BucketBy: dateField,
+		Buckets:  boundaries,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, aggregate := range monies {
+		if i, ok := index[aggregate.Bucket.UnixMilli()]; ok {
+			series[i].Money = amount(aggregate.Sum, currency)
+			series[i].MoneyspendCount = aggregate.Count
+		}
+	}
+	times, err := h.timespendStore.Aggregate(c, db.Aggregation{
+		Field:    "duration",
+		BucketBy: dateField,
+		Buckets:  boundaries,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, aggregate := range times {
+		if i, ok := index[aggregate.Bucket.UnixMilli()]; ok {
+			series[i].Duration = time.Duration(aggregate.Sum)
+			series[i].TimespendCount = aggregate.Count
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"unit":        unit,
+		"timezone":    loc.String(),
+		"currency":    currency,
+		"series":      series,
+		"unconverted": missing,
+	})
+}
+
+// seriesRange reads the unit, tz, start and end parameters of series reports
+// and returns the boundaries of their buckets.
+func seriesRange(ctx echo.Context) (string, *time.Location, []time.Time, error) {
+	unit := ctx.QueryParam("unit")
+	loc, err := time.LoadLocation(ctx.QueryParam("tz"))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("tz should be an IANA time zone")
+	}
+	start, err := time.ParseInLocation(dateLayout, ctx.QueryParam("start"), loc)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("start should be a date like %s", dateLayout)
+	}
+	end, err := time.ParseInLocation(dateLayout, ctx.QueryParam("end"), loc)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("end should be a date like %s", dateLayout)
+	}
+	boundaries, err := bucketBoundaries(unit, start, end)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return unit, loc, boundaries, nil
+}
+
+// bucketBoundaries returns the starts of the unit periods covering the start
+// to end days, followed by the end of the last period. Periods are computed
+// in the location of start, so they follow its daylight saving changes.
+func bucketBoundaries(unit string, start, end time.Time) ([]time.Time, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("end should not be before start")
+	}
+	var step func(time.Time) time.Time
+	switch unit {
+	case unitDay:
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case unitWeek:
+		// ISO weeks start on monday
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case unitMonth:
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case unitYear:
+		start = time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, start.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	default:
+		return nil, fmt.Errorf("unit should be one of %s, %s, %s, %s", unitDay, unitWeek, unitMonth, unitYear)
+	}
+	boundaries := []time.Time{start}
+	for t := start; !t.After(end); {
+		t = step(t)
+		boundaries = append(boundaries, t)
+		if len(boundaries) > db.MaxBuckets+1 {
+			return nil, fmt.Errorf("range should have at most %d %ss", db.MaxBuckets, unit)
 		}
+	}
+	return boundaries, nil
+}
+
+func bucketLabel(unit string, start time.Time) string {
+	switch unit {
+	case unitWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case unitMonth:
+		return start.Format("2006-01")
+	case unitYear:
+		return start.Format("2006")
+	}
+	return start.Format(dateLayout)
+}
+
+type categoryShare struct {
+	CategoryID      string        `json:"categoryId,omitempty"`
+	Name            string        `json:"name,omitempty"`
+	ParentID        string        `json:"parentId,omitempty"`
+	Money           types.Amount  `json:"money"`
+	MoneyPercent    float64       `json:"moneyPercent"`
+	Duration        time.Duration `json:"duration"`
+	DurationPercent float64       `json:"durationPercent"`
+	MoneyspendCount int64         `json:"moneyspendCount"`
+	TimespendCount  int64         `json:"timespendCount"`
+	// Totals include the spends of all subcategories.
+	TotalMoney           types.Amount  `json:"totalMoney"`
+	TotalMoneyPercent    float64       `json:"totalMoneyPercent"`
+	TotalDuration        time.Duration `json:"totalDuration"`
+	TotalDurationPercent float64       `json:"totalDurationPercent"`
+}
+
+// GetByCategory splits the spends dated within the optional start and end
+// days by category. Percentages are of all spends in the range, spends
+// without a category are reported apart. Money is converted like in
+// GetTotalSpend.
+func (h ReportHandler) GetByCategory(ctx echo.Context) error {
+	c := ctx.Request().Context()
 
-		dateEnd, err := time.Parse(time.DateOnly, end)
-		if err != nil {
-			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	conds, err := dateRange(ctx, "start", "end")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	categories, err := h.categoryStore.GetAll(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	currency, err := h.reportCurrency(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	monies, missing, err := h.aggregateMoney(c, h.moneyspendStore, currency, db.Aggregation{
+		Field:   "money",
+		Conds:   conds,
+		GroupBy: categoryField,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	times, err := h.timespendStore.Aggregate(c, db.Aggregation{
+		Field:   "duration",
+		Conds:   conds,
+		GroupBy: categoryField,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	zero := types.Amount{Currency: currency}
+	shares := map[string]*categoryShare{}
+	parents := map[string]string{}
+	for _, category := range categories {
+		shares[category.ID] = &categoryShare{
+			CategoryID: category.ID,
+			Name:       category.Name,
+			ParentID:   category.ParentID,
+			Money:      zero,
+			TotalMoney: zero,
+		}
+		parents[category.ID] = category.ParentID
+	}
+	// spends of unknown categories count as uncategorized
+	uncategorized := &categoryShare{Money: zero, TotalMoney: zero}
+	share := func(group any) *categoryShare {
+		if id, _ := group.(string); len(id) != 0 && shares[id] != nil {
+			return shares[id]
+		}
+		return uncategorized
+	}
+	totalMoney := zero
+	var totalDuration time.Duration
+	for _, aggregate := range monies {
+		s := share(aggregate.Group)
+		money := amount(aggregate.Sum, currency).Money
+		s.Money.Money += money
+		s.MoneyspendCount += aggregate.Count
+		totalMoney.Money += money
+	}
+	for _, aggregate := range times {
+		s := share(aggregate.Group)
+		duration := time.Duration(aggregate.Sum)
+		s.Duration += duration
+		s.TimespendCount += aggregate.Count
+		totalDuration += duration
+	}
+	for id, s := range shares {
+		seen := map[string]bool{}
+		for ancestor := id; len(ancestor) != 0 && !seen[ancestor] && shares[ancestor] != nil; ancestor = parents[ancestor] {
+			seen[ancestor] = true
+			shares[ancestor].TotalMoney.Money += s.Money.Money
+			shares[ancestor].TotalDuration += s.Duration
 		}
+	}
 
-		times = utils.Map(times, func(timespend types.Timespend) bool {
-			return timespend.Date.After(dateStart) && timespend.Date.Before(dateEnd)
-		})
-		monies = utils.Map(monies, func(moneyspend types.Moneyspend) bool {
-			return moneyspend.Date.After(dateStart) && moneyspend.Date.Before(dateEnd)
-		})
+	report := []categoryShare{}
+	for _, s := range shares {
+		s.MoneyPercent = percent(float64(s.Money.Money), float64(totalMoney.Money))
+		s.DurationPercent = percent(float64(s.Duration), float64(totalDuration))
+		s.TotalMoneyPercent = percent(float64(s.TotalMoney.Money), float64(totalMoney.Money))
+		s.TotalDurationPercent = percent(float64(s.TotalDuration), float64(totalDuration))
+		report = append(report, *s)
 	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].TotalMoney.Money != report[j].TotalMoney.Money {
+			return report[i].TotalMoney.Money > report[j].TotalMoney.Money
+		}
+		if report[i].TotalDuration != report[j].TotalDuration {
+			return report[i].TotalDuration > report[j].TotalDuration
+		}
+		return report[i].Name < report[j].Name
+	})
+	uncategorized.TotalMoney = uncategorized.Money
+	uncategorized.TotalDuration = uncategorized.Duration
+	uncategorized.MoneyPercent = percent(float64(uncategorized.Money.Money), float64(totalMoney.Money))
+	uncategorized.DurationPercent = percent(float64(uncategorized.Duration), float64(totalDuration))
+	uncategorized.TotalMoneyPercent = uncategorized.MoneyPercent
+	uncategorized.TotalDurationPercent = uncategorized.DurationPercent
 
-	totalTime := types.Timespend{
-		OwnerID: ownerID,
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"money":         totalMoney,
+		"currency":      currency,
+		"duration":      totalDuration,
+		"categories":    report,
+		"uncategorized": uncategorized,
+		"unconverted":   missing,
+	})
+}
+
+// percent returns part of total in percents rounded to hundredths.
+func percent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(part/total*10000) / 100
+}
+
+type tagTotal struct {
+	Tag             string        `json:"tag"`
+	Money           types.Amount  `json:"money"`
+	Duration        time.Duration `json:"duration"`
+	MoneyspendCount int64         `json:"moneyspendCount"`
+	TimespendCount  int64         `json:"timespendCount"`
+}
+
+// GetByTag totals the spends dated within the optional start and end days
+// per tag. A spend counts in the total of each of its tags, so totals of
+// different tags may overlap. Money is converted like in GetTotalSpend.
+func (h ReportHandler) GetByTag(ctx echo.Context) error {
+	c := ctx.Request().Context()
+
+	conds, err := dateRange(ctx, "start", "end")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	for _, time := range times {
-		totalTime.Duration += time.Duration
+	currency, err := h.reportCurrency(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	monies, missing, err := h.aggregateMoney(c, h.moneyspendStore, currency, db.Aggregation{
+		Field:   "money",
+		Conds:   conds,
+		GroupBy: tagsField,
+		Unwind:  true,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	times, err := h.timespendStore.Aggregate(c, db.Aggregation{
+		Field:   "duration",
+		Conds:   conds,
+		GroupBy: tagsField,
+		Unwind:  true,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
-	totalMoney := types.Moneyspend{
-		OwnerID: ownerID,
+	totals := map[string]*tagTotal{}
+	total := func(group any) *tagTotal {
+		tag, _ := group.(string)
+		if totals[tag] == nil {
+			totals[tag] = &tagTotal{Tag: tag, Money: types.Amount{Currency: currency}}
+		}
+		return totals[tag]
+	}
+	for _, aggregate := range monies {
+		t := total(aggregate.Group)
+		t.Money = amount(aggregate.Sum, currency)
+		t.MoneyspendCount = aggregate.Count
 	}
-	for _, money := range monies {
-		totalMoney.Money += money.Money
+	for _, aggregate := range times {
+		t := total(aggregate.Group)
+		t.Duration = time.Duration(aggregate.Sum)
+		t.TimespendCount = aggregate.Count
 	}
 
+	report := []tagTotal{}
+	for _, t := range totals {
+		report = append(report, *t)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Money.Money != report[j].Money.Money {
+			return report[i].Money.Money > report[j].Money.Money
+		}
+		if report[i].Duration != report[j].Duration {
+			return report[i].Duration > report[j].Duration
+		}
+		return report[i].Tag < report[j].Tag
+	})
 	return ctx.JSON(http.StatusOK, echo.Map{
-		"timespend":  totalTime,
-		"moneyspend": totalMoney,
+		"currency":    currency,
+		"tags":        report,
+		"unconverted": missing,
 	})
 }
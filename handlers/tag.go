@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultTagLimit = 20
+
+type TagHandler struct {
+	timespendStore  db.SpendStor[types.Timespend]
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	incomeStore     db.SpendStor[types.Income]
+}
+
+func NewTagHandler(timespendStore db.SpendStor[types.Timespend], moneyspendStore db.SpendStor[types.Moneyspend], incomeStore db.SpendStor[types.Income]) *TagHandler {
+	return &TagHandler{
+		timespendStore:  timespendStore,
+		moneyspendStore: moneyspendStore,
+		incomeStore:     incomeStore,
+	}
+}
+
+type tagUsage struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// GetTags lists the tags of the user starting with the optional prefix,
+// most used first, for autocompletion. Count is the number of spends and
+// incomes with the tag.
+func (h TagHandler) GetTags(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	prefix := strings.ToLower(strings.TrimSpace(ctx.QueryParam("prefix")))
+	limit := defaultTagLimit
+	if param := ctx.QueryParam("limit"); len(param) != 0 {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > db.MaxLimit {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": fmt.Sprintf("limit should be a number from 1 to %d", db.MaxLimit)})
+		}
+		limit = n
+	}
+
+	counts := map[string]int64{}
+	for _, source := range []struct {
+		store db.Aggregator
+		field string
+	}{
+		{h.timespendStore, "duration"},
+		{h.moneyspendStore, "money"},
+		{h.incomeStore, "money"},
+	} {
+		aggregates, err := source.store.Aggregate(c, db.Aggregation{
+			Field:   source.field,
+			GroupBy: tagsField,
+			Unwind:  true,
+		})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		for _, aggregate := range aggregates {
+			if tag, ok := aggregate.Group.(string); ok && strings.HasPrefix(tag, prefix) {
+				counts[tag] += aggregate.Count
+			}
+		}
+	}
+
+	tags := []tagUsage{}
+	for tag, count := range counts {
+		tags = append(tags, tagUsage{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"tags": tags})
+}
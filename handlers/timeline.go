@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	timelineInterval = "interval"
+	timelineGap      = "gap"
+)
+
+// timelineEntry is an interval of a timespend or a gap between intervals,
+// cut to the day of the timeline.
+type timelineEntry struct {
+	Kind      string           `json:"kind"`
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Duration  time.Duration    `json:"duration"`
+	Timespend *types.Timespend `json:"timespend,omitempty"`
+	// Overlaps marks intervals starting before the previous ones end.
+	Overlaps bool `json:"overlaps,omitempty"`
+}
+
+// GetTimeline lists the intervals of timespends within the date day, today
+// by default, in the tz time zone, UTC by default, ordered by start with the
+// gaps between them. Tracked is the time covered by intervals, counting
+// overlaps once. Timespends of the day without an interval are listed as
+// untimed.
+func (h TimespendHandler) GetTimeline(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	day, loc, err := dayParams(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	end := day.AddDate(0, 0, 1)
+
+	intervals, err := queryAll(c, h.timespendStore, db.Query{
+		Conds: []db.Cond{
+			{Field: startField, Op: db.OpLt, Value: end},
+			{Field: endField, Op: db.OpGt, Value: day},
+		},
+		Sort: startField,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	timeline := []timelineEntry{}
+	var tracked, gaps time.Duration
+	var cursor time.Time
+	for i := range intervals {
+		start, stop := intervals[i].Start, intervals[i].End
+		if start.Before(day) {
+			start = day
+		}
+		if stop.After(end) {
+			stop = end
+		}
+		start, stop = start.In(loc), stop.In(loc)
+		if !cursor.IsZero() && start.After(cursor) {
+			timeline = append(timeline, timelineEntry{Kind: timelineGap, Start: cursor, End: start, Duration: start.Sub(cursor)})
+			gaps += start.Sub(cursor)
+		}
+		overlaps := !cursor.IsZero() && start.Before(cursor)
+		switch {
+		case !overlaps:
+			tracked += stop.Sub(start)
+		case stop.After(cursor):
+			tracked += stop.Sub(cursor)
+		}
+		if stop.After(cursor) {
+			cursor = stop
+		}
+		timeline = append(timeline, timelineEntry{
+			Kind:      timelineInterval,
+			Start:     start,
+			End:       stop,
+			Duration:  stop.Sub(start),
+			Timespend: &intervals[i],
+			Overlaps:  overlaps,
+		})
+	}
+
+	dated, err := queryAll(c, h.timespendStore, db.Query{
+		Conds: []db.Cond{
+			{Field: dateField, Op: db.OpGte, Value: day},
+			{Field: dateField, Op: db.OpLt, Value: end},
+		},
+		Sort: dateField,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	untimed := []types.Timespend{}
+	for _, timespend := range dated {
+		if !timespend.HasInterval() {
+			untimed = append(untimed, timespend)
+		}
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{
+		"date":     day.Format(dateLayout),
+		"timezone": loc.String(),
+		"timeline": timeline,
+		"tracked":  tracked,
+		"gaps":     gaps,
+		"untimed":  untimed,
+	})
+}
+
+// queryAll returns the entities of every page of q.
+func queryAll[T any](ctx context.Context, store db.QueryStorer[T], q db.Query) ([]T, error) {
+	q.Limit = db.MaxLimit
+	entities := []T{}
+	for {
+		page, err := store.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Items...)
+		if len(page.Next) == 0 {
+			return entities, nil
+		}
+		q.After = page.Next
+	}
+}
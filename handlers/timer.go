@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+var errNoTimer = errors.New("timer isn't running")
+
+// StartTimer starts the timer of the user, who can't have another one
+// running.
+func (h TimespendHandler) StartTimer(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.StartTimerParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	c := ctx.Request().Context()
+	_, err = h.runningTimer(c)
+	if err == nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "timer is already running"})
+	}
+	if !errors.Is(err, errNoTimer) {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	errs := params.Validate()
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	timer := types.NewTimerFromParams(params, time.Now())
+	timer.OwnerID = ownerID
+	id, err := h.timerStore.Create(c, timer)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+// GetTimer returns the running timer of the user and the time it has run.
+func (h TimespendHandler) GetTimer(ctx echo.Context) error {
+	timer, err := h.runningTimer(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"timer": timer, "elapsed": timer.Elapsed(time.Now())})
+}
+
+// StopTimer turns the running timer of the user into a timespend of the
+// interval it has run. Timers stopped within a second keep running, the
+// shortest timespend is a second long. Overlapped timespends are returned
+// rather than rejected, so the tracked time isn't lost. The timer is deleted
+// before the timespend is made, so of concurrent stops only the one deleting
+// it makes a timespend.
+func (h TimespendHandler) StopTimer(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	timer, err := h.runningTimer(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	timespend := timer.Timespend(time.Now())
+	if timespend.Duration < time.Second {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": map[string]string{
+			"duration": "duration should be more then 1 second",
+		}})
+	}
+	overlaps, err := h.overlaps(c, "", timespend.Start, timespend.End)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := h.timerStore.Delete(c, timer.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": errNoTimer.Error()})
+	}
+	id, err := h.timespendStore.Create(c, timespend)
+	if err != nil {
+		if _, restoreErr := h.timerStore.Create(c, timer); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id, "overlaps": overlaps})
+}
+
+// DeleteTimer discards the running timer of the user without a timespend.
+func (h TimespendHandler) DeleteTimer(ctx echo.Context) error {
+	c := ctx.Request().Context()
+	timer, err := h.runningTimer(c)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := h.timerStore.Delete(c, timer.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": timer.ID})
+}
+
+// runningTimer returns the timer of the owner in ctx, or errNoTimer.
+func (h TimespendHandler) runningTimer(ctx context.Context) (types.Timer, error) {
+	page, err := h.timerStore.Query(ctx, db.Query{Limit: 1})
+	if err != nil {
+		return types.Timer{}, err
+	}
+	if len(page.Items) == 0 {
+		return types.Timer{}, errNoTimer
+	}
+	return page.Items[0], nil
+}
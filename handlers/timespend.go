@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SpectralJager/spender/db"
 	"github.com/SpectralJager/spender/middleware"
@@ -11,49 +15,96 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	startField = "start"
+	endField   = "end"
+
+	overlapReject = "reject"
+	overlapWarn   = "warn"
+)
+
 type TimespendHandler struct {
 	timespendStore db.SpendStor[types.Timespend]
+	timerStore     db.TimerStore
+	projectStore   db.ProjectStore
+	categoryStore  db.CategoryStore
 }
 
-func NewTimespendHandler(timespendStore db.SpendStor[types.Timespend]) *TimespendHandler {
+func NewTimespendHandler(timespendStore db.SpendStor[types.Timespend], timerStore db.TimerStore, projectStore db.ProjectStore, categoryStore db.CategoryStore) *TimespendHandler {
 	return &TimespendHandler{
 		timespendStore: timespendStore,
+		timerStore:     timerStore,
+		projectStore:   projectStore,
+		categoryStore:  categoryStore,
 	}
 }
 
+// GetAllTimes lists timespends by the parameters of spendQuery, a project
+// and whether they are billable.
 func (h TimespendHandler) GetAllTimes(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	times, err := h.timespendStore.GetAll(c)
+	q, err := spendQuery(ctx, "duration", parseDuration)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if project := ctx.QueryParam("project"); len(project) != 0 {
+		q.Conds = append(q.Conds, db.Cond{Field: projectField, Op: db.OpEq, Value: project})
+	}
+	if param := ctx.QueryParam(billableField); len(param) != 0 {
+		billable, err := strconv.ParseBool(param)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "billable should be true or false"})
+		}
+		q.Conds = append(q.Conds, db.Cond{Field: billableField, Op: db.OpEq, Value: billable})
+	}
+	page, err := h.timespendStore.Query(ctx.Request().Context(), q)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, echo.Map{"timespends": times})
+	return ctx.JSON(http.StatusOK, echo.Map{"timespends": page.Items, "next": page.Next})
 }
 
+// PostTimespend rejects timespends whose interval overlaps intervals of
+// other timespends, or creates them and returns the overlapped ones with
+// the overlap=warn parameter.
 func (h TimespendHandler) PostTimespend(ctx echo.Context) error {
 	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	params, err := utils.DecodeBody[types.CreateTimespendParams](ctx.Request().Body)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	if errs := params.Validate(); len(errs) != 0 {
+	warn, err := overlapParam(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	setInterval(params.Start, params.End, &params.Date, &params.Duration)
+	c := ctx.Request().Context()
+	errs := params.Validate()
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := validateProject(c, h.projectStore, params.ProjectID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	overlaps, err := h.validateOverlaps(c, "", params.Start, params.End, warn, errs)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
 	}
 	timespend := types.NewTimespendFromParams(params)
 	timespend.OwnerID = ownerID
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
 	id, err := h.timespendStore.Create(c, timespend)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id, "overlaps": overlaps})
 }
 
 func (h TimespendHandler) GetTimespend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
+	c := ctx.Request().Context()
 	timespend, err := h.timespendStore.GetByID(c, id)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
@@ -61,32 +112,131 @@ func (h TimespendHandler) GetTimespend(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, echo.Map{"timespend": timespend})
 }
 
+// PutTimespend checks overlaps of a changed interval like PostTimespend.
+// Date and Duration of timespends with an interval change only with it.
+// Invoiced timespends change only once their invoice is voided.
 func (h TimespendHandler) PutTimespend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
 	params, err := utils.DecodeBody[types.UpdateTimespendParams](ctx.Request().Body)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	if errs := params.Validate(); len(errs) != 0 {
+	warn, err := overlapParam(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	timespend, err := h.timespendStore.GetByID(c, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(timespend.InvoiceID) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": errInvoiced.Error()})
+	}
+	params.Tags = types.NormalizeTags(params.Tags)
+	setInterval(params.Start, params.End, &params.Date, &params.Duration)
+	errs := params.Validate()
+	if timespend.HasInterval() && params.Start.IsZero() && (!params.Date.Equal(timespend.Date) || params.Duration != timespend.Duration) {
+		errs["start"] = "start and end should be set to change date or duration of a timespend with an interval"
+	}
+	if err := validateCategory(c, h.categoryStore, categoryField, params.CategoryID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if err := validateProject(c, h.projectStore, params.ProjectID, errs); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if params.Billable != nil && *params.Billable && len(params.ProjectID) == 0 && len(timespend.ProjectID) == 0 {
+		errs[billableField] = "billable timespends should have a project"
+	}
+	overlaps, err := h.validateOverlaps(c, id, params.Start, params.End, warn, errs)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	if len(errs) != 0 {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
 	}
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	err = h.timespendStore.Update(c, id, params)
+	err = updateUninvoiced(c, h.timespendStore, id, params)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id, "overlaps": overlaps})
 }
 
+// DeleteTimespend refuses invoiced timespends like PutTimespend.
 func (h TimespendHandler) DeleteTimespend(ctx echo.Context) error {
-	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
 	id := ctx.Param("id")
-	c := context.WithValue(context.Background(), ownerIDKey, ownerID)
-	err := h.timespendStore.Delete(c, id)
+	c := ctx.Request().Context()
+	timespend, err := h.timespendStore.GetByID(c, id)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
+	if len(timespend.InvoiceID) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": errInvoiced.Error()})
+	}
+	if err := h.timespendStore.Delete(c, id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
 
+// overlapParam reads whether overlapping intervals only warn, with
+// overlap=warn, or are rejected, with overlap=reject or by default.
+func overlapParam(ctx echo.Context) (bool, error) {
+	switch param := ctx.QueryParam("overlap"); param {
+	case "", overlapReject:
+		return false, nil
+	case overlapWarn:
+		return true, nil
+	}
+	return false, fmt.Errorf("overlap should be %s or %s", overlapReject, overlapWarn)
+}
+
+// setInterval sets date to start and duration to the time from start to end
+// when both are set.
+func setInterval(start, end time.Time, date *time.Time, duration *time.Duration) {
+	if start.IsZero() || end.IsZero() {
+		return
+	}
+	*date = start
+	*duration = end.Sub(start)
+}
+
+// overlaps returns the IDs of timespends, other than id, whose intervals
+// overlap the one from start to end.
+func (h TimespendHandler) overlaps(ctx context.Context, id string, start, end time.Time) ([]string, error) {
+	page, err := h.timespendStore.Query(ctx, db.Query{
+		Conds: []db.Cond{
+			{Field: startField, Op: db.OpLt, Value: end},
+			{Field: endField, Op: db.OpGt, Value: start},
+		},
+		Sort:  startField,
+		Limit: db.MaxLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, timespend := range page.Items {
+		if timespend.ID != id {
+			ids = append(ids, timespend.ID)
+		}
+	}
+	return ids, nil
+}
+
+// validateOverlaps adds a validation error to errs when the interval from
+// start to end overlaps others, unless warn is set, and returns the IDs of
+// the overlapped timespends.
+func (h TimespendHandler) validateOverlaps(ctx context.Context, id string, start, end time.Time, warn bool, errs map[string]string) ([]string, error) {
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return []string{}, nil
+	}
+	ids, err := h.overlaps(ctx, id, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != 0 && !warn {
+		errs[startField] = fmt.Sprintf("interval overlaps timespends %s", strings.Join(ids, ", "))
+	}
+	return ids, nil
 }
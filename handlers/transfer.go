@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type TransferHandler struct {
+	transferStore db.SpendStor[types.Transfer]
+	accountStore  db.AccountStore
+}
+
+func NewTransferHandler(transferStore db.SpendStor[types.Transfer], accountStore db.AccountStore) *TransferHandler {
+	return &TransferHandler{
+		transferStore: transferStore,
+		accountStore:  accountStore,
+	}
+}
+
+// GetAllTransfers lists transfers within an inclusive from/to date range,
+// optionally only those of the fromAccount or toAccount accounts.
+func (h TransferHandler) GetAllTransfers(ctx echo.Context) error {
+	conds, err := dateRange(ctx, "from", "to")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	q := db.Query{Sort: dateField, Conds: conds}
+	for param, field := range map[string]string{"fromAccount": fromAccountField, "toAccount": toAccountField} {
+		if account := ctx.QueryParam(param); len(account) != 0 {
+			q.Conds = append(q.Conds, db.Cond{Field: field, Op: db.OpEq, Value: account})
+		}
+	}
+	if err := pageParams(ctx, &q); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	page, err := h.transferStore.Query(ctx.Request().Context(), q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"transfers": page.Items, "next": page.Next})
+}
+
+func (h TransferHandler) PostTransfer(ctx echo.Context) error {
+	ownerID := middleware.GetUserIDFromRequest(ctx.Request())
+	params, err := utils.DecodeBody[types.CreateTransferParams](ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	c := ctx.Request().Context()
+	from, err := findAccount(c, h.accountStore, params.FromAccountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	to, err := findAccount(c, h.accountStore, params.ToAccountID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	params.FromCurrency, params.ToCurrency = from.Currency, to.Currency
+	errs := map[string]string{}
+	if len(params.FromAccountID) != 0 && len(from.ID) == 0 {
+		errs[fromAccountField] = fmt.Sprintf("account with id = %s doesn't exist", params.FromAccountID)
+	}
+	if len(params.ToAccountID) != 0 && len(to.ID) == 0 {
+		errs[toAccountField] = fmt.Sprintf("account with id = %s doesn't exist", params.ToAccountID)
+	}
+	if len(errs) == 0 {
+		errs = params.Validate()
+	}
+	if len(errs) != 0 {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"validationErrors": errs})
+	}
+	transfer, err := types.NewTransferFromParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	transfer.OwnerID = ownerID
+	id, err := h.transferStore.Create(c, transfer)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
+
+func (h TransferHandler) GetTransfer(ctx echo.Context) error {
+	id := ctx.Param("id")
+	transfer, err := h.transferStore.GetByID(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"transfer": transfer})
+}
+
+func (h TransferHandler) DeleteTransfer(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := h.transferStore.Delete(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
+}
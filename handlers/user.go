@@ -2,22 +2,30 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/SpectralJager/spender/db"
 	"github.com/SpectralJager/spender/middleware"
+	"github.com/SpectralJager/spender/tenant"
 	"github.com/SpectralJager/spender/types"
 	"github.com/SpectralJager/spender/utils"
 	"github.com/labstack/echo/v4"
 )
 
 type UserHandler struct {
-	userStore db.UserStore
+	userStore         db.UserStore
+	groupStore        db.GroupStore
+	groupExpenseStore db.GroupExpenseStore
 }
 
-func NewUserHandler(userStore db.UserStore) *UserHandler {
+func NewUserHandler(userStore db.UserStore, groupStore db.GroupStore, groupExpenseStore db.GroupExpenseStore) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
+		userStore:         userStore,
+		groupStore:        groupStore,
+		groupExpenseStore: groupExpenseStore,
 	}
 }
 
@@ -27,17 +35,72 @@ func (h UserHandler) GetUser(ctx echo.Context) error {
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
+	user.Role = user.UserRole()
 	return ctx.JSON(http.StatusOK, echo.Map{"user": user})
 }
 
+// DeleteUser deletes the user once the balances of the user in groups are
+// settled, and takes the user out of the groups the user is a member of or
+// invited to. Groups the user created go to the member who joined them
+// next, or are deleted when the user is their only member.
 func (h UserHandler) DeleteUser(ctx echo.Context) error {
 	id := middleware.GetUserIDFromRequest(ctx.Request())
-	if err := h.userStore.Delete(context.TODO(), id); err != nil {
+	c := ctx.Request().Context()
+	groups, err := queryAll(c, h.groupStore, db.Query{Conds: []db.Cond{{Field: membersField, Op: db.OpAny, Value: []string{id}}}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, group := range groups {
+		expenses, err := queryAll(tenant.WithGroup(c, group.ID), h.groupExpenseStore, db.Query{})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+		for _, balance := range types.GroupBalances(group.Members, expenses) {
+			if balance.UserID == id && balance.Balance != 0 {
+				return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": fmt.Sprintf("balance of the user in group %s should be settled up before deleting the user", group.Name)})
+			}
+		}
+	}
+	for _, group := range groups {
+		if err := h.leaveGroup(c, group, id); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	invitations, err := queryAll(c, h.groupStore, db.Query{Conds: []db.Cond{{Field: invitedField, Op: db.OpAny, Value: []string{id}}}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	for _, group := range invitations {
+		err := h.groupStore.UpdateSets(c, group.ID, nil, []db.SetChange{{Field: invitedField, Value: id, Remove: true}})
+		if err != nil && !errors.Is(err, db.ErrNoMatch) {
+			return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+	if err := h.userStore.Delete(c, id); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 	return ctx.JSON(http.StatusOK, echo.Map{"resutl": "done", "id": id})
 }
 
+// leaveGroup takes the user with id out of the members of group, handing
+// the group over to the next member when the user created it.
+func (h UserHandler) leaveGroup(ctx context.Context, group types.Group, id string) error {
+	if group.CreatedBy == id {
+		others := slices.DeleteFunc(slices.Clone(group.Members), func(member string) bool { return member == id })
+		if len(others) == 0 {
+			return deleteGroup(ctx, h.groupStore, h.groupExpenseStore, group)
+		}
+		if err := h.groupStore.Update(ctx, group.ID, types.GroupCreatorParams{CreatedBy: others[0]}); err != nil {
+			return err
+		}
+	}
+	err := h.groupStore.UpdateSets(ctx, group.ID, nil, []db.SetChange{{Field: membersField, Value: id, Remove: true}})
+	if errors.Is(err, db.ErrNoMatch) {
+		return nil
+	}
+	return err
+}
+
 func (h UserHandler) PutUser(ctx echo.Context) error {
 	params, err := utils.DecodeBody[types.UpdateUserParams](ctx.Request().Body)
 	if err != nil {
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"github.com/SpectralJager/spender/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const tokenHashField = "tokenHash"
+
+// NewAPIAuthentication returns the authentication of routes of a resource
+// like moneyspend. Requests are authenticated by an access token like
+// NewJWTAuthentication does, or by an API key in the X-Api-Token header
+// whose scopes grant reading the resource for GET requests and writing it
+// for others. Routes authenticated by NewJWTAuthentication alone refuse API
+// keys.
+func NewAPIAuthentication(userStore db.UserStore, sessionStore db.SessionStore, apiKeyStore db.APIKeyStore) func(resource string) echo.MiddlewareFunc {
+	jwtAuthentication := NewJWTAuthentication(userStore, sessionStore)
+	return func(resource string) echo.MiddlewareFunc {
+		if !slices.Contains(types.APIResources, resource) {
+			panic(fmt.Sprintf("unknown api resource %q", resource))
+		}
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			withJWT := jwtAuthentication(next)
+			return func(ctx echo.Context) error {
+				token := ctx.Request().Header.Get("X-Api-Token")
+				if !strings.HasPrefix(token, types.APIKeyPrefix) {
+					return withJWT(ctx)
+				}
+
+				req := ctx.Request()
+				system := tenant.WithSystem(req.Context())
+				page, err := apiKeyStore.Query(system, db.Query{
+					Conds: []db.Cond{{Field: tokenHashField, Op: db.OpEq, Value: utils.HashToken(token)}},
+					Limit: 1,
+				})
+				if err != nil {
+					log.Println(err)
+					return fmt.Errorf("unauthorized")
+				}
+				if len(page.Items) == 0 {
+					return fmt.Errorf("unauthorized")
+				}
+				key := page.Items[0]
+				if key.IsExpired(time.Now()) {
+					return fmt.Errorf("unauthorized")
+				}
+
+				user, err := userStore.GetByID(req.Context(), key.OwnerID)
+				if err != nil {
+					log.Println(err)
+					return fmt.Errorf("unauthorized")
+				}
+				if user.Disabled {
+					return fmt.Errorf("unauthorized")
+				}
+
+				write := req.Method != http.MethodGet && req.Method != http.MethodHead
+				if !key.Allows(resource, write) {
+					return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "api key doesn't have the scope required"})
+				}
+
+				ctx.SetRequest(req.WithContext(tenant.WithOwner(req.Context(), key.OwnerID)))
+				return next(ctx)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const groupKey = "group"
+
+// GroupMembership lets only members of the group in the id path parameter
+// through, and scopes the request to the group. It runs after
+// NewJWTAuthentication.
+func GroupMembership(groupStore db.GroupStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			group, err := groupStore.GetByID(req.Context(), ctx.Param("id"))
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			}
+			if !group.IsMember(GetUserIDFromRequest(req)) {
+				return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "user is not a member of the group"})
+			}
+			ctx.Set(groupKey, group)
+			ctx.SetRequest(req.WithContext(tenant.WithGroup(req.Context(), group.ID)))
+			return next(ctx)
+		}
+	}
+}
+
+// GetGroup returns the group GroupMembership checked the user is a member of.
+func GetGroup(ctx echo.Context) types.Group {
+	group, _ := ctx.Get(groupKey).(types.Group)
+	return group
+}
@@ -1,94 +1,120 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-var (
-	secret = []byte("secret")
-)
+const (
+	// AccessTokenTTL is how long access tokens are valid, clients renew them
+	// with their refresh token.
+	AccessTokenTTL = 15 * time.Minute
 
-type ctxKey string
+	sessionKey = "session"
+)
 
-const (
-	userIDKey ctxKey = "userid"
+var (
+	keyring *Keyring
 )
 
+// UseKeyring sets the keys tokens are signed and verified with, it is called
+// once on start.
+func UseKeyring(k *Keyring) {
+	keyring = k
+}
+
 type AuthClaims struct {
-	UserID string `json:"userid"`
+	UserID    string `json:"userid"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func JWTAuthentication(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(ctx echo.Context) error {
-		tokenStr := ctx.Request().Header.Get("X-Api-Token")
-		log.Println("JWT token: ", tokenStr)
-		if len(tokenStr) == 0 {
-			return fmt.Errorf("unauthorized")
-		}
+// NewJWTAuthentication authenticates requests by the token in the
+// X-Api-Token header. Tokens of disabled users, of sessions the user logged
+// out of and tokens issued before the role of their user changed are refused.
+func NewJWTAuthentication(userStore db.UserStore, sessionStore db.SessionStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			tokenStr := ctx.Request().Header.Get("X-Api-Token")
+			if len(tokenStr) == 0 || strings.HasPrefix(tokenStr, types.APIKeyPrefix) {
+				return fmt.Errorf("unauthorized")
+			}
 
-		token, err := parseJWTToken(tokenStr)
-		if err != nil {
-			log.Println(err)
-			return fmt.Errorf("unauthorized")
-		}
+			token, err := parseJWTToken(tokenStr)
+			if err != nil {
+				log.Println(err)
+				return fmt.Errorf("unauthorized")
+			}
 
-		claims, ok := token.Claims.(*AuthClaims)
-		if !ok {
-			log.Panicln("unexpected claims")
-			return fmt.Errorf("unauthorized")
-		}
+			claims, ok := token.Claims.(*AuthClaims)
+			if !ok {
+				return fmt.Errorf("unauthorized")
+			}
 
-		req := ctx.Request()
-		ctx.SetRequest(
-			req.WithContext(
-				context.WithValue(
-					req.Context(),
-					userIDKey,
-					claims.UserID,
-				),
-			),
-		)
-
-		return next(ctx)
+			if len(claims.UserID) == 0 || len(claims.SessionID) == 0 {
+				return fmt.Errorf("unauthorized")
+			}
+
+			req := ctx.Request()
+			user, err := userStore.GetByID(req.Context(), claims.UserID)
+			if err != nil {
+				log.Println(err)
+				return fmt.Errorf("unauthorized")
+			}
+			if user.Disabled || user.UserRole() != claimedRole(claims) {
+				return fmt.Errorf("unauthorized")
+			}
+
+			scoped := tenant.WithOwner(req.Context(), claims.UserID)
+			if _, err := sessionStore.GetByID(scoped, claims.SessionID); err != nil {
+				log.Println(err)
+				return fmt.Errorf("unauthorized")
+			}
+
+			ctx.Set(roleKey, user.UserRole())
+			ctx.Set(sessionKey, claims.SessionID)
+			ctx.SetRequest(req.WithContext(scoped))
+
+			return next(ctx)
+		}
 	}
 }
 
 func parseJWTToken(tokenStr string) (*jwt.Token, error) {
-	return jwt.ParseWithClaims(tokenStr, &AuthClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return secret, nil
-	})
+	return jwt.ParseWithClaims(tokenStr, &AuthClaims{}, keyring.verificationKey)
 }
 
-func NewJWTTokenString(userID string) (string, error) {
+// NewJWTTokenString returns an access token of user in the session with
+// sessionID.
+func NewJWTTokenString(user types.User, sessionID string) (string, error) {
 	claims := &AuthClaims{
-		UserID: userID,
+		UserID:    user.ID,
+		Role:      user.UserRole(),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 2)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	ss, err := token.SignedString(secret)
-	if err != nil {
-		return "", err
-	}
-	return ss, nil
+	return keyring.sign(claims)
 }
 
 func GetUserIDFromRequest(req *http.Request) string {
-	if userID, ok := req.Context().Value(userIDKey).(string); ok {
-		return userID
-	}
-	return ""
+	return tenant.OwnerID(req.Context())
+}
+
+// GetSessionID returns the session the request was authenticated in.
+func GetSessionID(ctx echo.Context) string {
+	sessionID, _ := ctx.Get(sessionKey).(string)
+	return sessionID
 }
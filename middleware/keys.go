@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	pemKeyExt    = ".pem"
+	secretKeyExt = ".secret"
+
+	minSecretLen = 32
+)
+
+// signingKey verifies tokens with its kid, and signs them when it has a
+// private key.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// Keyring holds the keys tokens are verified with, keyed by the kid header
+// of tokens, and the key new tokens are signed with. Keeping the former
+// signing key for verification lets keys rotate without refusing tokens
+// signed before.
+type Keyring struct {
+	keys    map[string]signingKey
+	kids    []string
+	signing signingKey
+}
+
+// LoadKeyring loads the keys in dir, the kid of a key is its file name
+// without extension. Files ending in .pem hold an RSA, a P-256 or an Ed25519
+// key, signing with RS256, ES256 or EdDSA. Public keys only verify tokens.
+// Files ending in .secret hold an HMAC secret of at least 32 bytes for
+// HS512. Tokens are signed with the key with signingKID, or with the private
+// key with the last kid when it is empty.
+func LoadKeyring(dir, signingKID string) (*Keyring, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	keyring := &Keyring{keys: map[string]signingKey{}}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != pemKeyExt && ext != secretKeyExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(entry.Name(), ext)
+		var key signingKey
+		if ext == secretKeyExt {
+			key, err = secretKey(kid, data)
+		} else {
+			key, err = pemKey(kid, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't load key %s: %w", entry.Name(), err)
+		}
+		if _, ok := keyring.keys[kid]; ok {
+			return nil, fmt.Errorf("there are several keys with kid %s", kid)
+		}
+		keyring.add(key)
+	}
+	if len(signingKID) == 0 {
+		for i := len(keyring.kids) - 1; i >= 0; i-- {
+			if keyring.keys[keyring.kids[i]].private != nil {
+				signingKID = keyring.kids[i]
+				break
+			}
+		}
+	}
+	key, ok := keyring.keys[signingKID]
+	if !ok || key.private == nil {
+		return nil, fmt.Errorf("there is no private key with kid %q in %s", signingKID, dir)
+	}
+	keyring.signing = key
+	return keyring, nil
+}
+
+// NewEphemeralKeyring returns a keyring with an Ed25519 key generated for
+// the life of the process, tokens it signs are refused after a restart.
+func NewEphemeralKeyring() (*Keyring, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyring := &Keyring{keys: map[string]signingKey{}}
+	keyring.add(signingKey{
+		kid:     "ephemeral",
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	})
+	keyring.signing = keyring.keys["ephemeral"]
+	return keyring, nil
+}
+
+func (keyring *Keyring) add(key signingKey) {
+	keyring.keys[key.kid] = key
+	keyring.kids = append(keyring.kids, key.kid)
+	slices.Sort(keyring.kids)
+}
+
+// SigningKID returns the kid of the key tokens are signed with.
+func (keyring *Keyring) SigningKID() string {
+	return keyring.signing.kid
+}
+
+func (keyring *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(keyring.signing.method, claims)
+	token.Header["kid"] = keyring.signing.kid
+	return token.SignedString(keyring.signing.private)
+}
+
+// verificationKey returns the key token is verified with, tokens should
+// name it by kid and be signed with its algorithm.
+func (keyring *Keyring) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keyring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys tokens are verified with, HMAC secrets are
+// left out.
+func (keyring *Keyring) JWKS() []JWK {
+	jwks := []JWK{}
+	for _, kid := range keyring.kids {
+		key := keyring.keys[kid]
+		jwk := JWK{Kid: kid, Alg: key.method.Alg(), Use: "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeJWKInt(public.N.Bytes())
+			jwk.E = encodeJWKInt(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = encodeJWKInt(public.X.FillBytes(make([]byte, 32)))
+			jwk.Y = encodeJWKInt(public.Y.FillBytes(make([]byte, 32)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encodeJWKInt(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+func encodeJWKInt(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func secretKey(kid string, data []byte) (signingKey, error) {
+	if len(data) < minSecretLen {
+		return signingKey{}, fmt.Errorf("secret should be at least %d bytes", minSecretLen)
+	}
+	return signingKey{kid: kid, method: jwt.SigningMethodHS512, private: data, public: data}, nil
+}
+
+func pemKey(kid string, data []byte) (signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, fmt.Errorf("no PEM block found")
+	}
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return signingKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+	signing := signingKey{kid: kid}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signing.method, signing.private, signing.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		signing.method, signing.public = jwt.SigningMethodRS256, key
+	case *ecdsa.PrivateKey:
+		signing.method, signing.private, signing.public = jwt.SigningMethodES256, key, &key.PublicKey
+	case *ecdsa.PublicKey:
+		signing.method, signing.public = jwt.SigningMethodES256, key
+	case ed25519.PrivateKey:
+		signing.method, signing.private, signing.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		signing.method, signing.public = jwt.SigningMethodEdDSA, key
+	default:
+		return signingKey{}, fmt.Errorf("unsupported key type %T", key)
+	}
+	if public, ok := signing.public.(*ecdsa.PublicKey); ok && public.Curve != elliptic.P256() {
+		return signingKey{}, fmt.Errorf("EC keys should be on the P-256 curve")
+	}
+	return signing, nil
+}
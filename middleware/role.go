@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/SpectralJager/spender/types"
+	"github.com/labstack/echo/v4"
+)
+
+const roleKey = "role"
+
+// RequireRoles lets only users with one of roles through. It runs after
+// NewJWTAuthentication.
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !slices.Contains(roles, GetRole(ctx)) {
+				return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": "user doesn't have the role required"})
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// GetRole returns the role of the authenticated user.
+func GetRole(ctx echo.Context) string {
+	role, _ := ctx.Get(roleKey).(string)
+	return role
+}
+
+// claimedRole returns the role claimed by a token, tokens issued before roles
+// were introduced claim none.
+func claimedRole(claims *AuthClaims) string {
+	if len(claims.Role) == 0 {
+		return types.RoleUser
+	}
+	return claims.Role
+}
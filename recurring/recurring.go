@@ -0,0 +1,142 @@
+// Package recurring materializes the due occurrences of recurring rules of
+// all users into moneyspends and timespends.
+package recurring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SpectralJager/spender/db"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+)
+
+const recurringField = "recurringId"
+
+type Scheduler struct {
+	recurringStore  db.RecurringStore
+	moneyspendStore db.SpendStor[types.Moneyspend]
+	timespendStore  db.SpendStor[types.Timespend]
+}
+
+func NewScheduler(recurringStore db.RecurringStore, moneyspendStore db.SpendStor[types.Moneyspend], timespendStore db.SpendStor[types.Timespend]) *Scheduler {
+	return &Scheduler{
+		recurringStore:  recurringStore,
+		moneyspendStore: moneyspendStore,
+		timespendStore:  timespendStore,
+	}
+}
+
+// Run materializes due occurrences right away and then every interval until
+// ctx is done, logging failures. Occurrences missed while the process was
+// down are materialized on the first run.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		created, err := s.Tick(ctx, time.Now())
+		if err != nil {
+			log.Printf("recurring: %v", err)
+		}
+		if created != 0 {
+			log.Printf("recurring: created %d entries", created)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick materializes every occurrence at or before now of the rules which
+// aren't done and returns the number of created entries. Entries already
+// created for an occurrence aren't created again, so a tick interrupted
+// before its rules were advanced can be repeated. A failing rule doesn't
+// stop the others and is retried on the next tick.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) (int, error) {
+	system := tenant.WithSystem(ctx)
+	q := db.Query{
+		Conds: []db.Cond{
+			{Field: "done", Op: db.OpEq, Value: false},
+			{Field: "next", Op: db.OpLte, Value: now},
+		},
+		Limit: db.MaxLimit,
+	}
+	created := 0
+	var errs []error
+	for {
+		page, err := s.recurringStore.Query(system, q)
+		if err != nil {
+			return created, errors.Join(append(errs, err)...)
+		}
+		for _, rule := range page.Items {
+			n, err := s.materialize(tenant.WithOwner(ctx, rule.OwnerID), rule, now)
+			created += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule %s: %w", rule.ID, err))
+			}
+		}
+		if len(page.Next) == 0 {
+			return created, errors.Join(errs...)
+		}
+		q.After = page.Next
+	}
+}
+
+// materialize creates the entries of the occurrences of rule at or before
+// now, then advances the rule past them.
+func (s *Scheduler) materialize(ctx context.Context, rule types.Recurring, now time.Time) (int, error) {
+	created := 0
+	n := rule.NextIndex
+	for ; !rule.Ended(n); n++ {
+		date := rule.Occurrence(n)
+		if date.After(now) {
+			break
+		}
+		if rule.IsSkipped(date) {
+			continue
+		}
+		ok, err := s.create(ctx, rule, date)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, s.recurringStore.Update(ctx, rule.ID, types.AdvanceRecurringParams{
+		Next:      rule.Occurrence(n),
+		NextIndex: n,
+		Done:      rule.Ended(n),
+	})
+}
+
+// create creates the entry of the occurrence of rule at date unless it
+// exists, and reports whether it did.
+func (s *Scheduler) create(ctx context.Context, rule types.Recurring, date time.Time) (bool, error) {
+	q := db.Query{
+		Conds: []db.Cond{
+			{Field: recurringField, Op: db.OpEq, Value: rule.ID},
+			{Field: "date", Op: db.OpEq, Value: date},
+		},
+		Limit: 1,
+	}
+	if rule.Kind == types.RecurringMoney {
+		page, err := s.moneyspendStore.Query(ctx, q)
+		if err != nil || len(page.Items) != 0 {
+			return false, err
+		}
+		_, err = s.moneyspendStore.Create(ctx, rule.Moneyspend(date))
+		return err == nil, err
+	}
+	page, err := s.timespendStore.Query(ctx, q)
+	if err != nil || len(page.Items) != 0 {
+		return false, err
+	}
+	_, err = s.timespendStore.Create(ctx, rule.Timespend(date))
+	return err == nil, err
+}
@@ -0,0 +1,83 @@
+package recurring_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/db/memory"
+	"github.com/SpectralJager/spender/recurring"
+	"github.com/SpectralJager/spender/tenant"
+	"github.com/SpectralJager/spender/types"
+)
+
+const ownerID = "6650c0ffee0000000000000a"
+
+func TestTickIsIdempotent(t *testing.T) {
+	ctx := tenant.WithOwner(context.Background(), ownerID)
+	recurringStore := memory.NewRecurringStore()
+	moneyspendStore := memory.NewMoneyspendStore()
+	scheduler := recurring.NewScheduler(recurringStore, moneyspendStore, memory.NewTimespendStore())
+
+	start := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+	id, err := recurringStore.Create(ctx, types.Recurring{
+		OwnerID:  ownerID,
+		Kind:     types.RecurringMoney,
+		Freq:     types.RecurringDaily,
+		Interval: 1,
+		Start:    start,
+		Count:    5,
+		Money:    1000,
+		Currency: "EUR",
+		Skipped:  []string{"2024-03-03"},
+		Next:     start,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	tick := func(now time.Time, want int) {
+		t.Helper()
+		created, err := scheduler.Tick(context.Background(), now)
+		if err != nil {
+			t.Fatalf("tick at %s: %v", now, err)
+		}
+		if created != want {
+			t.Fatalf("tick at %s created %d entries, want %d", now, created, want)
+		}
+	}
+	tick(start.Add(-time.Minute), 0)
+	tick(start.AddDate(0, 0, 2), 2)
+	tick(start.AddDate(0, 0, 2), 0)
+	// catching up after downtime creates the missed occurrences at once
+	tick(start.AddDate(0, 1, 0), 2)
+	tick(start.AddDate(0, 2, 0), 0)
+
+	// a tick interrupted before the rule was advanced is repeated
+	err = recurringStore.Update(ctx, id, types.AdvanceRecurringParams{Next: start})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	tick(start.AddDate(0, 2, 0), 0)
+
+	moneyspends, err := moneyspendStore.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	dates := []string{}
+	for _, moneyspend := range moneyspends {
+		dates = append(dates, moneyspend.Date.Format(time.DateOnly))
+	}
+	want := []string{"2024-03-01", "2024-03-02", "2024-03-04", "2024-03-05"}
+	if !slices.Equal(dates, want) {
+		t.Fatalf("scheduler created moneyspends on %v, want %v", dates, want)
+	}
+	rule, err := recurringStore.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+	if !rule.Done {
+		t.Fatalf("rule isn't done after its last occurrence")
+	}
+}
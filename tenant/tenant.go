@@ -0,0 +1,46 @@
+package tenant
+
+import "context"
+
+type ctxKey string
+
+const (
+	scopeKey ctxKey = "scope"
+)
+
+type Scope struct {
+	OwnerID string
+	// GroupID is the group the owner acts in, set once membership is checked.
+	GroupID string
+	System  bool
+}
+
+func WithOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, scopeKey, Scope{OwnerID: ownerID})
+}
+
+// WithGroup keeps the owner of the scope of ctx and adds groupID to it.
+func WithGroup(ctx context.Context, groupID string) context.Context {
+	scope, _ := FromContext(ctx)
+	scope.GroupID = groupID
+	return context.WithValue(ctx, scopeKey, scope)
+}
+
+func WithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey, Scope{System: true})
+}
+
+func FromContext(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey).(Scope)
+	return scope, ok
+}
+
+func OwnerID(ctx context.Context) string {
+	scope, _ := FromContext(ctx)
+	return scope.OwnerID
+}
+
+func GroupID(ctx context.Context) string {
+	scope, _ := FromContext(ctx)
+	return scope.GroupID
+}
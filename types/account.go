@@ -0,0 +1,239 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	minAccountNameLen = 1
+	maxAccountNameLen = 32
+
+	AccountCash = "cash"
+	AccountCard = "card"
+	AccountBank = "bank"
+)
+
+func isAccountKind(kind string) bool {
+	return kind == AccountCash || kind == AccountCard || kind == AccountBank
+}
+
+// CreateAccountParams parses OpeningBalance in the decimals of Currency,
+// handlers set Currency to the base currency of the user when it is not
+// given.
+type CreateAccountParams struct {
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"`
+	Currency       string    `json:"currency"`
+	OpeningBalance Decimal   `json:"openingBalance"`
+	OpeningDate    time.Time `json:"openingDate"`
+}
+
+func (params CreateAccountParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) < minAccountNameLen || len(params.Name) > maxAccountNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from %d to %d characters", minAccountNameLen, maxAccountNameLen)
+	}
+	if !isAccountKind(params.Kind) {
+		errors["kind"] = fmt.Sprintf("kind should be one of %s, %s, %s", AccountCash, AccountCard, AccountBank)
+	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	if len(params.OpeningBalance) != 0 {
+		if _, err := ParseMoney(params.OpeningBalance, params.Currency); err != nil {
+			errors["openingBalance"] = err.Error()
+		}
+	}
+	return errors
+}
+
+// UpdateAccountParams can't change the currency of an account, handlers set
+// Currency to the one of the updated account to parse OpeningBalance.
+type UpdateAccountParams struct {
+	Name           string    `bson:"name,omitempty" json:"name"`
+	Kind           string    `bson:"kind,omitempty" json:"kind"`
+	OpeningBalance Decimal   `bson:"-" json:"openingBalance"`
+	OpeningDate    time.Time `bson:"openingDate,omitempty" json:"openingDate"`
+	Currency       string    `bson:"-" json:"-"`
+}
+
+func (params UpdateAccountParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) > maxAccountNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be less or equal then %d characters", maxAccountNameLen)
+	}
+	if len(params.Kind) != 0 && !isAccountKind(params.Kind) {
+		errors["kind"] = fmt.Sprintf("kind should be one of %s, %s, %s", AccountCash, AccountCard, AccountBank)
+	}
+	if len(params.OpeningBalance) != 0 {
+		if _, err := ParseMoney(params.OpeningBalance, params.Currency); err != nil {
+			errors["openingBalance"] = err.Error()
+		}
+	}
+	return errors
+}
+
+func (params UpdateAccountParams) ToBsonDoc() (*bson.D, error) {
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.OpeningBalance) != 0 {
+		balance, err := ParseMoney(params.OpeningBalance, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		*doc = append(*doc, bson.E{Key: "openingBalance", Value: balance})
+	}
+	return doc, nil
+}
+
+// Account is where money of moneyspends and incomes is drawn from and paid
+// into, like a wallet or a bank account. Its balance starts with
+// OpeningBalance on OpeningDate, earlier entries don't change it.
+type Account struct {
+	ID             string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID        string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Name           string    `bson:"name" json:"name"`
+	Kind           string    `bson:"kind" json:"kind"`
+	Currency       string    `bson:"currency" json:"currency"`
+	OpeningBalance Money     `bson:"openingBalance" json:"openingBalance"`
+	OpeningDate    time.Time `bson:"openingDate" json:"openingDate"`
+}
+
+// MarshalJSON writes OpeningBalance as an exact decimal string in the
+// decimals of Currency.
+func (account Account) MarshalJSON() ([]byte, error) {
+	type plain Account
+	return json.Marshal(struct {
+		plain
+		OpeningBalance Amount `json:"openingBalance"`
+	}{
+		plain:          plain(account),
+		OpeningBalance: Amount{Money: account.OpeningBalance, Currency: account.Currency},
+	})
+}
+
+func NewAccountFromParams(params CreateAccountParams) (Account, error) {
+	var balance Money
+	if len(params.OpeningBalance) != 0 {
+		var err error
+		balance, err = ParseMoney(params.OpeningBalance, params.Currency)
+		if err != nil {
+			return Account{}, err
+		}
+	}
+	return Account{
+		Name:           params.Name,
+		Kind:           params.Kind,
+		Currency:       params.Currency,
+		OpeningBalance: balance,
+		OpeningDate:    params.OpeningDate,
+	}, nil
+}
+
+// CreateTransferParams parses Money in the decimals of the currency of the
+// from account and ToMoney in the decimals of the currency of the to
+// account, handlers set both currencies. ToMoney defaults to Money between
+// accounts of the same currency.
+type CreateTransferParams struct {
+	FromAccountID string    `json:"fromAccountId"`
+	ToAccountID   string    `json:"toAccountId"`
+	Money         Decimal   `json:"money"`
+	ToMoney       Decimal   `json:"toMoney"`
+	Date          time.Time `json:"date"`
+	Note          string    `json:"note"`
+	FromCurrency  string    `json:"-"`
+	ToCurrency    string    `json:"-"`
+}
+
+func (params CreateTransferParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.FromAccountID) == 0 {
+		errors["fromAccountId"] = "fromAccountId should be set"
+	}
+	if len(params.ToAccountID) == 0 {
+		errors["toAccountId"] = "toAccountId should be set"
+	}
+	if len(params.FromAccountID) != 0 && params.FromAccountID == params.ToAccountID {
+		errors["toAccountId"] = "money can't be transferred to the same account"
+	}
+	if params.Date.IsZero() {
+		errors["date"] = "date should be not zero"
+	}
+	if money, err := ParseMoney(params.Money, params.FromCurrency); err != nil {
+		errors["money"] = err.Error()
+	} else if money <= 0 {
+		errors["money"] = "money should be more then 0"
+	}
+	if len(params.ToMoney) == 0 {
+		if params.FromCurrency != params.ToCurrency {
+			errors["toMoney"] = "toMoney should be set between accounts of different currencies"
+		}
+	} else if money, err := ParseMoney(params.ToMoney, params.ToCurrency); err != nil {
+		errors["toMoney"] = err.Error()
+	} else if money <= 0 {
+		errors["toMoney"] = "toMoney should be more then 0"
+	}
+	return errors
+}
+
+// Transfer moves money between two accounts of its owner, it is neither a
+// spend nor an income. Money leaves the from account in its currency and
+// ToMoney enters the to account in its currency.
+type Transfer struct {
+	ID            string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID       string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	FromAccountID string    `bson:"fromAccountId" json:"fromAccountId"`
+	ToAccountID   string    `bson:"toAccountId" json:"toAccountId"`
+	Money         Money     `bson:"money" json:"money"`
+	FromCurrency  string    `bson:"fromCurrency" json:"fromCurrency"`
+	ToMoney       Money     `bson:"toMoney" json:"toMoney"`
+	ToCurrency    string    `bson:"toCurrency" json:"toCurrency"`
+	Date          time.Time `bson:"date" json:"date"`
+	Note          string    `bson:"note" json:"note"`
+}
+
+// MarshalJSON writes Money and ToMoney as exact decimal strings in the
+// decimals of their currencies.
+func (transfer Transfer) MarshalJSON() ([]byte, error) {
+	type plain Transfer
+	return json.Marshal(struct {
+		plain
+		Money   Amount `json:"money"`
+		ToMoney Amount `json:"toMoney"`
+	}{
+		plain:   plain(transfer),
+		Money:   Amount{Money: transfer.Money, Currency: transfer.FromCurrency},
+		ToMoney: Amount{Money: transfer.ToMoney, Currency: transfer.ToCurrency},
+	})
+}
+
+func NewTransferFromParams(params CreateTransferParams) (Transfer, error) {
+	money, err := ParseMoney(params.Money, params.FromCurrency)
+	if err != nil {
+		return Transfer{}, err
+	}
+	toMoney := money
+	if len(params.ToMoney) != 0 {
+		toMoney, err = ParseMoney(params.ToMoney, params.ToCurrency)
+		if err != nil {
+			return Transfer{}, err
+		}
+	}
+	return Transfer{
+		FromAccountID: params.FromAccountID,
+		ToAccountID:   params.ToAccountID,
+		Money:         money,
+		FromCurrency:  params.FromCurrency,
+		ToMoney:       toMoney,
+		ToCurrency:    params.ToCurrency,
+		Date:          params.Date,
+		Note:          params.Note,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyPrefix starts API keys, telling them apart from access tokens.
+	APIKeyPrefix = "spk_"
+
+	// ScopeRead and ScopeWrite grant reading or reading and writing all
+	// resources, a scope like moneyspend:write grants it on one resource.
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+
+	maxAPIKeyNameLen = 64
+	maxAPIKeyScopes  = 32
+	// apiKeyPrefixLen is how much of an API key is kept to recognize it.
+	apiKeyPrefixLen = len(APIKeyPrefix) + 4
+)
+
+// APIResources are the resources API keys can be scoped to, named like
+// their routes.
+var APIResources = []string{
+	"timespend", "moneyspend", "income", "accounts", "transfers", "budgets",
+	"projects", "invoices", "groups", "recurring", "categories", "tags",
+	"rates", "report",
+}
+
+// IsAPIScope reports whether scope is read, write or one of them on an API
+// resource like moneyspend:write.
+func IsAPIScope(scope string) bool {
+	if scope == ScopeRead || scope == ScopeWrite {
+		return true
+	}
+	resource, access, ok := strings.Cut(scope, ":")
+	return ok && slices.Contains(APIResources, resource) && (access == ScopeRead || access == ScopeWrite)
+}
+
+// CreateAPIKeyParams name an API key and the scopes it grants, the key is
+// valid until ExpiresAt or forever when it is zero.
+type CreateAPIKeyParams struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (params CreateAPIKeyParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) == 0 || len(params.Name) > maxAPIKeyNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from 1 to %d characters", maxAPIKeyNameLen)
+	}
+	if len(params.Scopes) == 0 || len(params.Scopes) > maxAPIKeyScopes {
+		errors["scopes"] = fmt.Sprintf("scopes should have from 1 to %d scopes", maxAPIKeyScopes)
+	}
+	for _, scope := range params.Scopes {
+		if !IsAPIScope(scope) {
+			errors["scopes"] = fmt.Sprintf("scope %q should be %s, %s or a resource like moneyspend:%s", scope, ScopeRead, ScopeWrite, ScopeWrite)
+			break
+		}
+	}
+	return errors
+}
+
+// APIKey lets scripts use the api on behalf of its owner within its scopes,
+// only the hash of the key is stored.
+type APIKey struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID   string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Name      string    `bson:"name" json:"name"`
+	Prefix    string    `bson:"prefix" json:"prefix"`
+	TokenHash string    `bson:"tokenHash" json:"-"`
+	Scopes    []string  `bson:"scopes" json:"scopes"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+func NewAPIKeyFromParams(params CreateAPIKeyParams, token, tokenHash string, now time.Time) APIKey {
+	scopes := slices.Clone(params.Scopes)
+	slices.Sort(scopes)
+	return APIKey{
+		Name:      params.Name,
+		Prefix:    token[:apiKeyPrefixLen],
+		TokenHash: tokenHash,
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: now.UTC().Truncate(time.Millisecond),
+		ExpiresAt: params.ExpiresAt.UTC().Truncate(time.Millisecond),
+	}
+}
+
+// IsExpired reports whether the key is expired at now.
+func (key APIKey) IsExpired(now time.Time) bool {
+	return !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt)
+}
+
+// Allows reports whether the scopes of the key grant reading resource, or
+// writing it when write is set.
+func (key APIKey) Allows(resource string, write bool) bool {
+	for _, scope := range key.Scopes {
+		if scope == ScopeWrite || scope == resource+":"+ScopeWrite {
+			return true
+		}
+		if !write && (scope == ScopeRead || scope == resource+":"+ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
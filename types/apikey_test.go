@@ -0,0 +1,74 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpectralJager/spender/types"
+)
+
+func TestAPIKeyIsExpired(t *testing.T) {
+	expiresAt := time.Date(2024, time.March, 5, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		now       time.Time
+		want      bool
+	}{
+		{"before", expiresAt, expiresAt.Add(-time.Millisecond), false},
+		{"at expiry", expiresAt, expiresAt, true},
+		{"after", expiresAt, expiresAt.Add(time.Hour), true},
+		{"never expires", time.Time{}, expiresAt.AddDate(100, 0, 0), false},
+	}
+	for _, tt := range tests {
+		key := types.APIKey{ExpiresAt: tt.expiresAt}
+		if got := key.IsExpired(tt.now); got != tt.want {
+			t.Fatalf("%s: key expiring at %v is expired at %v: %v, want %v", tt.name, tt.expiresAt, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		resource string
+		write    bool
+		want     bool
+	}{
+		{[]string{"read"}, "moneyspend", false, true},
+		{[]string{"read"}, "moneyspend", true, false},
+		{[]string{"write"}, "moneyspend", true, true},
+		{[]string{"write"}, "report", false, true},
+		{[]string{"moneyspend:read"}, "moneyspend", false, true},
+		{[]string{"moneyspend:read"}, "moneyspend", true, false},
+		{[]string{"moneyspend:read"}, "income", false, false},
+		{[]string{"moneyspend:write"}, "moneyspend", false, true},
+		{[]string{"moneyspend:write"}, "timespend", true, false},
+		{[]string{"income:read", "moneyspend:write"}, "income", true, false},
+		{nil, "moneyspend", false, false},
+	}
+	for _, tt := range tests {
+		key := types.APIKey{Scopes: tt.scopes}
+		if got := key.Allows(tt.resource, tt.write); got != tt.want {
+			t.Fatalf("key with scopes %v allows %s with write %v: %v, want %v", tt.scopes, tt.resource, tt.write, got, tt.want)
+		}
+	}
+}
+
+func TestIsAPIScope(t *testing.T) {
+	for scope, want := range map[string]bool{
+		"read":             true,
+		"write":            true,
+		"moneyspend:read":  true,
+		"report:write":     true,
+		"admin":            false,
+		"moneyspend":       false,
+		"moneyspend:admin": false,
+		"users:read":       false,
+		"":                 false,
+	} {
+		if got := types.IsAPIScope(scope); got != want {
+			t.Fatalf("scope %q is valid: %v, want %v", scope, got, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	minBudgetNameLen    = 1
+	maxBudgetNameLen    = 32
+	maxBudgetKeywordLen = 64
+
+	BudgetMoney = "money"
+	BudgetTime  = "time"
+
+	BudgetWeek  = "week"
+	BudgetMonth = "month"
+)
+
+func validateBudgetPeriod(period string, errors map[string]string) {
+	if period != BudgetWeek && period != BudgetMonth {
+		errors["period"] = fmt.Sprintf("period should be %s or %s", BudgetWeek, BudgetMonth)
+	}
+}
+
+// CreateBudgetParams limits Money in Currency for money budgets, handlers
+// set Currency to the base currency of the user when it is not given, and
+// Duration for time budgets.
+type CreateBudgetParams struct {
+	Name       string        `json:"name"`
+	Kind       string        `json:"kind"`
+	Period     string        `json:"period"`
+	Money      Decimal       `json:"money"`
+	Currency   string        `json:"currency"`
+	Duration   time.Duration `json:"duration"`
+	CategoryID string        `json:"categoryId"`
+	Keyword    string        `json:"keyword"`
+}
+
+func (params CreateBudgetParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) < minBudgetNameLen || len(params.Name) > maxBudgetNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from %d to %d characters", minBudgetNameLen, maxBudgetNameLen)
+	}
+	validateBudgetPeriod(params.Period, errors)
+	if len(params.Keyword) > maxBudgetKeywordLen {
+		errors["keyword"] = fmt.Sprintf("keyword lenght should be less or equal then %d characters", maxBudgetKeywordLen)
+	}
+	switch params.Kind {
+	case BudgetMoney:
+		if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+			errors["money"] = err.Error()
+		} else if money <= 0 {
+			errors["money"] = "money should be more then 0"
+		}
+		if !IsCurrency(params.Currency) {
+			errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+		}
+		if params.Duration != 0 {
+			errors["duration"] = "duration is only for time budgets"
+		}
+	case BudgetTime:
+		if params.Duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
+		if len(params.Money) != 0 {
+			errors["money"] = "money is only for money budgets"
+		}
+	default:
+		errors["kind"] = fmt.Sprintf("kind should be %s or %s", BudgetMoney, BudgetTime)
+	}
+	return errors
+}
+
+// UpdateBudgetParams can't change the kind of a budget, handlers set Kind
+// to the one of the updated budget and Currency to its currency when it is
+// not given.
+type UpdateBudgetParams struct {
+	Name       string        `bson:"name,omitempty" json:"name"`
+	Period     string        `bson:"period,omitempty" json:"period"`
+	Money      Decimal       `bson:"-" json:"money"`
+	Currency   string        `bson:"currency,omitempty" json:"currency"`
+	Duration   time.Duration `bson:"duration,omitempty" json:"duration"`
+	CategoryID string        `bson:"categoryId,omitempty" json:"categoryId"`
+	Keyword    string        `bson:"keyword,omitempty" json:"keyword"`
+	Kind       string        `bson:"-" json:"-"`
+}
+
+func (params UpdateBudgetParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) > maxBudgetNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be less or equal then %d characters", maxBudgetNameLen)
+	}
+	if len(params.Period) != 0 {
+		validateBudgetPeriod(params.Period, errors)
+	}
+	if len(params.Keyword) > maxBudgetKeywordLen {
+		errors["keyword"] = fmt.Sprintf("keyword lenght should be less or equal then %d characters", maxBudgetKeywordLen)
+	}
+	if params.Kind == BudgetMoney {
+		if len(params.Money) != 0 {
+			if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+				errors["money"] = err.Error()
+			} else if money <= 0 {
+				errors["money"] = "money should be more then 0"
+			}
+		}
+		if len(params.Currency) != 0 && !IsCurrency(params.Currency) {
+			errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+		}
+		if params.Duration != 0 {
+			errors["duration"] = "duration is only for time budgets"
+		}
+	} else {
+		if params.Duration != 0 && params.Duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
+		if len(params.Money) != 0 || len(params.Currency) != 0 {
+			errors["money"] = "money is only for money budgets"
+		}
+	}
+	return errors
+}
+
+func (params UpdateBudgetParams) ToBsonDoc() (*bson.D, error) {
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Money) != 0 {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		*doc = append(*doc, bson.E{Key: "money", Value: money})
+	}
+	return doc, nil
+}
+
+// Budget limits the money or the time spent each week or month, on the
+// spends of a category and its subcategories, or of notes with a keyword,
+// or on all spends.
+type Budget struct {
+	ID         string        `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string        `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Name       string        `bson:"name" json:"name"`
+	Kind       string        `bson:"kind" json:"kind"`
+	Period     string        `bson:"period" json:"period"`
+	Money      Money         `bson:"money" json:"money,omitempty"`
+	Currency   string        `bson:"currency" json:"currency,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration,omitempty"`
+	CategoryID string        `bson:"categoryId" json:"categoryId,omitempty"`
+	Keyword    string        `bson:"keyword" json:"keyword,omitempty"`
+}
+
+// MarshalJSON writes Money of money budgets as an exact decimal string in
+// the decimals of Currency.
+func (budget Budget) MarshalJSON() ([]byte, error) {
+	type plain Budget
+	if budget.Kind != BudgetMoney {
+		return json.Marshal(plain(budget))
+	}
+	return json.Marshal(struct {
+		plain
+		Money Amount `json:"money"`
+	}{
+		plain: plain(budget),
+		Money: Amount{Money: budget.Money, Currency: budget.Currency},
+	})
+}
+
+func NewBudgetFromParams(params CreateBudgetParams) (Budget, error) {
+	budget := Budget{
+		Name:       params.Name,
+		Kind:       params.Kind,
+		Period:     params.Period,
+		Duration:   params.Duration,
+		CategoryID: params.CategoryID,
+		Keyword:    params.Keyword,
+	}
+	if params.Kind == BudgetMoney {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return Budget{}, err
+		}
+		budget.Money = money
+		budget.Currency = params.Currency
+	}
+	return budget, nil
+}
@@ -0,0 +1,94 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	minCategoryNameLen = 1
+	maxCategoryNameLen = 32
+	maxCategoryIconLen = 32
+)
+
+var (
+	colorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+type CreateCategoryParams struct {
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+}
+
+func (params CreateCategoryParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) < minCategoryNameLen || len(params.Name) > maxCategoryNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from %d to %d characters", minCategoryNameLen, maxCategoryNameLen)
+	}
+	if len(params.Color) != 0 && !colorRegex.MatchString(params.Color) {
+		errors["color"] = "color should be like #a1b2c3"
+	}
+	if len(params.Icon) > maxCategoryIconLen {
+		errors["icon"] = fmt.Sprintf("icon lenght should be less or equal then %d characters", maxCategoryIconLen)
+	}
+	return errors
+}
+
+// UpdateCategoryParams leave the parent as it is when ParentID is not
+// given, an empty ParentID moves the category to the top level.
+type UpdateCategoryParams struct {
+	Name     string  `bson:"name,omitempty" json:"name"`
+	ParentID *string `bson:"parentId,omitempty" json:"parentId"`
+	Color    string  `bson:"color,omitempty" json:"color"`
+	Icon     string  `bson:"icon,omitempty" json:"icon"`
+}
+
+// Parent returns the parent the category is moved to, it is empty when the
+// category is moved to the top level or its parent is left as it is.
+func (params UpdateCategoryParams) Parent() string {
+	if params.ParentID == nil {
+		return ""
+	}
+	return *params.ParentID
+}
+
+func (params UpdateCategoryParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) > maxCategoryNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be less or equal then %d characters", maxCategoryNameLen)
+	}
+	if len(params.Color) != 0 && !colorRegex.MatchString(params.Color) {
+		errors["color"] = "color should be like #a1b2c3"
+	}
+	if len(params.Icon) > maxCategoryIconLen {
+		errors["icon"] = fmt.Sprintf("icon lenght should be less or equal then %d characters", maxCategoryIconLen)
+	}
+	return errors
+}
+
+func (params UpdateCategoryParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+type Category struct {
+	ID       string `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID  string `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Name     string `bson:"name" json:"name"`
+	ParentID string `bson:"parentId" json:"parentId,omitempty"`
+	Color    string `bson:"color" json:"color,omitempty"`
+	Icon     string `bson:"icon" json:"icon,omitempty"`
+}
+
+func NewCategoryFromParams(params CreateCategoryParams) Category {
+	return Category{
+		Name:     params.Name,
+		ParentID: params.ParentID,
+		Color:    params.Color,
+		Icon:     params.Icon,
+	}
+}
@@ -0,0 +1,462 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	SplitEqual   = "equal"
+	SplitShares  = "shares"
+	SplitExact   = "exact"
+	SplitPercent = "percent"
+
+	minGroupNameLen = 1
+	maxGroupNameLen = 64
+)
+
+// CreateGroupParams have the Currency group expenses are in, handlers set it
+// to the base currency of the user when it is not given.
+type CreateGroupParams struct {
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+}
+
+func (params CreateGroupParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) < minGroupNameLen || len(params.Name) > maxGroupNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from %d to %d characters", minGroupNameLen, maxGroupNameLen)
+	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	return errors
+}
+
+type UpdateGroupParams struct {
+	Name string `bson:"name,omitempty" json:"name"`
+}
+
+func (params UpdateGroupParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) > maxGroupNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be less or equal then %d characters", maxGroupNameLen)
+	}
+	return errors
+}
+
+func (params UpdateGroupParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+// GroupCreatorParams hand a group over to another member, who can delete it.
+type GroupCreatorParams struct {
+	CreatedBy string `bson:"createdBy" json:"-"`
+}
+
+func (params GroupCreatorParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+// InviteParams invite the user registered with Email to a group.
+type InviteParams struct {
+	Email string `json:"email"`
+}
+
+// Group is shared by its Members, users join it when they are invited.
+type Group struct {
+	ID        string   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name      string   `bson:"name" json:"name"`
+	Currency  string   `bson:"currency" json:"currency"`
+	CreatedBy string   `bson:"createdBy" json:"createdBy"`
+	Members   []string `bson:"members" json:"members"`
+	Invited   []string `bson:"invited" json:"invited,omitempty"`
+}
+
+func (group Group) IsMember(userID string) bool {
+	return slices.Contains(group.Members, userID)
+}
+
+func (group Group) IsInvited(userID string) bool {
+	return slices.Contains(group.Invited, userID)
+}
+
+func NewGroupFromParams(params CreateGroupParams, createdBy string) Group {
+	return Group{
+		Name:      params.Name,
+		Currency:  params.Currency,
+		CreatedBy: createdBy,
+		Members:   []string{createdBy},
+	}
+}
+
+// ShareParams are the share of a user in a group expense. Value is ignored
+// by equal splits, it is the number of shares of the user in share splits,
+// the money the user owes in exact splits and the percent of the money in
+// percent splits.
+type ShareParams struct {
+	UserID string  `json:"userId"`
+	Value  Decimal `json:"value"`
+}
+
+// CreateGroupExpenseParams split Money paid by PayerID among Shares,
+// handlers set PayerID to the user, Shares to every member of the group when
+// they are not given and Currency to the one of the group.
+type CreateGroupExpenseParams struct {
+	PayerID  string        `json:"payerId"`
+	Money    Decimal       `json:"money"`
+	Currency string        `json:"-"`
+	Date     time.Time     `json:"date"`
+	Note     string        `json:"note"`
+	Split    string        `json:"split"`
+	Shares   []ShareParams `json:"shares"`
+}
+
+func (params CreateGroupExpenseParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if params.Date.IsZero() {
+		errors["date"] = "date should be not zero"
+	}
+	money, err := ParseMoney(params.Money, params.Currency)
+	if err != nil {
+		errors["money"] = err.Error()
+	} else if money <= 0 {
+		errors["money"] = "money should be more then 0"
+	} else if _, err := SplitMoney(money, params.Currency, params.Split, params.Shares); err != nil {
+		errors["shares"] = err.Error()
+	}
+	return errors
+}
+
+// SettleParams pay Money to the member To, settling up what the user owes.
+// Date is the time of the payment when it is not given.
+type SettleParams struct {
+	To    string    `json:"to"`
+	Money Decimal   `json:"money"`
+	Date  time.Time `json:"date"`
+}
+
+// ExpenseShare is the money a member owes for a group expense.
+type ExpenseShare struct {
+	UserID string `bson:"userId" json:"userId"`
+	Amount Money  `bson:"amount" json:"amount"`
+}
+
+// GroupExpense is Money paid by PayerID for the members of Shares, the
+// amounts of Shares sum to Money.
+type GroupExpense struct {
+	ID        string         `bson:"_id,omitempty" json:"id,omitempty"`
+	GroupID   string         `bson:"groupid" json:"groupid"`
+	CreatedBy string         `bson:"createdBy" json:"createdBy"`
+	PayerID   string         `bson:"payerId" json:"payerId"`
+	Money     Money          `bson:"money" json:"money"`
+	Currency  string         `bson:"currency" json:"currency"`
+	Date      time.Time      `bson:"date" json:"date"`
+	Note      string         `bson:"note" json:"note"`
+	Split     string         `bson:"split" json:"split"`
+	Shares    []ExpenseShare `bson:"shares" json:"shares"`
+	// Settlement marks payments settling up balances between members.
+	Settlement bool `bson:"settlement" json:"settlement"`
+}
+
+// MarshalJSON writes money of the expense and its shares as exact decimal
+// strings in the decimals of Currency.
+func (expense GroupExpense) MarshalJSON() ([]byte, error) {
+	type plain GroupExpense
+	type plainShare ExpenseShare
+	type share struct {
+		plainShare
+		Amount Amount `json:"amount"`
+	}
+	shares := make([]share, 0, len(expense.Shares))
+	for _, s := range expense.Shares {
+		shares = append(shares, share{
+			plainShare: plainShare(s),
+			Amount:     Amount{Money: s.Amount, Currency: expense.Currency},
+		})
+	}
+	return json.Marshal(struct {
+		plain
+		Money  Amount  `json:"money"`
+		Shares []share `json:"shares"`
+	}{
+		plain:  plain(expense),
+		Money:  Amount{Money: expense.Money, Currency: expense.Currency},
+		Shares: shares,
+	})
+}
+
+func NewGroupExpenseFromParams(params CreateGroupExpenseParams) (GroupExpense, error) {
+	money, err := ParseMoney(params.Money, params.Currency)
+	if err != nil {
+		return GroupExpense{}, err
+	}
+	shares, err := SplitMoney(money, params.Currency, params.Split, params.Shares)
+	if err != nil {
+		return GroupExpense{}, err
+	}
+	return GroupExpense{
+		PayerID:  params.PayerID,
+		Money:    money,
+		Currency: params.Currency,
+		Date:     params.Date,
+		Note:     params.Note,
+		Split:    params.Split,
+		Shares:   shares,
+	}, nil
+}
+
+// SplitMoney splits money among shares by split. Shares of exact splits
+// should sum to money and those of percent splits to 100. Other splits are
+// rounded down to minor units, the units left over go one by one to the
+// shares rounded down the most, earlier shares first.
+func SplitMoney(money Money, currency string, split string, shares []ShareParams) ([]ExpenseShare, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("there should be at least one share")
+	}
+	seen := map[string]bool{}
+	for _, share := range shares {
+		if len(share.UserID) == 0 {
+			return nil, fmt.Errorf("shares should have a userId")
+		}
+		if seen[share.UserID] {
+			return nil, fmt.Errorf("user %s has more than one share", share.UserID)
+		}
+		seen[share.UserID] = true
+	}
+	weights := make([]*big.Rat, 0, len(shares))
+	switch split {
+	case SplitExact:
+		result := make([]ExpenseShare, 0, len(shares))
+		var sum Money
+		for _, share := range shares {
+			amount, err := ParseMoney(share.Value, currency)
+			if err != nil {
+				return nil, err
+			}
+			if amount < 0 {
+				return nil, fmt.Errorf("share of user %s should be positive number", share.UserID)
+			}
+			sum += amount
+			result = append(result, ExpenseShare{UserID: share.UserID, Amount: amount})
+		}
+		if sum != money {
+			return nil, fmt.Errorf("shares sum to %s instead of %s", sum.Format(currency), money.Format(currency))
+		}
+		return result, nil
+	case SplitEqual:
+		for range shares {
+			weights = append(weights, big.NewRat(1, 1))
+		}
+	case SplitShares, SplitPercent:
+		sum := new(big.Rat)
+		for _, share := range shares {
+			weight, ok := new(big.Rat).SetString(string(share.Value))
+			if !decimalRegex.MatchString(string(share.Value)) || !ok {
+				return nil, fmt.Errorf("share of user %s should be a decimal number like 12.34", share.UserID)
+			}
+			if weight.Sign() <= 0 {
+				return nil, fmt.Errorf("share of user %s should be more then 0", share.UserID)
+			}
+			sum.Add(sum, weight)
+			weights = append(weights, weight)
+		}
+		if split == SplitPercent && sum.Cmp(big.NewRat(100, 1)) != 0 {
+			return nil, fmt.Errorf("percents sum to %s instead of 100", sum.FloatString(2))
+		}
+	default:
+		return nil, fmt.Errorf("split should be one of %s, %s, %s, %s", SplitEqual, SplitShares, SplitExact, SplitPercent)
+	}
+
+	total := new(big.Rat)
+	for _, weight := range weights {
+		total.Add(total, weight)
+	}
+	result := make([]ExpenseShare, 0, len(shares))
+	remainders := make([]*big.Rat, 0, len(shares))
+	var allocated Money
+	for i, share := range shares {
+		exact := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(money)), weights[i])
+		exact.Quo(exact, total)
+		floor := new(big.Int).Quo(exact.Num(), exact.Denom())
+		result = append(result, ExpenseShare{UserID: share.UserID, Amount: Money(floor.Int64())})
+		remainders = append(remainders, exact.Sub(exact, new(big.Rat).SetInt(floor)))
+		allocated += Money(floor.Int64())
+	}
+	order := make([]int, len(result))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]].Cmp(remainders[order[j]]) > 0
+	})
+	for i := 0; allocated < money; i++ {
+		result[order[i]].Amount++
+		allocated++
+	}
+	return result, nil
+}
+
+// GroupBalance is the money a member paid for a group and owes to it,
+// Balance above 0 is owed to the member and below 0 by the member.
+type GroupBalance struct {
+	UserID  string
+	Paid    Money
+	Owed    Money
+	Balance Money
+}
+
+// GroupTransfer is a payment of Amount from the member From to To.
+type GroupTransfer struct {
+	From   string
+	To     string
+	Amount Money
+}
+
+// GroupBalances returns the balances of members and of users who left the
+// group with expenses, ordered by user id.
+func GroupBalances(members []string, expenses []GroupExpense) []GroupBalance {
+	balances := map[string]*GroupBalance{}
+	balance := func(userID string) *GroupBalance {
+		if _, ok := balances[userID]; !ok {
+			balances[userID] = &GroupBalance{UserID: userID}
+		}
+		return balances[userID]
+	}
+	for _, member := range members {
+		balance(member)
+	}
+	for _, expense := range expenses {
+		balance(expense.PayerID).Paid += expense.Money
+		for _, share := range expense.Shares {
+			balance(share.UserID).Owed += share.Amount
+		}
+	}
+	result := make([]GroupBalance, 0, len(balances))
+	for _, b := range balances {
+		b.Balance = b.Paid - b.Owed
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// maxExactSettleUp is the most unsettled members SettleUp finds the fewest
+// transfers for, the search takes time and memory exponential in them.
+const maxExactSettleUp = 16
+
+// SettleUp returns the fewest transfers bringing balances to zero. Members
+// whose balances sum to zero settle up among themselves with one transfer
+// less than they are, so the unsettled members are split into as many such
+// sets as possible. With more than maxExactSettleUp unsettled members they
+// settle up as one set, which takes at most one transfer more than members
+// per set found.
+func SettleUp(balances []GroupBalance) []GroupTransfer {
+	unsettled := []GroupBalance{}
+	for _, b := range balances {
+		if b.Balance != 0 {
+			unsettled = append(unsettled, b)
+		}
+	}
+	transfers := []GroupTransfer{}
+	for _, set := range zeroSumSets(unsettled) {
+		transfers = append(transfers, settleSet(set)...)
+	}
+	return transfers
+}
+
+// zeroSumSets splits balances summing to zero into the most sets of
+// balances summing to zero. most[mask] is the number of zero sum sets the
+// balances in mask split into when they are taken in the best order, a set
+// ends whenever the balances taken so far sum to zero.
+func zeroSumSets(balances []GroupBalance) [][]GroupBalance {
+	n := len(balances)
+	if n == 0 {
+		return nil
+	}
+	if n > maxExactSettleUp {
+		return [][]GroupBalance{balances}
+	}
+	sum := make([]Money, 1<<n)
+	most := make([]int, 1<<n)
+	for mask := 1; mask < 1<<n; mask++ {
+		low := bits.TrailingZeros(uint(mask))
+		sum[mask] = sum[mask&(mask-1)] + balances[low].Balance
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 {
+				most[mask] = max(most[mask], most[mask&^(1<<i)])
+			}
+		}
+		if sum[mask] == 0 {
+			most[mask]++
+		}
+	}
+	// taking balances out in the order that kept most sets, the balances
+	// taken out since the last zero sum form a set
+	sets := [][]GroupBalance{}
+	set := []GroupBalance{}
+	for mask := 1<<n - 1; mask != 0; {
+		want := most[mask]
+		if sum[mask] == 0 {
+			want--
+		}
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && most[mask&^(1<<i)] == want {
+				set = append(set, balances[i])
+				mask &^= 1 << i
+				break
+			}
+		}
+		if sum[mask] == 0 {
+			sets = append(sets, set)
+			set = []GroupBalance{}
+		}
+	}
+	return sets
+}
+
+// settleSet returns transfers bringing balances summing to zero to zero, the
+// member owing the most pays the member owed the most until every balance is
+// settled, so there is at most one transfer less than members.
+func settleSet(balances []GroupBalance) []GroupTransfer {
+	debtors, creditors := []GroupBalance{}, []GroupBalance{}
+	for _, b := range balances {
+		if b.Balance < 0 {
+			debtors = append(debtors, GroupBalance{UserID: b.UserID, Balance: -b.Balance})
+		} else if b.Balance > 0 {
+			creditors = append(creditors, b)
+		}
+	}
+	byBalance := func(list []GroupBalance) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Balance != list[j].Balance {
+				return list[i].Balance > list[j].Balance
+			}
+			return list[i].UserID < list[j].UserID
+		})
+	}
+	transfers := []GroupTransfer{}
+	for len(debtors) != 0 && len(creditors) != 0 {
+		byBalance(debtors)
+		byBalance(creditors)
+		amount := min(debtors[0].Balance, creditors[0].Balance)
+		transfers = append(transfers, GroupTransfer{From: debtors[0].UserID, To: creditors[0].UserID, Amount: amount})
+		debtors[0].Balance -= amount
+		creditors[0].Balance -= amount
+		if debtors[0].Balance == 0 {
+			debtors = debtors[1:]
+		}
+		if creditors[0].Balance == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return transfers
+}
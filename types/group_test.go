@@ -0,0 +1,48 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/SpectralJager/spender/types"
+)
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  map[string]types.Money
+		transfers int
+	}{
+		{"settled", map[string]types.Money{"a": 0, "b": 0}, 0},
+		{"one debtor", map[string]types.Money{"a": 10, "b": -4, "c": -6}, 2},
+		{"pairs", map[string]types.Money{"a": 5, "b": -3, "c": -2, "d": -4, "e": 4}, 3},
+		{"no subsets", map[string]types.Money{"a": 7, "b": -3, "c": -5, "d": 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balances := []types.GroupBalance{}
+			for userID, balance := range tt.balances {
+				balances = append(balances, types.GroupBalance{UserID: userID, Balance: balance})
+			}
+			transfers := types.SettleUp(balances)
+			if len(transfers) != tt.transfers {
+				t.Fatalf("settling up takes %d transfers %v, want %d", len(transfers), transfers, tt.transfers)
+			}
+			left := map[string]types.Money{}
+			for userID, balance := range tt.balances {
+				left[userID] = balance
+			}
+			for _, transfer := range transfers {
+				if transfer.Amount <= 0 {
+					t.Fatalf("transfer %v isn't positive", transfer)
+				}
+				left[transfer.From] += transfer.Amount
+				left[transfer.To] -= transfer.Amount
+			}
+			for userID, balance := range left {
+				if balance != 0 {
+					t.Fatalf("balance of %s is %d after settling up with %v", userID, balance, transfers)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CreateIncomeParams parses Money in the decimals of Currency, handlers set
+// Currency to the base currency of the user when it is not given.
+type CreateIncomeParams struct {
+	Money      Decimal   `json:"money"`
+	Currency   string    `json:"currency"`
+	Note       string    `json:"note"`
+	Date       time.Time `json:"date"`
+	CategoryID string    `json:"categoryId"`
+	AccountID  string    `json:"accountId"`
+	Tags       []string  `json:"tags"`
+}
+
+func (params CreateIncomeParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if params.Date.IsZero() {
+		errors["date"] = "date should be not zero"
+	}
+	if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+		errors["money"] = err.Error()
+	} else if money <= 0 {
+		errors["money"] = "money should be more then 0"
+	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	validateTags(params.Tags, errors)
+	return errors
+}
+
+// UpdateIncomeParams parses Money in the decimals of Currency, handlers set
+// Currency to the one of the updated income when it is not given.
+type UpdateIncomeParams struct {
+	Money      Decimal   `bson:"-" json:"money"`
+	Currency   string    `bson:"currency,omitempty" json:"currency"`
+	Date       time.Time `bson:"date,omitempty" json:"date"`
+	Note       string    `bson:"note,omitempty" json:"note"`
+	CategoryID string    `bson:"categoryId,omitempty" json:"categoryId"`
+	AccountID  string    `bson:"accountId,omitempty" json:"accountId"`
+	Tags       []string  `bson:"tags,omitempty" json:"tags"`
+}
+
+func (params UpdateIncomeParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Money) != 0 {
+		if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+			errors["money"] = err.Error()
+		} else if money <= 0 {
+			errors["money"] = "money should be more then 0"
+		}
+	}
+	if len(params.Currency) != 0 && !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	validateTags(params.Tags, errors)
+	return errors
+}
+
+func (params UpdateIncomeParams) ToBsonDoc() (*bson.D, error) {
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Money) != 0 {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		*doc = append(*doc, bson.E{Key: "money", Value: money})
+	}
+	return doc, nil
+}
+
+// Income is money received, like a salary or a refund, the opposite of a
+// moneyspend.
+type Income struct {
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Money      Money     `bson:"money" json:"money"`
+	Currency   string    `bson:"currency" json:"currency"`
+	Date       time.Time `bson:"date" json:"date"`
+	Note       string    `bson:"note" json:"note"`
+	CategoryID string    `bson:"categoryId" json:"categoryId,omitempty"`
+	AccountID  string    `bson:"accountId" json:"accountId,omitempty"`
+	Tags       []string  `bson:"tags" json:"tags,omitempty"`
+}
+
+// MarshalJSON writes Money as an exact decimal string in the decimals of
+// Currency.
+func (income Income) MarshalJSON() ([]byte, error) {
+	type plain Income
+	return json.Marshal(struct {
+		plain
+		Money Amount `json:"money"`
+	}{
+		plain: plain(income),
+		Money: Amount{Money: income.Money, Currency: income.Currency},
+	})
+}
+
+func NewIncomeFromParams(params CreateIncomeParams) (Income, error) {
+	money, err := ParseMoney(params.Money, params.Currency)
+	if err != nil {
+		return Income{}, err
+	}
+	return Income{
+		Money:      money,
+		Currency:   params.Currency,
+		Date:       params.Date,
+		Note:       params.Note,
+		CategoryID: params.CategoryID,
+		AccountID:  params.AccountID,
+		Tags:       params.Tags,
+	}, nil
+}
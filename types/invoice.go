@@ -0,0 +1,206 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	InvoiceLineTime    = "time"
+	InvoiceLineExpense = "expense"
+
+	maxNotePrefixLen = 64
+)
+
+// CreateInvoiceParams bill the uninvoiced timespends and reimbursable
+// moneyspends dated from the Start day up to and including the End day,
+// optionally only those with a note starting with NotePrefix or with Tag.
+// HourlyRate is parsed in the decimals of Currency, handlers set Currency to
+// the base currency of the user when it is not given.
+type CreateInvoiceParams struct {
+	Client     string  `json:"client"`
+	HourlyRate Decimal `json:"hourlyRate"`
+	Currency   string  `json:"currency"`
+	Start      string  `json:"start"`
+	End        string  `json:"end"`
+	NotePrefix string  `json:"notePrefix"`
+	Tag        string  `json:"tag"`
+}
+
+func (params CreateInvoiceParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Client) == 0 || len(params.Client) > maxClientNameLen {
+		errors["client"] = fmt.Sprintf("client lenght should be from 1 to %d characters", maxClientNameLen)
+	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	if rate, err := ParseMoney(params.HourlyRate, params.Currency); err != nil {
+		errors["hourlyRate"] = err.Error()
+	} else if rate < 0 {
+		errors["hourlyRate"] = "hourlyRate should be positive number"
+	}
+	start, startErr := time.Parse(dayLayout, params.Start)
+	if startErr != nil {
+		errors["start"] = fmt.Sprintf("start should be a date like %s", dayLayout)
+	}
+	end, endErr := time.Parse(dayLayout, params.End)
+	if endErr != nil {
+		errors["end"] = fmt.Sprintf("end should be a date like %s", dayLayout)
+	}
+	if startErr == nil && endErr == nil && end.Before(start) {
+		errors["end"] = "end should not be before start"
+	}
+	if len(params.NotePrefix) > maxNotePrefixLen {
+		errors["notePrefix"] = fmt.Sprintf("notePrefix lenght should be less or equal then %d characters", maxNotePrefixLen)
+	}
+	if len(params.Tag) != 0 && (len(params.Tag) > maxTagLen || !tagRegex.MatchString(params.Tag)) {
+		errors["tag"] = fmt.Sprintf("tag %q should be up to %d letters, digits, '_', '.' or '-'", params.Tag, maxTagLen)
+	}
+	return errors
+}
+
+// InvoiceLine bills a timespend at the hourly rate of its invoice, or a
+// reimbursable moneyspend at its money. Lines are numbered from 1.
+type InvoiceLine struct {
+	Number      int           `bson:"number" json:"number"`
+	Kind        string        `bson:"kind" json:"kind"`
+	SourceID    string        `bson:"sourceId" json:"sourceId"`
+	Date        time.Time     `bson:"date" json:"date"`
+	Description string        `bson:"description" json:"description"`
+	Duration    time.Duration `bson:"duration" json:"duration,omitempty"`
+	Amount      Money         `bson:"amount" json:"amount"`
+}
+
+// Invoice bills a client for the entries dated from the Start day up to and
+// including the End day. Document is the invoice rendered as html.
+type Invoice struct {
+	ID           string        `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID      string        `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Number       int           `bson:"number" json:"number"`
+	Client       string        `bson:"client" json:"client"`
+	Currency     string        `bson:"currency" json:"currency"`
+	HourlyRate   Money         `bson:"hourlyRate" json:"hourlyRate"`
+	Start        time.Time     `bson:"start" json:"start"`
+	End          time.Time     `bson:"end" json:"end"`
+	IssuedAt     time.Time     `bson:"issuedAt" json:"issuedAt"`
+	Lines        []InvoiceLine `bson:"lines" json:"lines"`
+	Duration     time.Duration `bson:"duration" json:"duration"`
+	TimeTotal    Money         `bson:"timeTotal" json:"timeTotal"`
+	ExpenseTotal Money         `bson:"expenseTotal" json:"expenseTotal"`
+	Total        Money         `bson:"total" json:"total"`
+	Document     string        `bson:"document" json:"-"`
+}
+
+// MarshalJSON writes money of the invoice and its lines as exact decimal
+// strings in the decimals of Currency.
+func (invoice Invoice) MarshalJSON() ([]byte, error) {
+	type plain Invoice
+	type plainLine InvoiceLine
+	type line struct {
+		plainLine
+		Amount Amount `json:"amount"`
+	}
+	lines := make([]line, 0, len(invoice.Lines))
+	for _, l := range invoice.Lines {
+		lines = append(lines, line{
+			plainLine: plainLine(l),
+			Amount:    Amount{Money: l.Amount, Currency: invoice.Currency},
+		})
+	}
+	return json.Marshal(struct {
+		plain
+		HourlyRate   Amount `json:"hourlyRate"`
+		Lines        []line `json:"lines"`
+		TimeTotal    Amount `json:"timeTotal"`
+		ExpenseTotal Amount `json:"expenseTotal"`
+		Total        Amount `json:"total"`
+	}{
+		plain:        plain(invoice),
+		HourlyRate:   Amount{Money: invoice.HourlyRate, Currency: invoice.Currency},
+		Lines:        lines,
+		TimeTotal:    Amount{Money: invoice.TimeTotal, Currency: invoice.Currency},
+		ExpenseTotal: Amount{Money: invoice.ExpenseTotal, Currency: invoice.Currency},
+		Total:        Amount{Money: invoice.Total, Currency: invoice.Currency},
+	})
+}
+
+// HourlyAmount prices duration at rate per hour, rounded to minor units.
+func HourlyAmount(rate Money, duration time.Duration) Money {
+	return Money(math.Round(float64(rate) * float64(duration) / float64(time.Hour)))
+}
+
+// NewInvoiceFromParams returns an invoice without lines, lines are added
+// with AddTime and AddExpense.
+func NewInvoiceFromParams(params CreateInvoiceParams, issuedAt time.Time) (Invoice, error) {
+	rate, err := ParseMoney(params.HourlyRate, params.Currency)
+	if err != nil {
+		return Invoice{}, err
+	}
+	start, err := time.Parse(dayLayout, params.Start)
+	if err != nil {
+		return Invoice{}, err
+	}
+	end, err := time.Parse(dayLayout, params.End)
+	if err != nil {
+		return Invoice{}, err
+	}
+	return Invoice{
+		Client:     params.Client,
+		Currency:   params.Currency,
+		HourlyRate: rate,
+		Start:      start,
+		End:        end,
+		IssuedAt:   issuedAt,
+		Lines:      []InvoiceLine{},
+	}, nil
+}
+
+// AddTime adds a line billing timespend at the hourly rate.
+func (invoice *Invoice) AddTime(timespend Timespend) {
+	amount := HourlyAmount(invoice.HourlyRate, timespend.Duration)
+	invoice.addLine(InvoiceLine{
+		Kind:        InvoiceLineTime,
+		SourceID:    timespend.ID,
+		Date:        timespend.Date,
+		Description: timespend.Note,
+		Duration:    timespend.Duration,
+		Amount:      amount,
+	})
+	invoice.Duration += timespend.Duration
+	invoice.TimeTotal += amount
+}
+
+// AddExpense adds a line billing moneyspend, which should be in the
+// currency of the invoice.
+func (invoice *Invoice) AddExpense(moneyspend Moneyspend) {
+	invoice.addLine(InvoiceLine{
+		Kind:        InvoiceLineExpense,
+		SourceID:    moneyspend.ID,
+		Date:        moneyspend.Date,
+		Description: moneyspend.Note,
+		Amount:      moneyspend.Money,
+	})
+	invoice.ExpenseTotal += moneyspend.Money
+}
+
+func (invoice *Invoice) addLine(line InvoiceLine) {
+	line.Number = len(invoice.Lines) + 1
+	invoice.Lines = append(invoice.Lines, line)
+	invoice.Total += line.Amount
+}
+
+// InvoicedParams mark an entry as billed on InvoiceID, an empty InvoiceID
+// makes it billable again.
+type InvoicedParams struct {
+	InvoiceID string `bson:"invoiceId" json:"invoiceId"`
+}
+
+func (params InvoicedParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
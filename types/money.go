@@ -0,0 +1,107 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMinorUnits = 2
+	// maxMoneyDigits keeps parsed amounts within int64.
+	maxMoneyDigits = 18
+)
+
+var (
+	decimalRegex = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d+))?$`)
+)
+
+// MinorUnits returns the number of decimals of the currency, 2 for unknown
+// currencies.
+func MinorUnits(currency string) int {
+	if digits, ok := currencies[currency]; ok {
+		return digits
+	}
+	return defaultMinorUnits
+}
+
+// Money is an amount in minor units of its currency, like cents of USD, so
+// sums of money are exact.
+type Money int64
+
+// Decimal is a decimal number as written in json, either as a string or as
+// a number, so no precision is lost before it is parsed into Money.
+type Decimal string
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*d = Decimal(strings.TrimSpace(s))
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("money should be a decimal number or string")
+	}
+	*d = Decimal(n)
+	return nil
+}
+
+// ParseMoney parses d into minor units of the currency, it fails when d
+// has more decimals than the currency.
+func ParseMoney(d Decimal, currency string) (Money, error) {
+	match := decimalRegex.FindStringSubmatch(string(d))
+	if match == nil {
+		return 0, fmt.Errorf("%q should be a decimal number like 12.34", string(d))
+	}
+	sign, whole, fraction := match[1], strings.TrimLeft(match[2], "0"), strings.TrimRight(match[3], "0")
+	digits := MinorUnits(currency)
+	if len(fraction) > digits {
+		return 0, fmt.Errorf("%q has more than %d decimals of %s", string(d), digits, currency)
+	}
+	units := whole + fraction + strings.Repeat("0", digits-len(fraction))
+	if len(units) > maxMoneyDigits {
+		return 0, fmt.Errorf("%q is too large", string(d))
+	}
+	if len(units) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(sign+units, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return Money(n), nil
+}
+
+// Format returns m as a decimal string with the decimals of the currency.
+func (m Money) Format(currency string) string {
+	digits := MinorUnits(currency)
+	sign := ""
+	units := strconv.FormatInt(int64(m), 10)
+	if m < 0 {
+		sign, units = "-", units[1:]
+	}
+	if digits == 0 {
+		return sign + units
+	}
+	if len(units) <= digits {
+		units = strings.Repeat("0", digits-len(units)+1) + units
+	}
+	return sign + units[:len(units)-digits] + "." + units[len(units)-digits:]
+}
+
+// Amount is money together with its currency, it is written to json as an
+// exact decimal string.
+type Amount struct {
+	Money    Money
+	Currency string
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Money.Format(a.Currency))
+}
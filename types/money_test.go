@@ -0,0 +1,111 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SpectralJager/spender/types"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		decimal  types.Decimal
+		currency string
+		want     types.Money
+		fails    bool
+	}{
+		{"12.34", "USD", 1234, false},
+		{"12.3", "USD", 1230, false},
+		{"12", "USD", 1200, false},
+		{"0.05", "EUR", 5, false},
+		{"012.50", "EUR", 1250, false},
+		{"0", "USD", 0, false},
+		{"-0.00", "USD", 0, false},
+		{"-12.34", "USD", -1234, false},
+		{"12.345", "USD", 0, true},
+		{"12.340", "USD", 1234, false},
+		{"1500", "JPY", 1500, false},
+		{"1500.0", "JPY", 1500, false},
+		{"1500.5", "JPY", 0, true},
+		{"-300", "JPY", -300, false},
+		{"1.234", "KWD", 1234, false},
+		{"1.2", "BHD", 1200, false},
+		{"1.2345", "KWD", 0, true},
+		{"1.5", "XXY", 150, false},
+		{"9999999999999999.99", "USD", 999999999999999999, false},
+		{"-9999999999999999.99", "USD", -999999999999999999, false},
+		{"10000000000000000.00", "USD", 0, true},
+		{"999999999999999999", "JPY", 999999999999999999, false},
+		{"99999999999999999999", "JPY", 0, true},
+		{"", "USD", 0, true},
+		{"12,34", "USD", 0, true},
+		{"1e3", "USD", 0, true},
+		{".5", "USD", 0, true},
+		{"+5", "USD", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := types.ParseMoney(tt.decimal, tt.currency)
+		if tt.fails {
+			if err == nil {
+				t.Fatalf("parsing %q in %s returned %d, want an error", tt.decimal, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parse %q in %s: %v", tt.decimal, tt.currency, err)
+		}
+		if got != tt.want {
+			t.Fatalf("parsing %q in %s returned %d, want %d", tt.decimal, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		money    types.Money
+		currency string
+		want     string
+	}{
+		{1234, "USD", "12.34"},
+		{5, "USD", "0.05"},
+		{0, "USD", "0.00"},
+		{-5, "USD", "-0.05"},
+		{-1234, "EUR", "-12.34"},
+		{1500, "JPY", "1500"},
+		{-300, "JPY", "-300"},
+		{0, "JPY", "0"},
+		{1234, "KWD", "1.234"},
+		{7, "BHD", "0.007"},
+		{999999999999999999, "USD", "9999999999999999.99"},
+	}
+	for _, tt := range tests {
+		got := tt.money.Format(tt.currency)
+		if got != tt.want {
+			t.Fatalf("%d in %s is formatted as %s, want %s", tt.money, tt.currency, got, tt.want)
+		}
+		if back, err := types.ParseMoney(types.Decimal(got), tt.currency); err != nil || back != tt.money {
+			t.Fatalf("%s in %s parses back to %d, %v", got, tt.currency, back, err)
+		}
+	}
+}
+
+func TestDecimalFromJSON(t *testing.T) {
+	for data, want := range map[string]types.Decimal{
+		`"12.50"`:   "12.50",
+		`" 12.50 "`: "12.50",
+		`12.50`:     "12.50",
+		`-3`:        "-3",
+	} {
+		var got types.Decimal
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+		if got != want {
+			t.Fatalf("%s is decoded as %q, want %q", data, got, want)
+		}
+	}
+	var d types.Decimal
+	if err := json.Unmarshal([]byte(`true`), &d); err == nil {
+		t.Fatalf("decoding true succeeded")
+	}
+}
@@ -0,0 +1,131 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	minProjectNameLen = 1
+	maxProjectNameLen = 64
+	maxClientNameLen  = 64
+)
+
+// CreateProjectParams parses HourlyRate in the decimals of Currency,
+// handlers set Currency to the base currency of the user when it is not
+// given.
+type CreateProjectParams struct {
+	Name       string  `json:"name"`
+	Client     string  `json:"client"`
+	HourlyRate Decimal `json:"hourlyRate"`
+	Currency   string  `json:"currency"`
+}
+
+func (params CreateProjectParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) < minProjectNameLen || len(params.Name) > maxProjectNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be from %d to %d characters", minProjectNameLen, maxProjectNameLen)
+	}
+	if len(params.Client) > maxClientNameLen {
+		errors["client"] = fmt.Sprintf("client lenght should be less or equal then %d characters", maxClientNameLen)
+	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	if len(params.HourlyRate) != 0 {
+		if rate, err := ParseMoney(params.HourlyRate, params.Currency); err != nil {
+			errors["hourlyRate"] = err.Error()
+		} else if rate < 0 {
+			errors["hourlyRate"] = "hourlyRate should be positive number"
+		}
+	}
+	return errors
+}
+
+// UpdateProjectParams parses HourlyRate in the decimals of Currency, handlers
+// set Currency to the one of the updated project when it is not given.
+type UpdateProjectParams struct {
+	Name       string  `bson:"name,omitempty" json:"name"`
+	Client     string  `bson:"client,omitempty" json:"client"`
+	HourlyRate Decimal `bson:"-" json:"hourlyRate"`
+	Currency   string  `bson:"currency,omitempty" json:"currency"`
+}
+
+func (params UpdateProjectParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(params.Name) > maxProjectNameLen {
+		errors["name"] = fmt.Sprintf("name lenght should be less or equal then %d characters", maxProjectNameLen)
+	}
+	if len(params.Client) > maxClientNameLen {
+		errors["client"] = fmt.Sprintf("client lenght should be less or equal then %d characters", maxClientNameLen)
+	}
+	if len(params.Currency) != 0 && !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	if len(params.HourlyRate) != 0 {
+		if rate, err := ParseMoney(params.HourlyRate, params.Currency); err != nil {
+			errors["hourlyRate"] = err.Error()
+		} else if rate < 0 {
+			errors["hourlyRate"] = "hourlyRate should be positive number"
+		}
+	}
+	return errors
+}
+
+func (params UpdateProjectParams) ToBsonDoc() (*bson.D, error) {
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.HourlyRate) != 0 {
+		rate, err := ParseMoney(params.HourlyRate, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		*doc = append(*doc, bson.E{Key: "hourlyRate", Value: rate})
+	}
+	return doc, nil
+}
+
+// Project is work done for a client, billable timespends of a project are
+// priced at its HourlyRate.
+type Project struct {
+	ID         string `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Name       string `bson:"name" json:"name"`
+	Client     string `bson:"client" json:"client,omitempty"`
+	HourlyRate Money  `bson:"hourlyRate" json:"hourlyRate"`
+	Currency   string `bson:"currency" json:"currency"`
+}
+
+// MarshalJSON writes HourlyRate as an exact decimal string in the decimals
+// of Currency.
+func (project Project) MarshalJSON() ([]byte, error) {
+	type plain Project
+	return json.Marshal(struct {
+		plain
+		HourlyRate Amount `json:"hourlyRate"`
+	}{
+		plain:      plain(project),
+		HourlyRate: Amount{Money: project.HourlyRate, Currency: project.Currency},
+	})
+}
+
+func NewProjectFromParams(params CreateProjectParams) (Project, error) {
+	project := Project{
+		Name:     params.Name,
+		Client:   params.Client,
+		Currency: params.Currency,
+	}
+	if len(params.HourlyRate) != 0 {
+		rate, err := ParseMoney(params.HourlyRate, params.Currency)
+		if err != nil {
+			return Project{}, err
+		}
+		project.HourlyRate = rate
+	}
+	return project, nil
+}
@@ -0,0 +1,365 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	RecurringMoney = "money"
+	RecurringTime  = "time"
+
+	RecurringDaily   = "daily"
+	RecurringWeekly  = "weekly"
+	RecurringMonthly = "monthly"
+
+	maxRecurringInterval = 366
+)
+
+func validateRecurringTemplate(kind string, money Decimal, currency string, duration time.Duration, errors map[string]string) {
+	switch kind {
+	case RecurringMoney:
+		if money, err := ParseMoney(money, currency); err != nil {
+			errors["money"] = err.Error()
+		} else if money <= 0 {
+			errors["money"] = "money should be more then 0"
+		}
+		if !IsCurrency(currency) {
+			errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", currency)
+		}
+		if duration != 0 {
+			errors["duration"] = "duration is only for time rules"
+		}
+	case RecurringTime:
+		if duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
+		if len(money) != 0 {
+			errors["money"] = "money is only for money rules"
+		}
+	default:
+		errors["kind"] = fmt.Sprintf("kind should be %s or %s", RecurringMoney, RecurringTime)
+	}
+}
+
+// CreateRecurringParams repeat a moneyspend or a timespend from Start every
+// Interval days, weeks or months, on MonthDay of monthly rules, the day of
+// Start by default. Rules end after Until, a day, or after Count
+// occurrences, whichever comes first, and repeat forever without both.
+// Handlers set Currency of money rules like for moneyspends.
+type CreateRecurringParams struct {
+	Kind       string        `json:"kind"`
+	Freq       string        `json:"freq"`
+	Interval   int           `json:"interval"`
+	MonthDay   int           `json:"monthDay"`
+	Start      time.Time     `json:"start"`
+	Until      time.Time     `json:"until"`
+	Count      int           `json:"count"`
+	Money      Decimal       `json:"money"`
+	Currency   string        `json:"currency"`
+	AccountID  string        `json:"accountId"`
+	Duration   time.Duration `json:"duration"`
+	Note       string        `json:"note"`
+	CategoryID string        `json:"categoryId"`
+	Tags       []string      `json:"tags"`
+}
+
+func (params CreateRecurringParams) Validate() map[string]string {
+	errors := map[string]string{}
+	switch params.Freq {
+	case RecurringDaily, RecurringWeekly:
+		if params.MonthDay != 0 {
+			errors["monthDay"] = "monthDay is only for monthly rules"
+		}
+	case RecurringMonthly:
+		if params.MonthDay < 0 || params.MonthDay > 31 {
+			errors["monthDay"] = "monthDay should be from 1 to 31"
+		}
+	default:
+		errors["freq"] = fmt.Sprintf("freq should be %s, %s or %s", RecurringDaily, RecurringWeekly, RecurringMonthly)
+	}
+	if params.Interval < 0 || params.Interval > maxRecurringInterval {
+		errors["interval"] = fmt.Sprintf("interval should be from 1 to %d", maxRecurringInterval)
+	}
+	if params.Start.IsZero() {
+		errors["start"] = "start should be not zero"
+	}
+	if !params.Until.IsZero() && recurringDay(params.Until).Before(recurringDay(params.Start)) {
+		errors["until"] = "until should be not before start"
+	}
+	if params.Count < 0 {
+		errors["count"] = "count should be positive number"
+	}
+	validateRecurringTemplate(params.Kind, params.Money, params.Currency, params.Duration, errors)
+	validateTags(params.Tags, errors)
+	return errors
+}
+
+// UpdateRecurringParams change what a rule creates and when it ends, not
+// its schedule. Handlers set Kind to the one of the updated rule, Currency
+// to its currency when it is not given and Done to whether the rule ends
+// before its next occurrence with the new Until and Count.
+type UpdateRecurringParams struct {
+	Until      time.Time     `bson:"until,omitempty" json:"until"`
+	Count      int           `bson:"count,omitempty" json:"count"`
+	Money      Decimal       `bson:"-" json:"money"`
+	Currency   string        `bson:"currency,omitempty" json:"currency"`
+	AccountID  string        `bson:"accountId,omitempty" json:"accountId"`
+	Duration   time.Duration `bson:"duration,omitempty" json:"duration"`
+	Note       string        `bson:"note,omitempty" json:"note"`
+	CategoryID string        `bson:"categoryId,omitempty" json:"categoryId"`
+	Tags       []string      `bson:"tags,omitempty" json:"tags"`
+	Kind       string        `bson:"-" json:"-"`
+	Done       bool          `bson:"done" json:"-"`
+}
+
+func (params UpdateRecurringParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if params.Count < 0 {
+		errors["count"] = "count should be positive number"
+	}
+	if params.Kind == RecurringMoney {
+		if len(params.Money) != 0 {
+			if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+				errors["money"] = err.Error()
+			} else if money <= 0 {
+				errors["money"] = "money should be more then 0"
+			}
+		}
+		if len(params.Currency) != 0 && !IsCurrency(params.Currency) {
+			errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+		}
+		if params.Duration != 0 {
+			errors["duration"] = "duration is only for time rules"
+		}
+	} else {
+		if params.Duration != 0 && params.Duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
+		if len(params.Money) != 0 || len(params.Currency) != 0 || len(params.AccountID) != 0 {
+			errors["money"] = "money is only for money rules"
+		}
+	}
+	validateTags(params.Tags, errors)
+	return errors
+}
+
+func (params UpdateRecurringParams) ToBsonDoc() (*bson.D, error) {
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Money) != 0 {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		*doc = append(*doc, bson.E{Key: "money", Value: money})
+	}
+	return doc, nil
+}
+
+// SkipRecurringParams replace the skipped days of a rule.
+type SkipRecurringParams struct {
+	Skipped []string `bson:"skipped"`
+}
+
+func (params SkipRecurringParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+// AdvanceRecurringParams move a rule past its materialized occurrences.
+type AdvanceRecurringParams struct {
+	Next      time.Time `bson:"next"`
+	NextIndex int       `bson:"nextIndex"`
+	Done      bool      `bson:"done"`
+}
+
+func (params AdvanceRecurringParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+// Recurring is a rule creating a moneyspend or a timespend on each of its
+// occurrences. Occurrences are numbered from 0 at Start, in UTC, and the
+// ones before NextIndex are already materialized. Next is the occurrence
+// NextIndex, the first one to materialize unless the rule is Done.
+type Recurring struct {
+	ID         string        `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string        `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Kind       string        `bson:"kind" json:"kind"`
+	Freq       string        `bson:"freq" json:"freq"`
+	Interval   int           `bson:"interval" json:"interval"`
+	MonthDay   int           `bson:"monthDay" json:"monthDay,omitempty"`
+	Start      time.Time     `bson:"start" json:"start"`
+	Until      time.Time     `bson:"until" json:"until"`
+	Count      int           `bson:"count" json:"count,omitempty"`
+	Money      Money         `bson:"money" json:"money,omitempty"`
+	Currency   string        `bson:"currency" json:"currency,omitempty"`
+	AccountID  string        `bson:"accountId" json:"accountId,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration,omitempty"`
+	Note       string        `bson:"note" json:"note"`
+	CategoryID string        `bson:"categoryId" json:"categoryId,omitempty"`
+	Tags       []string      `bson:"tags" json:"tags,omitempty"`
+	// Skipped are days like 2006-01-02 of occurrences not to materialize.
+	Skipped   []string  `bson:"skipped" json:"skipped,omitempty"`
+	Next      time.Time `bson:"next" json:"next"`
+	NextIndex int       `bson:"nextIndex" json:"-"`
+	Done      bool      `bson:"done" json:"done"`
+}
+
+// MarshalJSON writes Money of money rules as an exact decimal string in the
+// decimals of Currency.
+func (rule Recurring) MarshalJSON() ([]byte, error) {
+	type plain Recurring
+	if rule.Kind != RecurringMoney {
+		return json.Marshal(plain(rule))
+	}
+	return json.Marshal(struct {
+		plain
+		Money Amount `json:"money"`
+	}{
+		plain: plain(rule),
+		Money: Amount{Money: rule.Money, Currency: rule.Currency},
+	})
+}
+
+// Occurrence returns the occurrence n of the rule. Monthly occurrences fall
+// on the last day of months shorter than MonthDay.
+func (rule Recurring) Occurrence(n int) time.Time {
+	switch rule.Freq {
+	case RecurringWeekly:
+		return rule.Start.AddDate(0, 0, 7*rule.Interval*n)
+	case RecurringMonthly:
+		return monthDay(rule.Start, rule.Interval*n, rule.MonthDay)
+	}
+	return rule.Start.AddDate(0, 0, rule.Interval*n)
+}
+
+// Ended reports whether the rule ends before its occurrence n.
+func (rule Recurring) Ended(n int) bool {
+	if rule.Count > 0 && n >= rule.Count {
+		return true
+	}
+	return !rule.Until.IsZero() && recurringDay(rule.Occurrence(n)).After(recurringDay(rule.Until))
+}
+
+// IsSkipped reports whether the occurrence at date is skipped.
+func (rule Recurring) IsSkipped(date time.Time) bool {
+	return slices.Contains(rule.Skipped, date.UTC().Format(dayLayout))
+}
+
+// Upcoming returns at most limit occurrences not materialized yet.
+func (rule Recurring) Upcoming(limit int) []time.Time {
+	occurrences := []time.Time{}
+	for n := rule.NextIndex; len(occurrences) < limit && !rule.Ended(n); n++ {
+		occurrences = append(occurrences, rule.Occurrence(n))
+	}
+	return occurrences
+}
+
+// UpcomingOn returns the occurrence not materialized yet on the day, like
+// 2006-01-02, and whether there is one.
+func (rule Recurring) UpcomingOn(day string) (time.Time, bool) {
+	date, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for n := rule.NextIndex; !rule.Ended(n); n++ {
+		occurrence := rule.Occurrence(n)
+		if recurringDay(occurrence).After(date) {
+			break
+		}
+		if recurringDay(occurrence).Equal(date) {
+			return occurrence, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Moneyspend returns the moneyspend of a money rule occurring at date.
+func (rule Recurring) Moneyspend(date time.Time) Moneyspend {
+	return Moneyspend{
+		OwnerID:     rule.OwnerID,
+		Money:       rule.Money,
+		Currency:    rule.Currency,
+		Date:        date,
+		Note:        rule.Note,
+		CategoryID:  rule.CategoryID,
+		AccountID:   rule.AccountID,
+		Tags:        rule.Tags,
+		RecurringID: rule.ID,
+	}
+}
+
+// Timespend returns the timespend of a time rule occurring at date.
+func (rule Recurring) Timespend(date time.Time) Timespend {
+	return Timespend{
+		OwnerID:     rule.OwnerID,
+		Duration:    rule.Duration,
+		Date:        date,
+		Start:       date,
+		End:         date.Add(rule.Duration),
+		Note:        rule.Note,
+		CategoryID:  rule.CategoryID,
+		Tags:        rule.Tags,
+		RecurringID: rule.ID,
+	}
+}
+
+// recurringDay returns the UTC day of t.
+func recurringDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// monthDay returns the day of the month months after the one of t, at the
+// clock of t, or the last day of the month when it is shorter.
+func monthDay(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// NewRecurringFromParams returns a rule with its first occurrence as Start,
+// on or after the given start.
+func NewRecurringFromParams(params CreateRecurringParams) (Recurring, error) {
+	rule := Recurring{
+		Kind:       params.Kind,
+		Freq:       params.Freq,
+		Interval:   max(params.Interval, 1),
+		Start:      params.Start.UTC().Truncate(time.Millisecond),
+		Until:      params.Until,
+		Count:      params.Count,
+		Duration:   params.Duration,
+		Note:       params.Note,
+		CategoryID: params.CategoryID,
+		Tags:       params.Tags,
+	}
+	if params.Kind == RecurringMoney {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return Recurring{}, err
+		}
+		rule.Money = money
+		rule.Currency = params.Currency
+		rule.AccountID = params.AccountID
+	}
+	if rule.Freq == RecurringMonthly {
+		rule.MonthDay = params.MonthDay
+		if rule.MonthDay == 0 {
+			rule.MonthDay = rule.Start.Day()
+		}
+		start := monthDay(rule.Start, 0, rule.MonthDay)
+		if start.Before(rule.Start) {
+			start = monthDay(rule.Start, rule.Interval, rule.MonthDay)
+		}
+		rule.Start = start
+	}
+	rule.Next = rule.Start
+	rule.Done = rule.Ended(0)
+	return rule, nil
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var roles = []string{RoleUser, RoleAdmin}
+
+// IsRole reports whether role is one of the known roles.
+func IsRole(role string) bool {
+	return slices.Contains(roles, role)
+}
+
+// UserRole returns the role of the user, users registered before roles were
+// introduced have none set.
+func (user User) UserRole() string {
+	if len(user.Role) == 0 {
+		return RoleUser
+	}
+	return user.Role
+}
+
+// UserRoleParams change the role of a user.
+type UserRoleParams struct {
+	Role string `bson:"role" json:"role"`
+}
+
+func (params UserRoleParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if !IsRole(params.Role) {
+		errors["role"] = fmt.Sprintf("role %q should be one of %v", params.Role, roles)
+	}
+	return errors
+}
+
+func (params UserRoleParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
+
+// UserStatusParams disable the account of a user or enable it again,
+// disabled users can't authenticate.
+type UserStatusParams struct {
+	Disabled bool `bson:"disabled" json:"disabled"`
+}
+
+func (params UserStatusParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
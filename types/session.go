@@ -0,0 +1,68 @@
+package types
+
+import (
+	"time"
+
+	"github.com/SpectralJager/spender/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxUsedHashes bounds the refresh tokens a session remembers to detect
+// their reuse, older ones are merely unknown.
+const maxUsedHashes = 100
+
+type RefreshParams struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Session is a login of a user, kept until the user logs out or its refresh
+// token expires. The refresh token rotates on every refresh, only its hash is
+// stored and hashes of the tokens it replaced are kept in UsedHashes.
+type Session struct {
+	ID          string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID     string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	TokenHash   string    `bson:"tokenHash" json:"-"`
+	UsedHashes  []string  `bson:"usedHashes" json:"-"`
+	CreatedAt   time.Time `bson:"createdAt" json:"createdAt"`
+	RefreshedAt time.Time `bson:"refreshedAt" json:"refreshedAt"`
+	ExpiresAt   time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+func NewSession(ownerID, tokenHash string, now time.Time, ttl time.Duration) Session {
+	now = now.UTC().Truncate(time.Millisecond)
+	return Session{
+		OwnerID:     ownerID,
+		TokenHash:   tokenHash,
+		UsedHashes:  []string{},
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+// Rotate returns the update replacing the refresh token of the session with
+// the one hashed to tokenHash, which expires ttl from now.
+func (session Session) Rotate(tokenHash string, now time.Time, ttl time.Duration) RotateSessionParams {
+	used := append(session.UsedHashes, session.TokenHash)
+	if len(used) > maxUsedHashes {
+		used = used[len(used)-maxUsedHashes:]
+	}
+	now = now.UTC().Truncate(time.Millisecond)
+	return RotateSessionParams{
+		TokenHash:   tokenHash,
+		UsedHashes:  used,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+type RotateSessionParams struct {
+	TokenHash   string    `bson:"tokenHash"`
+	UsedHashes  []string  `bson:"usedHashes"`
+	RefreshedAt time.Time `bson:"refreshedAt"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
+
+func (params RotateSessionParams) ToBsonDoc() (*bson.D, error) {
+	return utils.ToBsonDoc(params)
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxTags   = 20
+	maxTagLen = 32
+)
+
+var (
+	tagRegex = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_.-]*$`)
+)
+
+// NormalizeTags trims and lowercases tags, drops empty ones and duplicates
+// and sorts the rest. It returns nil when no tag is left.
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func validateTags(tags []string, errors map[string]string) {
+	if len(tags) > maxTags {
+		errors["tags"] = fmt.Sprintf("there should be at most %d tags", maxTags)
+		return
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLen || !tagRegex.MatchString(tag) {
+			errors["tags"] = fmt.Sprintf("tag %q should be up to %d letters, digits, '_', '.' or '-'", tag, maxTagLen)
+			return
+		}
+	}
+}
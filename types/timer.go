@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+type StartTimerParams struct {
+	Note       string   `json:"note"`
+	CategoryID string   `json:"categoryId"`
+	Tags       []string `json:"tags"`
+}
+
+func (params StartTimerParams) Validate() map[string]string {
+	errors := map[string]string{}
+	validateTags(params.Tags, errors)
+	return errors
+}
+
+// Timer is a running timespend, users have at most one. It becomes a
+// timespend dated Start lasting until it is stopped.
+type Timer struct {
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Start      time.Time `bson:"start" json:"start"`
+	Note       string    `bson:"note" json:"note"`
+	CategoryID string    `bson:"categoryId" json:"categoryId,omitempty"`
+	Tags       []string  `bson:"tags" json:"tags,omitempty"`
+}
+
+// Elapsed returns the time the timer has run until now, in whole seconds.
+func (timer Timer) Elapsed(now time.Time) time.Duration {
+	return now.Sub(timer.Start).Truncate(time.Second)
+}
+
+// Timespend returns the timespend of the timer stopped at now.
+func (timer Timer) Timespend(now time.Time) Timespend {
+	elapsed := timer.Elapsed(now)
+	return Timespend{
+		OwnerID:    timer.OwnerID,
+		Duration:   elapsed,
+		Date:       timer.Start,
+		Start:      timer.Start,
+		End:        timer.Start.Add(elapsed),
+		Note:       timer.Note,
+		CategoryID: timer.CategoryID,
+		Tags:       timer.Tags,
+	}
+}
+
+func NewTimerFromParams(params StartTimerParams, now time.Time) Timer {
+	return Timer{
+		Start:      now.UTC().Truncate(time.Millisecond),
+		Note:       params.Note,
+		CategoryID: params.CategoryID,
+		Tags:       params.Tags,
+	}
+}
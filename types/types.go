@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"time"
@@ -18,6 +19,9 @@ const (
 	maxLastNameLen  = 24
 	minPasswordLen  = 8
 	maxPasswordLen  = 24
+
+	// dayLayout is the layout of days sent without a time, like 2024-03-31.
+	dayLayout = "2006-01-02"
 )
 
 var (
@@ -25,8 +29,9 @@ var (
 )
 
 type UpdateUserParams struct {
-	FirstName string `json:"firstName" bson:"firstName,omitempty"`
-	LastName  string `json:"lastName" bson:"lastName,omitempty"`
+	FirstName    string `json:"firstName" bson:"firstName,omitempty"`
+	LastName     string `json:"lastName" bson:"lastName,omitempty"`
+	BaseCurrency string `json:"baseCurrency" bson:"baseCurrency,omitempty"`
 }
 
 func (params UpdateUserParams) ToBsonDoc() (*bson.D, error) {
@@ -51,14 +56,18 @@ func (p UpdateUserParams) Validate() map[string]string {
 			errors["lastName"] = fmt.Sprintf("last name lenght should be less or equal then %d characters", maxLastNameLen)
 		}
 	}
+	if len(p.BaseCurrency) != 0 && !IsCurrency(p.BaseCurrency) {
+		errors["baseCurrency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", p.BaseCurrency)
+	}
 	return errors
 }
 
 type CreateUserParams struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	BaseCurrency string `json:"baseCurrency"`
 }
 
 func (p CreateUserParams) Validate() map[string]string {
@@ -84,6 +93,9 @@ func (p CreateUserParams) Validate() map[string]string {
 	if !emailRegex.MatchString(p.Email) {
 		errors["email"] = "incorect email format"
 	}
+	if len(p.BaseCurrency) != 0 && !IsCurrency(p.BaseCurrency) {
+		errors["baseCurrency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", p.BaseCurrency)
+	}
 	return errors
 }
 
@@ -93,6 +105,18 @@ type User struct {
 	LastName     string `bson:"lastName" json:"lastName"`
 	Email        string `bson:"email" json:"email"`
 	HashPassword string `bson:"hpassword" json:"-"`
+	BaseCurrency string `bson:"baseCurrency" json:"baseCurrency"`
+	Role         string `bson:"role" json:"role"`
+	Disabled     bool   `bson:"disabled" json:"disabled"`
+}
+
+// Currency returns the currency reports of the user are converted to,
+// users registered before currencies were introduced have none set.
+func (user User) Currency() string {
+	if len(user.BaseCurrency) == 0 {
+		return DefaultCurrency
+	}
+	return user.BaseCurrency
 }
 
 func NewUserFromParams(params CreateUserParams) (User, error) {
@@ -100,45 +124,78 @@ func NewUserFromParams(params CreateUserParams) (User, error) {
 	if err != nil {
 		return User{}, err
 	}
+	if len(params.BaseCurrency) == 0 {
+		params.BaseCurrency = DefaultCurrency
+	}
 	return User{
 		FirstName:    params.FirstName,
 		LastName:     params.LastName,
 		Email:        params.Email,
 		HashPassword: string(encpw),
+		BaseCurrency: params.BaseCurrency,
+		Role:         RoleUser,
 	}, nil
 }
 
+// CreateTimespendParams either have a Date and a Duration, or Start and End
+// of the interval the time was spent in, handlers set Date to Start and
+// Duration to the time between Start and End.
 type CreateTimespendParams struct {
-	Duration time.Duration `json:"duration"`
-	Note     string        `json:"note"`
-	Date     time.Time     `json:"date"`
+	Duration   time.Duration `json:"duration"`
+	Note       string        `json:"note"`
+	Date       time.Time     `json:"date"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	CategoryID string        `json:"categoryId"`
+	ProjectID  string        `json:"projectId"`
+	Billable   bool          `json:"billable"`
+	Tags       []string      `json:"tags"`
 }
 
 func (params CreateTimespendParams) Validate() map[string]string {
 	errors := map[string]string{}
-	if params.Date.IsZero() {
-		errors["date"] = "date should be zero"
+	if params.Start.IsZero() && params.End.IsZero() {
+		if params.Date.IsZero() {
+			errors["date"] = "date should be zero"
+		}
+		if params.Duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
 	}
-	if params.Duration < time.Second {
-		errors["duration"] = "duration should be more then 1 second"
+	validateInterval(params.Start, params.End, errors)
+	if params.Billable && len(params.ProjectID) == 0 {
+		errors["billable"] = "billable timespends should have a project"
 	}
+	validateTags(params.Tags, errors)
 	return errors
 }
 
+// UpdateTimespendParams set Date and Duration like CreateTimespendParams.
+// Billable is left as it is when it is not given.
 type UpdateTimespendParams struct {
-	Duration time.Duration `bson:"duration,omitempty" json:"duration"`
-	Date     time.Time     `bson:"date,omitempty" json:"date"`
-	Note     string        `bson:"note,omitempty" json:"note"`
+	Duration   time.Duration `bson:"duration,omitempty" json:"duration"`
+	Date       time.Time     `bson:"date,omitempty" json:"date"`
+	Start      time.Time     `bson:"start,omitempty" json:"start"`
+	End        time.Time     `bson:"end,omitempty" json:"end"`
+	Note       string        `bson:"note,omitempty" json:"note"`
+	CategoryID string        `bson:"categoryId,omitempty" json:"categoryId"`
+	ProjectID  string        `bson:"projectId,omitempty" json:"projectId"`
+	Billable   *bool         `bson:"billable,omitempty" json:"billable"`
+	Tags       []string      `bson:"tags,omitempty" json:"tags"`
 }
 
 func (params UpdateTimespendParams) Validate() map[string]string {
 	errors := map[string]string{}
-	if params.Date.IsZero() {
-		errors["date"] = "date should be not zero"
-	}
-	if params.Duration < time.Second {
-		errors["duration"] = "duration should be more then 1 second"
+	if params.Start.IsZero() && params.End.IsZero() {
+		if params.Date.IsZero() {
+			errors["date"] = "date should be not zero"
+		}
+		if params.Duration < time.Second {
+			errors["duration"] = "duration should be more then 1 second"
+		}
 	}
+	validateInterval(params.Start, params.End, errors)
+	validateTags(params.Tags, errors)
 	return errors
 }
 
@@ -146,26 +203,66 @@ func (params UpdateTimespendParams) ToBsonDoc() (*bson.D, error) {
 	return utils.ToBsonDoc(params)
 }
 
+// validateInterval adds validation errors unless start and end are both
+// zero, or end is a second or more after start.
+func validateInterval(start, end time.Time, errors map[string]string) {
+	if start.IsZero() != end.IsZero() {
+		errors["end"] = "start and end should be set together"
+	} else if !start.IsZero() && end.Sub(start) < time.Second {
+		errors["end"] = "end should be more then 1 second after start"
+	}
+}
+
+// Timespend is Duration spent on Date, and within the interval from Start
+// to End when they are set.
 type Timespend struct {
-	ID       string        `bson:"_id,omitempty" json:"id,omitempty"`
-	OwnerID  string        `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
-	Duration time.Duration `bson:"duration" json:"duration"`
-	Date     time.Time     `bson:"date" json:"date"`
-	Note     string        `bson:"note" json:"note"`
+	ID         string        `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string        `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+	Date       time.Time     `bson:"date" json:"date"`
+	Start      time.Time     `bson:"start" json:"start"`
+	End        time.Time     `bson:"end" json:"end"`
+	Note       string        `bson:"note" json:"note"`
+	CategoryID string        `bson:"categoryId" json:"categoryId,omitempty"`
+	ProjectID  string        `bson:"projectId" json:"projectId,omitempty"`
+	Billable   bool          `bson:"billable" json:"billable"`
+	Tags       []string      `bson:"tags" json:"tags,omitempty"`
+	// RecurringID is the rule the timespend was materialized from.
+	RecurringID string `bson:"recurringId" json:"recurringId,omitempty"`
+	// InvoiceID is the invoice the timespend was billed on.
+	InvoiceID string `bson:"invoiceId" json:"invoiceId,omitempty"`
+}
+
+// HasInterval reports whether the timespend has Start and End.
+func (timespend Timespend) HasInterval() bool {
+	return !timespend.Start.IsZero() && !timespend.End.IsZero()
 }
 
 func NewTimespendFromParams(params CreateTimespendParams) Timespend {
 	return Timespend{
-		Duration: params.Duration,
-		Date:     params.Date,
-		Note:     params.Note,
+		Duration:   params.Duration,
+		Date:       params.Date,
+		Start:      params.Start,
+		End:        params.End,
+		Note:       params.Note,
+		CategoryID: params.CategoryID,
+		ProjectID:  params.ProjectID,
+		Billable:   params.Billable,
+		Tags:       params.Tags,
 	}
 }
 
+// CreateMoneyspendParams parses Money in the decimals of Currency, handlers
+// set Currency to the base currency of the user when it is not given.
 type CreateMoneyspendParams struct {
-	Money float64   `json:"money"`
-	Note  string    `json:"note"`
-	Date  time.Time `json:"date"`
+	Money        Decimal   `json:"money"`
+	Currency     string    `json:"currency"`
+	Note         string    `json:"note"`
+	Date         time.Time `json:"date"`
+	CategoryID   string    `json:"categoryId"`
+	AccountID    string    `json:"accountId"`
+	Reimbursable bool      `json:"reimbursable"`
+	Tags         []string  `json:"tags"`
 }
 
 func (params CreateMoneyspendParams) Validate() map[string]string {
@@ -173,16 +270,30 @@ func (params CreateMoneyspendParams) Validate() map[string]string {
 	if params.Date.IsZero() {
 		errors["date"] = "date should be not zero"
 	}
-	if params.Money <= 0 {
+	if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+		errors["money"] = err.Error()
+	} else if money <= 0 {
 		errors["money"] = "money should be more then 0"
 	}
+	if !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	validateTags(params.Tags, errors)
 	return errors
 }
 
+// UpdateMoneyspendParams parses Money in the decimals of Currency, handlers
+// set Currency to the one of the updated moneyspend when it is not given.
+// Reimbursable is left as it is when it is not given.
 type UpdateMoneyspendParams struct {
-	Money float64   `bson:"money,omitempty" json:"money"`
-	Date  time.Time `bson:"date,omitempty" json:"date"`
-	Note  string    `bson:"note,omitempty" json:"note"`
+	Money        Decimal   `bson:"-" json:"money"`
+	Currency     string    `bson:"currency,omitempty" json:"currency"`
+	Date         time.Time `bson:"date,omitempty" json:"date"`
+	Note         string    `bson:"note,omitempty" json:"note"`
+	CategoryID   string    `bson:"categoryId,omitempty" json:"categoryId"`
+	AccountID    string    `bson:"accountId,omitempty" json:"accountId"`
+	Reimbursable *bool     `bson:"reimbursable,omitempty" json:"reimbursable"`
+	Tags         []string  `bson:"tags,omitempty" json:"tags"`
 }
 
 func (params UpdateMoneyspendParams) Validate() map[string]string {
@@ -190,30 +301,83 @@ func (params UpdateMoneyspendParams) Validate() map[string]string {
 	if params.Date.IsZero() {
 		errors["date"] = "date should be zero"
 	}
-	if params.Money < 0 {
-		errors["money"] = "money should be more positive number"
+	if len(params.Money) != 0 {
+		if money, err := ParseMoney(params.Money, params.Currency); err != nil {
+			errors["money"] = err.Error()
+		} else if money < 0 {
+			errors["money"] = "money should be more positive number"
+		}
 	}
+	if len(params.Currency) != 0 && !IsCurrency(params.Currency) {
+		errors["currency"] = fmt.Sprintf("currency %q should be an ISO 4217 code", params.Currency)
+	}
+	validateTags(params.Tags, errors)
 	return errors
 }
 
 func (params UpdateMoneyspendParams) ToBsonDoc() (*bson.D, error) {
-	return utils.ToBsonDoc(params)
+	doc, err := utils.ToBsonDoc(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Money) != 0 {
+		money, err := ParseMoney(params.Money, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if money != 0 {
+			*doc = append(*doc, bson.E{Key: "money", Value: money})
+		}
+	}
+	return doc, nil
 }
 
 type Moneyspend struct {
-	ID      string    `bson:"_id,omitempty" json:"id,omitempty"`
-	OwnerID string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
-	Money   float64   `bson:"money" json:"money"`
-	Date    time.Time `bson:"date" json:"date"`
-	Note    string    `bson:"note" json:"note"`
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	OwnerID    string    `bson:"ownerid,omitempty" json:"ownerid,omitempty"`
+	Money      Money     `bson:"money" json:"money"`
+	Currency   string    `bson:"currency" json:"currency"`
+	Date       time.Time `bson:"date" json:"date"`
+	Note       string    `bson:"note" json:"note"`
+	CategoryID string    `bson:"categoryId" json:"categoryId,omitempty"`
+	AccountID  string    `bson:"accountId" json:"accountId,omitempty"`
+	// Reimbursable moneyspends are billed to clients on invoices.
+	Reimbursable bool     `bson:"reimbursable" json:"reimbursable"`
+	Tags         []string `bson:"tags" json:"tags,omitempty"`
+	// RecurringID is the rule the moneyspend was materialized from.
+	RecurringID string `bson:"recurringId" json:"recurringId,omitempty"`
+	// InvoiceID is the invoice the moneyspend was billed on.
+	InvoiceID string `bson:"invoiceId" json:"invoiceId,omitempty"`
 }
 
-func NewMoneyspendFromParams(params CreateMoneyspendParams) Moneyspend {
-	return Moneyspend{
-		Money: params.Money,
-		Date:  params.Date,
-		Note:  params.Note,
+// MarshalJSON writes Money as an exact decimal string in the decimals of
+// Currency.
+func (moneyspend Moneyspend) MarshalJSON() ([]byte, error) {
+	type plain Moneyspend
+	return json.Marshal(struct {
+		plain
+		Money Amount `json:"money"`
+	}{
+		plain: plain(moneyspend),
+		Money: Amount{Money: moneyspend.Money, Currency: moneyspend.Currency},
+	})
+}
+
+func NewMoneyspendFromParams(params CreateMoneyspendParams) (Moneyspend, error) {
+	money, err := ParseMoney(params.Money, params.Currency)
+	if err != nil {
+		return Moneyspend{}, err
 	}
+	return Moneyspend{
+		Money:        money,
+		Currency:     params.Currency,
+		Date:         params.Date,
+		Note:         params.Note,
+		CategoryID:   params.CategoryID,
+		AccountID:    params.AccountID,
+		Reimbursable: params.Reimbursable,
+		Tags:         params.Tags,
+	}, nil
 }
 
 type AuthCredentials struct {